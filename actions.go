@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/peteraba/dblfinder/model"
+)
+
+// decision holds the duplicates of a group to act on, together with a file of the group which is kept
+// The reason tells which rule made the decision, see why.go.
+type decision struct {
+	keep   string
+	files  []string
+	reason string
+}
+
+// export returns the decision in the form of the result model
+func (d decision) export(a action) model.Decision {
+	return model.Decision{Action: a, Keep: d.keep, Files: d.files, Reason: d.reason}
+}
+
+// describeAction returns what happens to the duplicates chosen by the user
+func describeAction(opts options) string {
+	switch opts.action {
+	case markAction:
+		return "marked for deletion"
+	case linkAction:
+		return "replaced by hard links"
+	case reflinkAction:
+		return "replaced by reflinks"
+	case dedupeAction:
+		return "deduplicated"
+	case trashAction:
+		return "moved to the trash"
+	case moveAction:
+		return fmt.Sprintf("moved to %s", opts.target)
+	}
+
+	return "removed"
+}
+
+// apply acts on the duplicates of each decision depending on the action, unless dryRun is set
+// - mark records them in the marks file, to be deleted later by purge-marked
+// - hardlink and reflink replace them by hard links or copy-on-write clones of the kept file
+// - dedupe asks the kernel to share their extents with the kept file
+// - trash moves them to the trash of the platform
+// - move relocates them under the target directory, preserving their path relative to their root
+// - keep deletes them
+func apply(decisions []decision, opts options) {
+	var all []string
+	for _, d := range decisions {
+		all = append(all, d.files...)
+	}
+
+	if len(all) == 0 {
+		return
+	}
+
+	jsonProgress.setPhase(actionStage, 0)
+	defer stats.track(actionStage)()
+	stats.add(actionStage, int64(len(all)), 0)
+
+	switch opts.action {
+	case markAction:
+		var marks []mark
+		for _, d := range decisions {
+			marks = append(marks, newMarks(d.keep, d.files, d.reason)...)
+		}
+
+		markFiles(opts.marksFile, marks, opts.dryRun)
+	case linkAction, reflinkAction:
+		for _, d := range decisions {
+			linkFiles(d.keep, d.files, opts.action, opts.dryRun)
+		}
+	case dedupeAction:
+		for _, d := range decisions {
+			dedupeFiles(d.keep, d.files, opts.dryRun)
+		}
+	case trashAction:
+		trashFiles(all, opts.trashBackend, opts.dryRun)
+	case moveAction:
+		moveFiles(all, opts.roots, opts.target, opts.dryRun)
+	default:
+		deleteOtherFiles(all, opts.dryRun)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/peteraba/dblfinder/model"
+)
+
+// scan states reported by the API
+const (
+	scanRunning = "running"
+	scanDone    = "done"
+	scanFailed  = "failed"
+)
+
+// apiReason is the reason recorded for decisions submitted through the API
+const apiReason = "api"
+
+// apiScanRequest starts a scan, fields left empty take the defaults of the command line
+type apiScanRequest struct {
+	Roots   []string `json:"roots"`
+	Exclude []string `json:"exclude"`
+	Hash    string   `json:"hash"`
+}
+
+// apiProgress is how far a scan got, counted since it started
+type apiProgress struct {
+	FilesFound  int64 `json:"files_found"`
+	FilesHashed int64 `json:"files_hashed"`
+	BytesHashed int64 `json:"bytes_hashed"`
+}
+
+// apiScan is a scan started through the API
+type apiScan struct {
+	ID       int         `json:"id"`
+	Roots    []string    `json:"roots"`
+	State    string      `json:"state"`
+	Error    string      `json:"error,omitempty"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Progress apiProgress `json:"progress"`
+	Groups   int         `json:"groups"`
+	groups   []model.Group
+	base     [3]int64
+}
+
+// apiServer runs scans and acts on their duplicates as requested by other tools, through a JSON API
+// Scans share the run statistics their progress is counted by, so only one of them runs at a time.
+type apiServer struct {
+	mu    sync.Mutex
+	scans []*apiScan
+	opts  options
+}
+
+// runAPI serves the API on the address of -listen until the process is stopped
+func runAPI(opts options) error {
+	s := &apiServer{opts: opts}
+
+	fmt.Printf("Serving the API on %s\n", opts.listen)
+
+	return http.ListenAndServe(opts.listen, s.handler())
+}
+
+// handler returns the routes of the API
+// - POST /api/scans starts a scan, GET /api/scans lists them
+// - GET /api/scans/{id} returns a scan with its progress
+// - GET /api/scans/{id}/groups returns the duplicate groups found by a scan
+// - POST /api/scans/{id}/decisions acts on the files of the decisions given
+// - GET /metrics returns the metrics of the process for Prometheus
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/scans", s.scansHandler)
+	mux.HandleFunc("/api/scans/", s.scanHandler)
+	mux.HandleFunc("/metrics", metricsHandler)
+
+	return mux
+}
+
+// writeJSON writes v as the JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error as a JSON response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// decodeJSON decodes the JSON body of a request into v
+// Requests have to declare their body as JSON, which browsers don't do across origins without asking first, so other
+// sites can't make the browser of the user start scans or act on files.
+func decodeJSON(r *http.Request, v interface{}) error {
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+		return fmt.Errorf("the body of the request must be application/json")
+	}
+
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// scansHandler lists the scans or starts a new one
+func (s *apiServer) scansHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		res := []apiScan{}
+		for _, scan := range s.scans {
+			res = append(res, s.snapshot(scan))
+		}
+
+		writeJSON(w, http.StatusOK, res)
+	case http.MethodPost:
+		var req apiScanRequest
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		scan, status, err := s.start(req)
+		if err != nil {
+			writeError(w, status, err)
+			return
+		}
+
+		writeJSON(w, status, scan)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+	}
+}
+
+// start starts a scan in the background, unless one is running already
+func (s *apiServer) start(req apiScanRequest) (apiScan, int, error) {
+	if len(req.Roots) == 0 {
+		return apiScan{}, http.StatusBadRequest, fmt.Errorf("no roots given")
+	}
+
+	hashName := req.Hash
+	if hashName == "" {
+		hashName = s.opts.hashName
+	}
+
+	newHash, ok := hashers[hashName]
+	if !ok {
+		return apiScan{}, http.StatusBadRequest, unknownValue("hash algorithm", hashName, hasherNames())
+	}
+
+	filter := s.opts.filter
+	if len(req.Exclude) > 0 {
+		f, err := newWalkFilter("", "", "", nil, req.Exclude)
+		if err != nil {
+			return apiScan{}, http.StatusBadRequest, err
+		}
+
+		filter = f
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, scan := range s.scans {
+		if scan.State == scanRunning {
+			return apiScan{}, http.StatusConflict, fmt.Errorf("scan %d is still running", scan.ID)
+		}
+	}
+
+	scan := &apiScan{ID: len(s.scans) + 1, Roots: req.Roots, State: scanRunning, Started: time.Now(), base: progressCounters()}
+	s.scans = append(s.scans, scan)
+
+	go func() {
+		defer recoverPanic()
+
+		groups, err := findDuplicateGroups(req.Roots, filter, newHash)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		now := time.Now()
+		scan.Finished = &now
+		scan.Progress = scan.progress()
+
+		if err != nil {
+			scan.State, scan.Error = scanFailed, err.Error()
+			return
+		}
+
+		scan.State, scan.groups = scanDone, ownedGroups(groups)
+		scan.Groups = len(scan.groups)
+	}()
+
+	return s.snapshot(scan), http.StatusAccepted, nil
+}
+
+// progressCounters returns the files found, the files hashed and the bytes hashed so far by the run
+func progressCounters() [3]int64 {
+	walk, sample := stats.stage(walkStage), stats.stage(sampleStage)
+
+	return [3]int64{atomic.LoadInt64(&walk.files), atomic.LoadInt64(&sample.files), atomic.LoadInt64(&sample.bytes)}
+}
+
+// progress returns how far the scan got since it started
+func (scan *apiScan) progress() apiProgress {
+	now := progressCounters()
+
+	return apiProgress{now[0] - scan.base[0], now[1] - scan.base[1], now[2] - scan.base[2]}
+}
+
+// snapshot returns a copy of a scan, with its progress updated if it's still running
+func (s *apiServer) snapshot(scan *apiScan) apiScan {
+	res := *scan
+	if res.State == scanRunning {
+		res.Progress = scan.progress()
+	}
+
+	return res
+}
+
+// scanHandler serves a scan, its groups and the decisions about them
+func (s *apiServer) scanHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/scans/"), "/"), "/")
+
+	id, err := strconv.Atoi(parts[0])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil || id < 1 || id > len(s.scans) || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+
+	scan := s.scans[id-1]
+
+	resource := ""
+	if len(parts) == 2 {
+		resource = parts[1]
+	}
+
+	switch {
+	case resource == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.snapshot(scan))
+	case resource == "groups" && r.Method == http.MethodGet:
+		if scan.State != scanDone {
+			writeError(w, http.StatusConflict, fmt.Errorf("scan %d is %s", scan.ID, scan.State))
+			return
+		}
+
+		groups := scan.groups
+		if groups == nil {
+			groups = []model.Group{}
+		}
+
+		writeJSON(w, http.StatusOK, groups)
+	case resource == "decisions" && r.Method == http.MethodPost:
+		var submitted []model.Decision
+		if err := decodeJSON(r, &submitted); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		decisions, err := s.validate(scan, submitted)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		act(decisions, s.opts)
+		scan.groups = withoutActed(scan.groups, decisions, s.opts.dryRun)
+		scan.Groups = len(scan.groups)
+
+		res := []model.Decision{}
+		for _, d := range decisions {
+			res = append(res, d.export(s.opts.action))
+		}
+
+		writeJSON(w, http.StatusOK, res)
+	case resource == "" || resource == "groups" || resource == "decisions":
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+	}
+}
+
+// validate turns the decisions submitted into decisions to apply
+// The kept file and the files to act on must belong to the same group found by the scan, and no file kept by any of
+// the decisions can be acted on by another one, so that a copy of each group survives. The action of a decision is the
+// one the server runs with, decisions naming another one or protected files are refused. The scan may be older than
+// the files, so files which are no longer identical to the file kept, or not settled yet, are left alone.
+func (s *apiServer) validate(scan *apiScan, submitted []model.Decision) ([]decision, error) {
+	if scan.State != scanDone {
+		return nil, fmt.Errorf("scan %d is %s", scan.ID, scan.State)
+	}
+
+	if s.opts.action == listAction {
+		return nil, fmt.Errorf("the server runs with -action %s, it doesn't act on files", listAction)
+	}
+
+	groupOf := map[string]int{}
+	for i, g := range scan.groups {
+		for _, f := range g.Files {
+			groupOf[f.Path] = i
+		}
+	}
+
+	var res []decision
+	for _, d := range submitted {
+		if d.Action != "" && d.Action != s.opts.action {
+			return nil, fmt.Errorf("the server only applies -action %s, not %s", s.opts.action, d.Action)
+		}
+
+		group, ok := groupOf[d.Keep]
+		if !ok {
+			return nil, fmt.Errorf("not a duplicate found by scan %d: %s", scan.ID, d.Keep)
+		}
+
+		if len(d.Files) == 0 {
+			continue
+		}
+
+		for _, file := range d.Files {
+			if i, ok := groupOf[file]; !ok || i != group {
+				return nil, fmt.Errorf("not a duplicate of %s: %s", d.Keep, file)
+			}
+
+			if matchAny(s.opts.protect, file) {
+				return nil, fmt.Errorf("protected files are never acted on: %s", file)
+			}
+		}
+
+		reason := d.Reason
+		if reason == "" {
+			reason = apiReason
+		}
+
+		res = append(res, decision{d.Keep, uniqueStrings(d.Files), reason})
+	}
+
+	if err := checkKeptFiles(res); err != nil {
+		return nil, err
+	}
+
+	var verified []decision
+	for _, d := range res {
+		files := d.files
+		if s.opts.settle > 0 {
+			files = settledFiles(files, s.opts.settle, time.Now())
+		}
+
+		if files = verifyDeleteFiles(d.keep, files); len(files) > 0 {
+			verified = append(verified, decision{d.keep, files, d.reason})
+		}
+	}
+
+	return verified, nil
+}
+
+// withoutActed drops the files acted on from the groups, and the groups left with a single file, unless in dry run
+// mode
+func withoutActed(groups []model.Group, decisions []decision, dryRun bool) []model.Group {
+	if dryRun {
+		return groups
+	}
+
+	acted := map[string]bool{}
+	for _, d := range decisions {
+		for _, file := range d.files {
+			acted[file] = true
+		}
+	}
+
+	var res []model.Group
+	for _, g := range groups {
+		var files []model.FileRef
+		for _, f := range g.Files {
+			if !acted[f.Path] {
+				files = append(files, f)
+			}
+		}
+
+		if len(files) < 2 {
+			continue
+		}
+
+		g.Files = files
+		g.Reclaimable = g.Size * int64(len(files)-1)
+		res = append(res, g)
+	}
+
+	return res
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peteraba/dblfinder/model"
+)
+
+func Test_apiServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c, p := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c"), filepath.Join(dir, "p")
+	for _, path := range []string{a, b, c, p} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &apiServer{opts: options{action: linkAction, hashName: defaultHash, protect: []pathPattern{{glob: "p"}}}}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	post := func(path, contentType, body string, v interface{}) int {
+		res, err := http.Post(srv.URL+path, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if v != nil {
+			json.NewDecoder(res.Body).Decode(v)
+		}
+
+		return res.StatusCode
+	}
+
+	get := func(path string, v interface{}) int {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		json.NewDecoder(res.Body).Decode(v)
+
+		return res.StatusCode
+	}
+
+	roots, _ := json.Marshal(apiScanRequest{Roots: []string{dir}})
+	if status := post("/api/scans", "text/plain", string(roots), nil); status != http.StatusBadRequest {
+		t.Errorf("scan without a JSON content type = %d, want 400", status)
+	}
+
+	var scan apiScan
+	if status := post("/api/scans", "application/json", string(roots), &scan); status != http.StatusAccepted || scan.ID != 1 {
+		t.Fatalf("start scan = %d, %+v", status, scan)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); scan.State == scanRunning && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		get("/api/scans/1", &scan)
+	}
+	if scan.State != scanDone || scan.Groups != 1 || scan.Progress.FilesFound != 4 {
+		t.Fatalf("scan = %+v, want a finished scan with one group", scan)
+	}
+
+	var groups []model.Group
+	if status := get("/api/scans/1/groups", &groups); status != http.StatusOK || len(groups) != 1 || len(groups[0].Files) != 4 {
+		t.Fatalf("groups = %d, %+v", status, groups)
+	}
+
+	if err := ioutil.WriteFile(c, []byte("ABCD"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		settle    time.Duration
+		decisions []model.Decision
+		want      int
+		applied   int
+	}{
+		{"unknown-keep", 0, []model.Decision{{Keep: filepath.Join(dir, "x"), Files: []string{b}}}, http.StatusBadRequest, 0},
+		{"keep-acted-on", 0, []model.Decision{{Keep: a, Files: []string{a, b}}}, http.StatusBadRequest, 0},
+		{"keep-each-other", 0, []model.Decision{{Keep: a, Files: []string{b}}, {Keep: b, Files: []string{a}}}, http.StatusBadRequest, 0},
+		{"protected", 0, []model.Decision{{Keep: a, Files: []string{b, p}}}, http.StatusBadRequest, 0},
+		{"not-settled", time.Hour, []model.Decision{{Keep: a, Files: []string{b}}}, http.StatusOK, 0},
+		{"changed-since-scan", 0, []model.Decision{{Keep: a, Files: []string{c}}}, http.StatusOK, 0},
+		{"other-action", 0, []model.Decision{{Action: deleteAction, Keep: a, Files: []string{b}}}, http.StatusBadRequest, 0},
+		{"link", 0, []model.Decision{{Keep: a, Files: []string{b}}}, http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.opts.settle = tt.settle
+			body, _ := json.Marshal(tt.decisions)
+
+			var applied []model.Decision
+			if status := post("/api/scans/1/decisions", "application/json", string(body), &applied); status != tt.want || len(applied) != tt.applied {
+				t.Errorf("decisions = %d, %+v, want %d with %d decision(s) applied", status, applied, tt.want, tt.applied)
+			}
+		})
+	}
+
+	if linked, err := sameFile(a, b); err != nil || !linked {
+		t.Errorf("decisions didn't link %s to %s: %v", b, a, err)
+	}
+
+	if linked, err := sameFile(a, c); err != nil || linked {
+		t.Errorf("decisions linked %s, which changed since the scan: %v", c, err)
+	}
+
+	if get("/api/scans/1/groups", &groups); len(groups) != 1 || len(groups[0].Files) != 3 {
+		t.Errorf("groups after the decisions = %+v, want the files not acted on", groups)
+	}
+
+	if status := get("/api/scans/2", &scan); status != http.StatusNotFound {
+		t.Errorf("unknown scan = %d, want 404", status)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// capability is a set of operations a storage backend supports besides listing and reading files
+type capability uint
+
+const (
+	capRangeRead capability = 1 << iota
+	capRemove
+	capRename
+	capHardlink
+	capClone
+)
+
+// capabilityNames names the capabilities in error messages
+var capabilityNames = map[capability]string{
+	capRangeRead: "range reads",
+	capRemove:    "removing files",
+	capRename:    "moving files",
+	capHardlink:  "hard links",
+	capClone:     "cloning files",
+}
+
+// has returns true if all capabilities of other are present
+func (c capability) has(other capability) bool {
+	return c&other == other
+}
+
+// missing returns the names of the capabilities of other which are not present
+func (c capability) missing(other capability) []string {
+	var res []string
+	for bit := capRangeRead; bit <= capClone; bit <<= 1 {
+		if other.has(bit) && !c.has(bit) {
+			res = append(res, capabilityNames[bit])
+		}
+	}
+
+	return res
+}
+
+// storage is where the files to deduplicate are stored
+// Backends declare their capabilities, actions requiring an operation a backend lacks are refused up front, and
+// without range reads files are hashed whole instead of sampled.
+// Only local storage is implemented, remote ones like S3 or SFTP need client libraries the module doesn't depend on.
+type storage interface {
+	name() string
+	capabilities() capability
+	walk(root string, visit filepath.WalkFunc) error
+	stat(path string) (os.FileInfo, error)
+	openRange(path string, offset, length int64) (io.ReadCloser, error)
+	remove(path string) error
+	rename(oldpath, newpath string) error
+	link(oldname, newname string) error
+}
+
+// localStorage stores files in the local file system, supporting all operations
+// Cloning depends on the file system, which is checked file by file when the reflink or dedupe action is applied.
+type localStorage struct{}
+
+func (localStorage) name() string {
+	return "local"
+}
+
+func (localStorage) capabilities() capability {
+	return capRangeRead | capRemove | capRename | capHardlink | capClone
+}
+
+func (localStorage) walk(root string, visit filepath.WalkFunc) error {
+	return filepath.Walk(root, visit)
+}
+
+func (localStorage) stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+// openRange opens a file for reading length bytes from offset, a negative length reads until the end of the file
+func (localStorage) openRange(path string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		return f, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, offset, length), f}, nil
+}
+
+func (localStorage) remove(path string) error {
+	return os.Remove(path)
+}
+
+func (localStorage) rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (localStorage) link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+// requiredCapabilities returns the capabilities of a storage backend an action depends on
+func requiredCapabilities(a action) capability {
+	switch a {
+	case listAction:
+		return 0
+	case linkAction:
+		return capHardlink | capRemove | capRename
+	case reflinkAction, dedupeAction:
+		return capClone
+	case trashAction, moveAction:
+		return capRename
+	}
+
+	return capRemove
+}
+
+// checkCapabilities returns an error if a storage backend can't carry out an action
+func checkCapabilities(s storage, a action) error {
+	if missing := s.capabilities().missing(requiredCapabilities(a)); len(missing) > 0 {
+		return fmt.Errorf("-action %s is not supported by the %s storage, it lacks %s", a, s.name(), strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readOnlyStorage is a local storage which only supports listing and reading whole files
+type readOnlyStorage struct {
+	localStorage
+}
+
+func (readOnlyStorage) name() string {
+	return "read-only"
+}
+
+func (readOnlyStorage) capabilities() capability {
+	return 0
+}
+
+func Test_checkCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage storage
+		action  action
+		wantErr string
+	}{
+		{"local-hardlink", localStorage{}, linkAction, ""},
+		{"read-only-list", readOnlyStorage{}, listAction, ""},
+		{"read-only-trash", readOnlyStorage{}, trashAction, "lacks moving files"},
+		{"read-only-hardlink", readOnlyStorage{}, linkAction, "lacks removing files, moving files, hard links"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCapabilities(tt.storage, tt.action)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkCapabilities() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_localStorage_openRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a")
+	if err := ioutil.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		offset, length int64
+		want           string
+	}{
+		{"range", 2, 3, "234"},
+		{"to-the-end", 7, -1, "789"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := localStorage{}.openRange(path, tt.offset, tt.length)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("openRange() read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	bucketHash     = "hash"
+	bucketSkip     = "skip"
+	bucketShardDir = "shard-dir"
+	bucketSameExt  = "same-ext"
+)
+
+// bucket is a set of same size files which need to be hashed to find duplicates among them
+type bucket struct {
+	size  int64
+	files []string
+}
+
+// validBucketMode returns true if mode is a known way of handling large buckets
+func validBucketMode(mode string) bool {
+	switch mode {
+	case bucketHash, bucketSkip, bucketShardDir, bucketSameExt:
+		return true
+	}
+
+	return false
+}
+
+// toBuckets turns same size files into buckets, largest files first
+// Buckets with more than limit files (if limit is positive) are logged and handled according to mode: hashed
+// anyway, skipped, split into buckets per directory, or split into buckets of files sharing their extension or name.
+func toBuckets(sameSizeFiles map[int64][]string, limit int, mode string) ([]bucket, int) {
+	sizes := sortedSizes(sameSizeFiles)
+
+	var (
+		buckets []bucket
+		count   int
+	)
+
+	for _, size := range sizes {
+		files := sameSizeFiles[size]
+
+		groups := [][]string{files}
+		if limit > 0 && len(files) > limit {
+			slog.Warn("many files have the same size", "files", len(files), "size", humanSize(size), "handling", describeBucketMode(mode))
+
+			switch mode {
+			case bucketSkip:
+				for _, file := range files {
+					traceSkip(file, "pathological size", "size", size)
+				}
+				groups = nil
+			case bucketShardDir:
+				groups = shardFiles(files, filepath.Dir)
+			case bucketSameExt:
+				groups = shardFiles(files, quickKey)
+			}
+		}
+
+		for _, group := range groups {
+			if len(group) < 2 {
+				continue
+			}
+
+			buckets = append(buckets, bucket{size, group})
+			count += len(group)
+		}
+	}
+
+	return buckets, count
+}
+
+// shardFiles splits files into groups sharing the same key, keeping the order of the files
+func shardFiles(files []string, key func(string) string) [][]string {
+	var (
+		keys   []string
+		shards = map[string][]string{}
+	)
+
+	for _, file := range files {
+		k := key(file)
+		if _, ok := shards[k]; !ok {
+			keys = append(keys, k)
+		}
+
+		shards[k] = append(shards[k], file)
+	}
+
+	var res [][]string
+	for _, k := range keys {
+		res = append(res, shards[k])
+	}
+
+	return res
+}
+
+// describeBucketMode explains what happens to a large bucket
+func describeBucketMode(mode string) string {
+	switch mode {
+	case bucketSkip:
+		return "skipped"
+	case bucketShardDir:
+		return "only compared within their directories"
+	case bucketSameExt:
+		return "only compared with files of the same type"
+	}
+
+	return "hashed anyway, see -bucket-mode"
+}
+
+// interleaveBuckets orders buckets round-robin across roots and orders of magnitude of file sizes
+// Buckets are otherwise hashed largest files first, so a run cut short would only report duplicates among the largest
+// files, likely of a single directory. Interleaved, the partial results are representative of all the roots instead.
+// A bucket belongs to the root of its first file.
+func interleaveBuckets(buckets []bucket, roots []string) []bucket {
+	type key struct {
+		root, class int
+	}
+
+	var (
+		keys   []key
+		queues = map[key][]bucket{}
+	)
+
+	for _, b := range buckets {
+		root, _ := rootIndex(b.files[0], roots)
+		k := key{root, len(strconv.FormatInt(b.size, 10))}
+
+		if _, ok := queues[k]; !ok {
+			keys = append(keys, k)
+		}
+
+		queues[k] = append(queues[k], b)
+	}
+
+	res := make([]bucket, 0, len(buckets))
+	for len(res) < len(buckets) {
+		for _, k := range keys {
+			if len(queues[k]) == 0 {
+				continue
+			}
+
+			res = append(res, queues[k][0])
+			queues[k] = queues[k][1:]
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_toBuckets(t *testing.T) {
+	sameSizeFiles := map[int64][]string{
+		10: {"a/1.log", "a/2.log", "b/3.log", "b/4.txt", "c/5.txt"},
+		20: {"a/x", "b/x"},
+	}
+
+	type args struct {
+		limit int
+		mode  string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      []bucket
+		wantCount int
+	}{
+		{
+			"under-limit",
+			args{
+				0,
+				bucketSkip,
+			},
+			[]bucket{
+				{20, []string{"a/x", "b/x"}},
+				{10, []string{"a/1.log", "a/2.log", "b/3.log", "b/4.txt", "c/5.txt"}},
+			},
+			7,
+		},
+		{
+			"hash",
+			args{
+				3,
+				bucketHash,
+			},
+			[]bucket{
+				{20, []string{"a/x", "b/x"}},
+				{10, []string{"a/1.log", "a/2.log", "b/3.log", "b/4.txt", "c/5.txt"}},
+			},
+			7,
+		},
+		{
+			"skip",
+			args{
+				3,
+				bucketSkip,
+			},
+			[]bucket{
+				{20, []string{"a/x", "b/x"}},
+			},
+			2,
+		},
+		{
+			"shard-dir",
+			args{
+				3,
+				bucketShardDir,
+			},
+			[]bucket{
+				{20, []string{"a/x", "b/x"}},
+				{10, []string{"a/1.log", "a/2.log"}},
+				{10, []string{"b/3.log", "b/4.txt"}},
+			},
+			6,
+		},
+		{
+			"same-ext",
+			args{
+				3,
+				bucketSameExt,
+			},
+			[]bucket{
+				{20, []string{"a/x", "b/x"}},
+				{10, []string{"a/1.log", "a/2.log", "b/3.log"}},
+				{10, []string{"b/4.txt", "c/5.txt"}},
+			},
+			7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := toBuckets(sameSizeFiles, tt.args.limit, tt.args.mode)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toBuckets() got = %v, want %v", got, tt.want)
+			}
+			if count != tt.wantCount {
+				t.Errorf("toBuckets() count = %v, want %v", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func Test_interleaveBuckets(t *testing.T) {
+	p := filepath.FromSlash
+	roots := []string{p("/videos"), p("/docs")}
+
+	buckets := []bucket{
+		{5000000, []string{p("/videos/a"), p("/videos/b")}},
+		{4000000, []string{p("/videos/c"), p("/docs/c")}},
+		{3000000, []string{p("/videos/d"), p("/videos/e")}},
+		{900, []string{p("/videos/f"), p("/videos/g")}},
+		{800, []string{p("/docs/h"), p("/docs/i")}},
+		{700, []string{p("/docs/j"), p("/docs/k")}},
+	}
+
+	var got []int64
+	for _, b := range interleaveBuckets(buckets, roots) {
+		got = append(got, b.size)
+	}
+
+	want := []int64{5000000, 900, 800, 4000000, 700, 3000000}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("interleaveBuckets() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// runCache implements the cache command, which lists the caches kept between runs, or clears them with -clear
+// Clearing them only costs time: thumbnails are made again when needed, and sample sizes are tuned from scratch.
+func runCache(args []string) error {
+	var (
+		clearAll                  bool
+		thumbCacheDir, tuningFile string
+	)
+
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	fs.BoolVar(&clearAll, "clear", false, "remove the cached thumbnails and the sample size tuning state")
+	fs.StringVar(&thumbCacheDir, "thumb-cache", defaultThumbCache(), "directory caching the thumbnails of images and videos")
+	fs.StringVar(&tuningFile, "tuning-file", defaultTuningFile(), "file holding the sample size tuning state")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder cache [-clear] [-thumb-cache <d>] [-tuning-file <f>]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	thumbs := thumbCache{thumbCacheDir, 0}
+
+	if clearAll {
+		if thumbs.enabled() {
+			if err := thumbs.prune(); err != nil {
+				return err
+			}
+		}
+
+		if tuningFile != "" {
+			if err := os.Remove(tuningFile); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	if thumbs.enabled() {
+		files, size, err := thumbs.usage()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("thumbnails: %s, %d file(s), %s\n", thumbs.dir, files, humanSize(size))
+	}
+
+	if tuningFile != "" {
+		var size int64
+		if fi, err := os.Stat(tuningFile); err == nil {
+			size = fi.Size()
+		}
+
+		fmt.Printf("sample size tuning: %s, %s\n", tuningFile, humanSize(size))
+	}
+
+	return nil
+}
+
+// usage returns the number of thumbnails in the cache and the space they take
+func (c thumbCache) usage() (int, int64, error) {
+	infos, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var size int64
+	for _, fi := range infos {
+		size += fi.Size()
+	}
+
+	return len(infos), size, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// checkResult is the outcome of the check command, written as JSON with -output json
+type checkResult struct {
+	MaxWasted int64         `json:"max_wasted"`
+	Wasted    int64         `json:"wasted"`
+	Passed    bool          `json:"passed"`
+	Groups    []reportGroup `json:"groups"`
+}
+
+// runCheck implements the check command, which fails if the duplicates under the roots waste more than a budget
+// It's meant for CI: it never asks nor acts on files, the run exits with exitDuplicates if the budget is exceeded.
+func runCheck(args []string) error {
+	var (
+		maxWasted            sizeFlag
+		gitignore            bool
+		output, hashName     string
+		exclude, excludeFrom listFlag
+	)
+
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Var(&maxWasted, "max-wasted", "space duplicates may waste before the check fails (eg. 10MB)")
+	fs.BoolVar(&gitignore, "respect-gitignore", true, "skip files ignored by .gitignore files and .git directories")
+	fs.Var(&exclude, "exclude", "glob (or regexp prefixed with re:) of files and directories to ignore, can be repeated")
+	fs.Var(&excludeFrom, "exclude-from", "file of exclude patterns, one per line, can be repeated")
+	fs.StringVar(&output, "output", textOutput, "format to report the result in (text, json)")
+	fs.StringVar(&hashName, "hash", defaultHash, "hash algorithm used to compare files")
+	fs.BoolVar(&exactSizes, "bytes", false, "list sizes in bytes instead of human readable units")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder check [-max-wasted <s>] [-output <s>] [-exclude <s>]... <root>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if output != textOutput && output != jsonOutput {
+		return unknownValue("output format", output, []string{textOutput, jsonOutput})
+	}
+
+	newHash, ok := hashers[hashName]
+	if !ok {
+		return unknownValue("hash algorithm", hashName, hasherNames())
+	}
+
+	for _, file := range excludeFrom {
+		patterns, err := readPatternFile(file)
+		if err != nil {
+			return fmt.Errorf("can't read exclude file: %v", err)
+		}
+
+		exclude = append(exclude, patterns...)
+	}
+
+	filter, err := newWalkFilter("", "", "", nil, exclude)
+	if err != nil {
+		return err
+	}
+	filter.gitignore = gitignore
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	out := os.Stdout
+	if output == jsonOutput {
+		// the result goes to stdout, progress messages are moved out of its way
+		os.Stdout = os.Stderr
+	}
+
+	groups, err := findDuplicateGroups(roots, filter, newHash)
+	if err != nil {
+		return err
+	}
+
+	res := checkResult{MaxWasted: int64(maxWasted), Groups: groups}
+	for _, g := range groups {
+		res.Wasted += g.Reclaimable
+	}
+	res.Passed = res.Wasted <= res.MaxWasted
+
+	if !res.Passed {
+		setExitCode(exitDuplicates)
+	}
+
+	if output == jsonOutput {
+		if res.Groups == nil {
+			res.Groups = []reportGroup{}
+		}
+
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(res)
+	}
+
+	return writeCheckListing(out, res)
+}
+
+// findDuplicateGroups finds the duplicates under the roots by hashing whole files, the most wasteful groups first
+func findDuplicateGroups(roots []string, filter walkFilter, newHash func() hash.Hash) ([]reportGroup, error) {
+	fileSizes, err := getAllFileSizes(roots, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sameSizeFiles, _ := filterSameSizeFiles(fileSizes)
+	sameSizeFiles, _ = collapseHardlinks(sameSizeFiles)
+	sameSizeFiles, _ = filterSameSizeFiles(sameSizeFiles)
+
+	buckets, _ := toBuckets(sameSizeFiles, 0, bucketHash)
+	groups, hashes, _ := filterSameHashFiles(buckets, 10, 0, 0, []string{sampleHead}, newHash, false, time.Time{}, nil)
+
+	sortByWaste(groups)
+	atomic.AddInt64(&metrics.groupsFound, int64(len(groups)))
+
+	return newReportGroups(groups, hashes), nil
+}
+
+// writeCheckListing lists the duplicate groups the way diffs show added lines, the first file of each group being
+// the original and the others the duplicates, followed by the verdict of the check
+func writeCheckListing(w io.Writer, res checkResult) error {
+	for _, g := range res.Groups {
+		fmt.Fprintf(w, "@@ %d copies of %s, %s wasted @@\n", len(g.Files), humanSize(g.Size), humanSize(g.Reclaimable))
+
+		for i, f := range g.Files {
+			prefix := "+"
+			if i == 0 {
+				prefix = " "
+			}
+
+			fmt.Fprintf(w, "%s%s\n", prefix, f.Path)
+		}
+	}
+
+	verdict := "passed"
+	if !res.Passed {
+		verdict = "FAILED"
+	}
+
+	_, err := fmt.Fprintf(w, "check %s: %d duplicate group(s) waste %s, the budget is %s\n", verdict, len(res.Groups), humanSize(res.Wasted), humanSize(res.MaxWasted))
+
+	return err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_findDuplicateGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"logo.png":      "logo",
+		"old/logo.png":  "logo",
+		"copy/logo.png": "logo",
+		"icon.png":      "icon",
+		"icon-2x.png":   "ICON",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := findDuplicateGroups([]string{dir}, walkFilter{}, hashers[defaultHash])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 1 || len(groups[0].Files) != 3 || groups[0].Reclaimable != 8 {
+		t.Errorf("findDuplicateGroups() = %+v, want a group of the three logos", groups)
+	}
+}
+
+func Test_writeCheckListing(t *testing.T) {
+	res := checkResult{
+		MaxWasted: 4,
+		Wasted:    8,
+		Groups:    []reportGroup{{Size: 4, Reclaimable: 8, Files: []reportFile{{Path: "a"}, {Path: "b"}, {Path: "c"}}}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeCheckListing(&buf, res); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `@@ 3 copies of 4 B, 8 B wasted @@
+ a
++b
++c
+check FAILED: 1 duplicate group(s) waste 8 B, the budget is 4 B
+`
+	if buf.String() != want {
+		t.Errorf("writeCheckListing() = %q, want %q", buf.String(), want)
+	}
+}
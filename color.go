@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape codes of the colors used
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+
+	// colors of the parts of the output
+	headerColor    = colorBold + colorCyan
+	preferredColor = colorGreen
+	protectedColor = colorYellow
+	deleteColor    = colorRed
+	warningColor   = colorYellow
+	errorColor     = colorBold + colorRed
+)
+
+// colorMode is set if the output is colored, see useColor
+var colorMode bool
+
+// useColor returns true if the output is to be colored
+// Colors are only used on terminals, and never with -no-color, in plain mode or if the NO_COLOR environment variable
+// is set to anything but an empty string, see https://no-color.org.
+func useColor(noColor, plain bool, lookup func(string) (string, bool)) bool {
+	if noColor || plain {
+		return false
+	}
+
+	if value, ok := lookup("NO_COLOR"); ok && value != "" {
+		return false
+	}
+
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// colorize returns s in the given color in color mode, s as is otherwise
+func colorize(color, s string) string {
+	if !colorMode {
+		return s
+	}
+
+	return color + s + colorReset
+}
+
+// levelColorWriter colors the warnings and errors written by the text log handler, a record at a time
+type levelColorWriter struct {
+	w io.Writer
+}
+
+// Write colors p if it's a warning or an error record
+func (c levelColorWriter) Write(p []byte) (int, error) {
+	color := ""
+	switch {
+	case bytes.Contains(p, []byte(" level=ERROR ")):
+		color = errorColor
+	case bytes.Contains(p, []byte(" level=WARN ")):
+		color = warningColor
+	}
+
+	if color == "" {
+		return c.w.Write(p)
+	}
+
+	if _, err := io.WriteString(c.w, color+string(bytes.TrimRight(p, "\n"))+colorReset+"\n"); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_useColor(t *testing.T) {
+	tests := []struct {
+		name    string
+		noColor bool
+		plain   bool
+		env     map[string]string
+	}{
+		{"no-color", true, false, nil},
+		{"plain", false, true, nil},
+		{"no-color-env", false, false, map[string]string{"NO_COLOR": "1"}},
+		// tests don't run on a terminal, so colors are never used
+		{"not-a-terminal", false, false, map[string]string{"NO_COLOR": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := func(key string) (string, bool) {
+				value, ok := tt.env[key]
+				return value, ok
+			}
+
+			if useColor(tt.noColor, tt.plain, lookup) {
+				t.Errorf("useColor() = true, want false")
+			}
+		})
+	}
+}
+
+func Test_colorize(t *testing.T) {
+	defer func() { colorMode = false }()
+
+	if got := colorize(deleteColor, "Removing:"); got != "Removing:" {
+		t.Errorf("colorize() = %q without color mode, want it as is", got)
+	}
+
+	colorMode = true
+	if got, want := colorize(deleteColor, "Removing:"), "\x1b[31mRemoving:\x1b[0m"; got != want {
+		t.Errorf("colorize() = %q, want %q", got, want)
+	}
+}
+
+func Test_levelColorWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   string
+	}{
+		{"info", "time=x level=INFO msg=started\n", "time=x level=INFO msg=started\n"},
+		{"warn", "time=x level=WARN msg=skipped\n", "\x1b[33mtime=x level=WARN msg=skipped\x1b[0m\n"},
+		{"error", "time=x level=ERROR msg=failed\n", "\x1b[1m\x1b[31mtime=x level=ERROR msg=failed\x1b[0m\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			n, err := levelColorWriter{&buf}.Write([]byte(tt.record))
+			if err != nil || n != len(tt.record) {
+				t.Fatalf("Write() = %d, %v", n, err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("Write() wrote %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
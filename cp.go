@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// contentIndex finds files with a given content among the files known to it, hashing them lazily
+type contentIndex struct {
+	newHash func() hash.Hash
+	bySize  map[int64][]string
+	hashes  map[string]string
+}
+
+// newContentIndex creates an index of the files found under root
+func newContentIndex(root string, newHash func() hash.Hash) (*contentIndex, error) {
+	idx := &contentIndex{newHash, map[int64][]string{}, map[string]string{}}
+
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return idx, nil
+	}
+
+	bySize, err := getAllFileSizes([]string{root}, walkFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	idx.bySize = bySize
+
+	return idx, nil
+}
+
+// add makes a file known to the index
+func (idx *contentIndex) add(path string, size int64) {
+	idx.bySize[size] = append(idx.bySize[size], path)
+}
+
+// find returns a file known to the index with the same content as path, or an empty string if there is none
+func (idx *contentIndex) find(path string, size int64) (string, error) {
+	candidates := idx.bySize[size]
+	if len(candidates) == 0 {
+		return "", nil
+	}
+
+	sum, err := hashFile(path, idx.newHash)
+	if err != nil {
+		return "", err
+	}
+
+	for _, candidate := range candidates {
+		candidateSum, ok := idx.hashes[candidate]
+		if !ok {
+			candidateSum, err = hashFile(candidate, idx.newHash)
+			if err != nil {
+				fmt.Printf("can't hash file: %s, err %v\n", candidate, err)
+				continue
+			}
+
+			idx.hashes[candidate] = candidateSum
+		}
+
+		if candidateSum == sum {
+			return candidate, nil
+		}
+	}
+
+	return "", nil
+}
+
+// hashFile calculates the hash of the whole content of a file
+func hashFile(path string, newHash func() hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyStats sums up what a dedup-aware copy did
+type copyStats struct {
+	copied, skipped, linked, fails int
+	conflicts                      int
+	copiedBytes, avoidedBytes      int64
+}
+
+// copyTree implements the cp command which copies a tree, skipping or linking files already present at the destination
+func copyTree(args []string) error {
+	var (
+		link, dryRun bool
+		hashName     string
+	)
+
+	fs := flag.NewFlagSet("cp", flag.ExitOnError)
+	fs.BoolVar(&link, "link", false, "hard link files whose content is already present at the destination instead of skipping them")
+	fs.BoolVar(&dryRun, "dry-run", false, "dry run, nothing will be copied")
+	fs.StringVar(&hashName, "hash", "sha256", "hash algorithm used to compare files")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder cp [-link] [-dry-run] [-hash <s>] <src> <dst>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("a source and a destination are expected")
+	}
+
+	newHash, ok := hashers[hashName]
+	if !ok {
+		return fmt.Errorf("unknown hash algorithm: %s", hashName)
+	}
+
+	src, dst := fs.Arg(0), fs.Arg(1)
+
+	idx, err := newContentIndex(dst, newHash)
+	if err != nil {
+		return err
+	}
+
+	var stats copyStats
+
+	err = filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if fi.IsDir() {
+			if dryRun {
+				return nil
+			}
+
+			return os.MkdirAll(target, 0755)
+		}
+
+		if !fi.Mode().IsRegular() {
+			fmt.Printf("Skipping: %s (not a regular file)\n", path)
+			return nil
+		}
+
+		copyOne(path, target, fi.Size(), idx, link, dryRun, &stats)
+
+		return nil
+	})
+
+	fmt.Printf("\n%d file(s) copied (%d bytes), %d skipped and %d linked as already present (%d bytes avoided), %d conflicting, %d failed\n",
+		stats.copied, stats.copiedBytes, stats.skipped, stats.linked, stats.avoidedBytes, stats.conflicts, stats.fails)
+
+	return err
+}
+
+// copyOne copies a single file unless its content is already present at the destination
+// A file already at the target with a different content is a conflict, it's left alone and the file isn't copied.
+func copyOne(path, target string, size int64, idx *contentIndex, link, dryRun bool, stats *copyStats) {
+	if exists(target) {
+		same, err := sameContent(path, target)
+		switch {
+		case err != nil:
+			fmt.Printf("can't compare file: %s, err %v\n", path, err)
+			stats.fails++
+		case !same:
+			fmt.Printf("Conflict: %s (%s exists with a different content)\n", path, target)
+			stats.conflicts++
+		default:
+			fmt.Printf("Skipping: %s (%s exists)\n", path, target)
+			stats.skipped++
+			stats.avoidedBytes += size
+		}
+
+		return
+	}
+
+	existing, err := idx.find(path, size)
+	if err != nil {
+		fmt.Printf("can't hash file: %s, err %v\n", path, err)
+		stats.fails++
+		return
+	}
+
+	if existing != "" && link {
+		fmt.Printf("Linking: %s => %s\n", target, existing)
+
+		if dryRun || os.Link(existing, target) == nil {
+			stats.linked++
+			stats.avoidedBytes += size
+			return
+		}
+
+		fmt.Printf("Linking failed, copying instead\n")
+	} else if existing != "" {
+		fmt.Printf("Skipping: %s (already at %s)\n", path, existing)
+		stats.skipped++
+		stats.avoidedBytes += size
+		return
+	}
+
+	fmt.Printf("Copying: %s => %s\n", path, target)
+
+	if !dryRun {
+		if err := copyFile(path, target); err != nil {
+			fmt.Printf("%v\n", err)
+			stats.fails++
+			return
+		}
+	}
+
+	if dryRun {
+		// the target doesn't exist in a dry run, so the source stands in for it
+		target = path
+	}
+	idx.add(target, size)
+
+	stats.copied++
+	stats.copiedBytes += size
+}
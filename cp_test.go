@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_copyOne(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-cp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	for path, content := range map[string]string{
+		filepath.Join(src, "a"):   "aaa",
+		filepath.Join(src, "b"):   "bbb",
+		filepath.Join(src, "c"):   "bbb",
+		filepath.Join(src, "d"):   "ddd",
+		filepath.Join(src, "e"):   "eee",
+		filepath.Join(dst, "old"): "aaa",
+		filepath.Join(dst, "d"):   "DDD",
+		filepath.Join(dst, "e"):   "eee",
+	} {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx, err := newContentIndex(dst, sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats copyStats
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		copyOne(filepath.Join(src, name), filepath.Join(dst, name), 3, idx, false, false, &stats)
+	}
+
+	want := copyStats{copied: 1, skipped: 3, conflicts: 1, copiedBytes: 3, avoidedBytes: 9}
+	if stats != want {
+		t.Errorf("copyOne() stats = %+v, want %+v", stats, want)
+	}
+	if exists(filepath.Join(dst, "a")) || !exists(filepath.Join(dst, "b")) || exists(filepath.Join(dst, "c")) {
+		t.Errorf("copyOne() copied the wrong files")
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dst, "d")); string(content) != "DDD" {
+		t.Errorf("copyOne() overwrote the conflicting file with %q", content)
+	}
+}
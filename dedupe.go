@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+)
+
+const dedupeChunkSize = 16 * 1024 * 1024
+
+// dedupeFiles asks the kernel to share the extents of keep with each of files, unless dryRun is set
+// The kernel compares the content itself and refuses to share anything that differs.
+func dedupeFiles(keep string, files []string, dryRun bool) {
+	for _, file := range files {
+		if dryRun {
+			fmt.Printf("Deduplicating: %s => %s (skipped)\n", file, keep)
+			continue
+		}
+
+		fmt.Printf("Deduplicating: %s => %s\n", file, keep)
+
+		if err := dedupeFile(keep, file); err != nil {
+			fmt.Printf("%v\n", err)
+		} else {
+			fmt.Println("done.")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const dedupeSupported = true
+
+// dedupeFile shares the extents of src with dst using the FIDEDUPERANGE ioctl (Btrfs, XFS)
+func dedupeFile(src, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	fi, err := s.Stat()
+	if err != nil {
+		return err
+	}
+
+	// the destination has to be writable, unless it's owned by the user
+	d, err := os.OpenFile(dst, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrPermission) {
+		d, err = os.Open(dst)
+	}
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	size := uint64(fi.Size())
+	for offset := uint64(0); offset < size; {
+		length := size - offset
+		if length > dedupeChunkSize {
+			length = dedupeChunkSize
+		}
+
+		r := &unix.FileDedupeRange{
+			Src_offset: offset,
+			Src_length: length,
+			Info: []unix.FileDedupeRangeInfo{
+				{Dest_fd: int64(d.Fd()), Dest_offset: offset},
+			},
+		}
+
+		if err := unix.IoctlFileDedupeRange(int(s.Fd()), r); err != nil {
+			return fmt.Errorf("dedupe %s %s: %v", src, dst, err)
+		}
+
+		info := r.Info[0]
+		switch {
+		case info.Status == unix.FILE_DEDUPE_RANGE_DIFFERS:
+			return fmt.Errorf("dedupe %s %s: content differs at offset %d", src, dst, offset)
+		case info.Status < 0:
+			return fmt.Errorf("dedupe %s %s: %v", src, dst, syscall.Errno(-info.Status))
+		case info.Bytes_deduped == 0:
+			return fmt.Errorf("dedupe %s %s: no progress at offset %d", src, dst, offset)
+		}
+
+		offset += info.Bytes_deduped
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+)
+
+const dedupeSupported = false
+
+// dedupeFile is only supported on Linux
+func dedupeFile(src, dst string) error {
+	return errors.New("dedupe is only supported on Linux")
+}
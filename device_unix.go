@@ -0,0 +1,69 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// deviceID returns the identifier of the device a file resides on
+func deviceID(path string) (uint64, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, nil
+	}
+
+	return uint64(st.Dev), nil
+}
+
+// syncDir flushes a directory entry to disk, so that removals in it are persisted
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// linkCount returns the number of hard links pointing to a file
+func linkCount(path string) (uint64, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 1, nil
+	}
+
+	return uint64(st.Nlink), nil
+}
+
+// fileIdentity returns the device and inode of a file, which are shared by hard links
+func fileIdentity(path string) (fileID, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return fileID{}, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, errNoFileID
+	}
+
+	return fileID{uint64(st.Dev), uint64(st.Ino)}, nil
+}
@@ -0,0 +1,64 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"syscall"
+)
+
+// deviceID returns the serial number of the volume a file resides on
+func deviceID(path string) (uint64, error) {
+	info, err := fileInformation(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(info.VolumeSerialNumber), nil
+}
+
+// linkCount returns the number of hard links pointing to a file
+func linkCount(path string) (uint64, error) {
+	info, err := fileInformation(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(info.NumberOfLinks), nil
+}
+
+// fileInformation returns the information Windows keeps about a file without following reparse points
+func fileInformation(path string) (*syscall.ByHandleFileInformation, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := syscall.CreateFile(p, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(h)
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// syncDir is a no-op on Windows, directory handles can not be flushed there
+func syncDir(dir string) error {
+	return nil
+}
+
+// fileIdentity returns the volume and file index of a file, which are shared by hard links
+func fileIdentity(path string) (fileID, error) {
+	info, err := fileInformation(path)
+	if err != nil {
+		return fileID{}, err
+	}
+
+	return fileID{uint64(info.VolumeSerialNumber), uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow)}, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+const recentLogSize = 200
+
+var (
+	startedAt = time.Now()
+	recentLog = &logRing{max: recentLogSize}
+)
+
+// logRing keeps the last lines written to it, so they can be included in a diagnostics bundle
+type logRing struct {
+	mu    sync.Mutex
+	max   int
+	lines []string
+}
+
+// Write stores each line of p, dropping the oldest lines once max is reached
+func (r *logRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		r.lines = append(r.lines, line)
+	}
+
+	if len(r.lines) > r.max {
+		r.lines = r.lines[len(r.lines)-r.max:]
+	}
+
+	return len(p), nil
+}
+
+// Lines returns a copy of the lines stored
+func (r *logRing) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.lines...)
+}
+
+// setupDiagnostics makes the standard logger also write into the ring buffer of recent log lines
+func setupDiagnostics() {
+	log.SetOutput(io.MultiWriter(os.Stderr, recentLog))
+}
+
+// recoverPanic must be deferred at the top of each goroutine, it turns a panic into a diagnostics bundle and exits
+func recoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := debug.Stack()
+
+	path, err := writeDiagnostics(r, stack)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dblfinder crashed: %v\n%s\nfailed writing diagnostics: %v\n", r, stack, err)
+		os.Exit(exitError)
+	}
+
+	fmt.Fprintf(os.Stderr, "dblfinder crashed: %v\ndiagnostics written to: %s\n", r, path)
+	os.Exit(exitError)
+}
+
+// writeDiagnostics writes the panic, the run manifest, all goroutine stacks and the recent log lines to a temp file
+func writeDiagnostics(r interface{}, stack []byte) (string, error) {
+	f, err := ioutil.TempFile("", "dblfinder-crash-*.txt")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(diagnosticsBundle(r, stack))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return f.Name(), err
+}
+
+// diagnosticsBundle renders the content of a diagnostics file
+func diagnosticsBundle(r interface{}, stack []byte) []byte {
+	var b bytes.Buffer
+
+	wd, _ := os.Getwd()
+
+	fmt.Fprintf(&b, "panic: %v\n\n", r)
+	fmt.Fprintf(&b, "== run manifest ==\n")
+	fmt.Fprintf(&b, "version: %s\n", version)
+	fmt.Fprintf(&b, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "args: %q\n", os.Args)
+	fmt.Fprintf(&b, "working directory: %s\n", wd)
+	fmt.Fprintf(&b, "started: %s\n", startedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "crashed: %s (after %s)\n\n", time.Now().Format(time.RFC3339), time.Since(startedAt))
+	fmt.Fprintf(&b, "== panicking goroutine ==\n%s\n", stack)
+
+	all := make([]byte, 1<<20)
+	all = all[:runtime.Stack(all, true)]
+	fmt.Fprintf(&b, "== all goroutines ==\n%s\n\n", all)
+
+	fmt.Fprintf(&b, "== recent log ==\n")
+	for _, line := range recentLog.Lines() {
+		fmt.Fprintln(&b, line)
+	}
+
+	return b.Bytes()
+}
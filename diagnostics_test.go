@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_logRing(t *testing.T) {
+	type args struct {
+		writes []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			"splits-lines",
+			args{
+				[]string{"one\ntwo\n"},
+			},
+			[]string{"one", "two"},
+		},
+		{
+			"drops-oldest",
+			args{
+				[]string{"one\n", "two\n", "three\n", "four\n"},
+			},
+			[]string{"two", "three", "four"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &logRing{max: 3}
+			for _, w := range tt.args.writes {
+				r.Write([]byte(w))
+			}
+
+			if got := r.Lines(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("logRing.Lines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
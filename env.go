@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables mirroring the flags
+const envPrefix = "DBLFINDER_"
+
+// envName returns the environment variable mirroring a flag, eg. DBLFINDER_SKIP_HIDDEN for -skip-hidden
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// givenFlags returns the names of the flags set so far, on the command line or by applyEnv
+func givenFlags(fs *flag.FlagSet) map[string]bool {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	return given
+}
+
+// applyEnv sets the flags not given on the command line from their environment variables
+// Flags given on the command line take precedence. Repeatable flags take a single value from the environment.
+func applyEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	given := givenFlags(fs)
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] {
+			return
+		}
+
+		value, ok := lookup(envName(f.Name))
+		if !ok {
+			return
+		}
+
+		if serr := fs.Set(f.Name, value); serr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, envName(f.Name), serr)
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_applyEnv(t *testing.T) {
+	var (
+		skipHidden, dryRun bool
+		action             string
+		limit              int
+		prefer             listFlag
+	)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.BoolVar(&skipHidden, "skip-hidden", false, "")
+	fs.BoolVar(&dryRun, "dry-run", false, "")
+	fs.StringVar(&action, "action", "list", "")
+	fs.IntVar(&limit, "fs-limit", 10, "")
+	fs.Var(&prefer, "prefer", "")
+
+	if err := fs.Parse([]string{"-action", "trash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"DBLFINDER_SKIP_HIDDEN": "true",
+		"DBLFINDER_ACTION":      "delete",
+		"DBLFINDER_FS_LIMIT":    "3",
+		"DBLFINDER_PREFER":      "^/originals/",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	if err := applyEnv(fs, lookup); err != nil {
+		t.Fatal(err)
+	}
+
+	if !skipHidden || dryRun || action != "trash" || limit != 3 || !reflect.DeepEqual(prefer, listFlag{"^/originals/"}) {
+		t.Errorf("applyEnv() skipHidden = %v, dryRun = %v, action = %v, limit = %v, prefer = %v", skipHidden, dryRun, action, limit, prefer)
+	}
+
+	env["DBLFINDER_DRY_RUN"] = "maybe"
+	if err := applyEnv(fs, lookup); err == nil || !strings.Contains(err.Error(), "DBLFINDER_DRY_RUN") {
+		t.Errorf("applyEnv() error = %v, want an error naming DBLFINDER_DRY_RUN", err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// exit codes of a run, see setExitCode
+const (
+	exitOK         = 0
+	exitDuplicates = 1
+	exitError      = 2
+)
+
+// exitCode is the code the run exits with once done
+var exitCode = exitOK
+
+// setExitCode sets the code the run exits with, errors take precedence over duplicates found
+func setExitCode(code int) {
+	if code == exitError {
+		atomic.AddInt64(&metrics.errors, 1)
+	}
+
+	if code > exitCode {
+		exitCode = code
+	}
+}
+
+// exitWithCode exits with the code set during the run, it must be deferred first so that it runs after all the other
+// deferred calls of main
+func exitWithCode() {
+	if exitCode != exitOK {
+		os.Exit(exitCode)
+	}
+}
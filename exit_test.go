@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_setExitCode(t *testing.T) {
+	defer func() { exitCode = exitOK }()
+
+	tests := []struct {
+		name string
+		code int
+		want int
+	}{
+		{"ok", exitOK, exitOK},
+		{"duplicates", exitDuplicates, exitDuplicates},
+		{"error", exitError, exitError},
+		{"error-takes-precedence", exitDuplicates, exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setExitCode(tt.code)
+
+			if exitCode != tt.want {
+				t.Errorf("setExitCode() exit code = %d, want %d", exitCode, tt.want)
+			}
+		})
+	}
+}
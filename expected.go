@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// expectedFile is the format of the expected duplicates manifest
+// Pairs are globs (or regexps prefixed with re:) matched against paths relative to their root, a group is expected
+// if each of its files matches one side of a pair and both sides are matched. SHA256 lists content which may be
+// duplicated anywhere.
+type expectedFile struct {
+	Pairs  [][2]string `json:"pairs"`
+	SHA256 []string    `json:"sha256"`
+}
+
+// expectedDuplicates describes duplication which is intentional, so it's not reported
+type expectedDuplicates struct {
+	pairs  [][2]pathPattern
+	hashes map[string]bool
+}
+
+// loadExpected reads an expected duplicates manifest
+func loadExpected(path string) (expectedDuplicates, error) {
+	var e expectedDuplicates
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+
+	var f expectedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return e, fmt.Errorf("can't parse %s: %v", path, err)
+	}
+
+	for _, pair := range f.Pairs {
+		patterns, err := parsePathPatterns(pair[:])
+		if err != nil {
+			return e, err
+		}
+
+		e.pairs = append(e.pairs, [2]pathPattern{patterns[0], patterns[1]})
+	}
+
+	e.hashes = map[string]bool{}
+	for _, sum := range f.SHA256 {
+		e.hashes[strings.ToLower(sum)] = true
+	}
+
+	return e, nil
+}
+
+// expected returns true if the duplication of a group of identical files is described by the manifest
+func (e expectedDuplicates) expected(files []string, roots []string) bool {
+	if len(files) == 0 {
+		return false
+	}
+
+	var rels []string
+	for _, file := range files {
+		rels = append(rels, relToRoot(file, roots))
+	}
+
+	for _, pair := range e.pairs {
+		if matchPair(pair, rels) {
+			return true
+		}
+	}
+
+	if len(e.hashes) == 0 {
+		return false
+	}
+
+	sum, err := hashFile(files[0], sha256.New)
+	if err != nil {
+		fmt.Printf("can't hash file: %s, err %v\n", files[0], err)
+		return false
+	}
+
+	return e.hashes[sum]
+}
+
+// matchPair returns true if each path matches a side of the pair and both sides are matched
+func matchPair(pair [2]pathPattern, paths []string) bool {
+	var left, right bool
+	for _, path := range paths {
+		switch {
+		case pair[0].match(path):
+			left = true
+		case pair[1].match(path):
+			right = true
+		default:
+			return false
+		}
+	}
+
+	return left && right
+}
+
+// relToRoot returns the path of a file relative to the first root containing it, or the path itself if none does
+func relToRoot(file string, roots []string) string {
+	i, err := rootIndex(file, roots)
+	if err != nil || i == len(roots) {
+		return file
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+
+	root, err := filepath.Abs(roots[i])
+	if err != nil {
+		return file
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return file
+	}
+
+	return rel
+}
+
+// withoutExpected drops the groups whose duplication is expected
+func withoutExpected(groups [][]string, e expectedDuplicates, roots []string) [][]string {
+	var res [][]string
+	for _, files := range groups {
+		if !e.expected(files, roots) {
+			res = append(res, files)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_withoutExpected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-expected")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+
+	for name, content := range map[string]string{
+		"release/app.tar.gz":  "app",
+		"mirror/app.tar.gz":   "app",
+		"release/notes.txt":   "notes",
+		"docs/notes.txt":      "notes",
+		"assets/logo.png":     "logo",
+		"assets/old/logo.png": "logo",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path(name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest := fmt.Sprintf(`{"pairs": [["release/*.tar.gz", "mirror/*.tar.gz"]], "sha256": ["%X"]}`, sha256.Sum256([]byte("logo")))
+	if err := ioutil.WriteFile(path("expected.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := loadExpected(path("expected.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := [][]string{
+		{path("release/app.tar.gz"), path("mirror/app.tar.gz")},
+		{path("release/notes.txt"), path("docs/notes.txt")},
+		{path("assets/logo.png"), path("assets/old/logo.png")},
+	}
+
+	got := withoutExpected(groups, expected, []string{dir})
+	if want := groups[1:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("withoutExpected() = %v, want %v", got, want)
+	}
+}
+
+func Test_matchPair(t *testing.T) {
+	patterns, err := parsePathPatterns([]string{"a/*", "b/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair := [2]pathPattern{patterns[0], patterns[1]}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  bool
+	}{
+		{"both-sides", []string{"a/x", "b/x", "b/y"}, true},
+		{"one-side-only", []string{"a/x", "a/y"}, false},
+		{"unmatched-file", []string{"a/x", "b/x", "c/x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			for _, path := range tt.paths {
+				paths = append(paths, filepath.FromSlash(path))
+			}
+			if got := matchPair(pair, paths); got != tt.want {
+				t.Errorf("matchPair() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
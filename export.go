@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sqliteExport is the scheme of -export writing the results into a SQLite database
+const sqliteExport = "sqlite"
+
+// exportSchemes lists the kinds of -export targets
+var exportSchemes = []string{sqliteExport}
+
+// sqliteSchema creates the tables of a results database, replacing the results of an earlier export
+const sqliteSchema = `DROP TABLE IF EXISTS files;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS runs;
+CREATE TABLE runs (version TEXT, finished TEXT, roots TEXT, action TEXT, hash_algorithm TEXT, sample_size INTEGER);
+CREATE TABLE groups (id INTEGER PRIMARY KEY, size INTEGER, hash TEXT, reclaimable INTEGER);
+CREATE TABLE files (path TEXT, group_id INTEGER REFERENCES groups(id), dir TEXT, ext TEXT, size INTEGER, mod_time TEXT, hash TEXT, action TEXT);
+CREATE INDEX files_group ON files (group_id);
+`
+
+// parseExport splits an -export target into its scheme and path, eg. sqlite:dups.db
+func parseExport(export string) (string, string, error) {
+	parts := strings.SplitN(export, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid export target: %s, expected eg. %s:dups.db", export, sqliteExport)
+	}
+
+	for _, scheme := range exportSchemes {
+		if parts[0] == scheme {
+			return parts[0], parts[1], nil
+		}
+	}
+
+	return "", "", unknownValue("export scheme", parts[0], exportSchemes)
+}
+
+// exportSQLite writes the duplicate groups and the actions taken into a SQLite database
+// The database is written by the sqlite3 command line tool, which keeps the module free of a database driver.
+func exportSQLite(db string, groups []reportGroup, decisions []decision, opts options) error {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return fmt.Errorf("exporting to SQLite requires the sqlite3 command line tool: %v", err)
+	}
+
+	var script bytes.Buffer
+	if err := writeSQLiteScript(&script, groups, decisions, opts); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sqlite3", "-bail", db)
+	cmd.Stdin = &script
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sqlite3 failed: %v %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// writeSQLiteScript writes the SQL statements creating the results database in a single transaction
+func writeSQLiteScript(w io.Writer, groups []reportGroup, decisions []decision, opts options) error {
+	bw := bufio.NewWriter(w)
+	actions := fileActions(groups, decisions, opts.action)
+
+	bw.WriteString("BEGIN;\n")
+	bw.WriteString(sqliteSchema)
+
+	fmt.Fprintf(bw, "INSERT INTO runs VALUES (%s, %s, %s, %s, %s, %d);\n", sqlQuote(version), sqlQuote(time.Now().Format(time.RFC3339)),
+		sqlQuote(strings.Join(opts.roots, string(filepath.ListSeparator))), sqlQuote(string(opts.action)), sqlQuote(opts.hashName), opts.sampleSize)
+
+	for i, g := range groups {
+		fmt.Fprintf(bw, "INSERT INTO groups VALUES (%d, %d, %s, %d);\n", i+1, g.Size, sqlQuote(g.Hash), g.Reclaimable)
+
+		for _, f := range g.Files {
+			fmt.Fprintf(bw, "INSERT INTO files VALUES (%s, %d, %s, %s, %d, %s, %s, %s);\n", sqlQuote(f.Path), i+1,
+				sqlQuote(filepath.Dir(f.Path)), sqlQuote(extension(f.Path)), f.Size, sqlQuote(f.ModTime.Format(time.RFC3339)),
+				sqlQuote(f.Hash), sqlQuote(actions[f.Path]))
+		}
+	}
+
+	bw.WriteString("COMMIT;\n")
+
+	return bw.Flush()
+}
+
+// sqlQuote returns a string as an SQL string literal
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
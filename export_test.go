@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_parseExport(t *testing.T) {
+	tests := []struct {
+		name    string
+		export  string
+		want    string
+		wantErr bool
+	}{
+		{"sqlite", "sqlite:dups.db", "dups.db", false},
+		{"windows-path", `sqlite:C:\dups.db`, `C:\dups.db`, false},
+		{"no-path", "sqlite:", "", true},
+		{"unknown-scheme", "sqlit:dups.db", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := parseExport(tt.export)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("parseExport() = %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_exportSQLite(t *testing.T) {
+	groups := []reportGroup{
+		{Size: 4, Hash: "01", Reclaimable: 4, Files: []reportFile{{Path: "/a/it's.jpg", Size: 4, Hash: "01"}, {Path: "/b/c.jpg", Size: 4, Hash: "01"}}},
+	}
+	decisions := []decision{{"/b/c.jpg", []string{"/a/it's.jpg"}, manualReason}}
+	opts := options{action: trashAction}
+
+	var script bytes.Buffer
+	if err := writeSQLiteScript(&script, groups, decisions, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script.String(), "'/a/it''s.jpg', 1, '/a', 'jpg', 4") {
+		t.Errorf("writeSQLiteScript() = %s", script.String())
+	}
+
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := filepath.Join(dir, "dups.db")
+	for i := 0; i < 2; i++ {
+		if err := exportSQLite(db, groups, decisions, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command("sqlite3", db, "SELECT path, action FROM files ORDER BY path").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/a/it's.jpg|trash\n/b/c.jpg|keep\n"; string(out) != want {
+		t.Errorf("exported files = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// sniffStage is the stage files are opened in to detect their type from their content
+const sniffStage = "sniffing"
+
+// fdStats holds the number of files of a stage open at the moment, at most at once, and in total
+type fdStats struct {
+	open, peak, total int
+}
+
+// fdTracker accounts for the files opened while scanning, to check that -fs-limit is honoured and nothing leaks
+type fdTracker struct {
+	mu         sync.Mutex
+	stages     map[string]*fdStats
+	open, peak int
+}
+
+// fds tracks the files opened by the current run
+var fds = newFDTracker()
+
+func newFDTracker() *fdTracker {
+	return &fdTracker{stages: map[string]*fdStats{}}
+}
+
+// openFile opens a file for reading and accounts for it in a stage, it must be closed by closeFile
+func (t *fdTracker) openFile(stage, path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.stages[stage]
+	if !ok {
+		s = &fdStats{}
+		t.stages[stage] = s
+	}
+
+	s.open++
+	s.total++
+	if s.open > s.peak {
+		s.peak = s.open
+	}
+
+	t.open++
+	if t.open > t.peak {
+		t.peak = t.open
+	}
+
+	return f, nil
+}
+
+// closeFile closes a file opened by openFile
+func (t *fdTracker) closeFile(stage string, f *os.File) error {
+	t.mu.Lock()
+	t.stages[stage].open--
+	t.open--
+	t.mu.Unlock()
+
+	return f.Close()
+}
+
+// report prints the peak number of open files per stage, and the files left open
+// A warning is printed if more files were open at once than the limit.
+func (t *fdTracker) report(limit int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Println("Open files:")
+	for _, name := range append(stageOrder, sniffStage) {
+		s, ok := t.stages[name]
+		if !ok {
+			continue
+		}
+
+		fmt.Printf("  %-13s peak %d, %d opened in total", name, s.peak, s.total)
+		if s.open > 0 {
+			fmt.Printf(", %d LEAKED", s.open)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("  overall peak %d (-fs-limit %d)\n", t.peak, limit)
+	if t.peak > limit {
+		fmt.Printf("WARNING: more files were open at once than -fs-limit allows\n")
+	}
+}
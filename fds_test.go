@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_fdTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var files []string
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("f%d", i))
+		if err := ioutil.WriteFile(path, []byte("same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	saved := fds
+	defer func() { fds = saved }()
+	fds = newFDTracker()
+
+	if got := getUniqueHashes(files, 3, 0, []string{sampleHead}, hashers[defaultHash], false); len(got) != 1 {
+		t.Fatalf("getUniqueHashes() = %v, want a single hash", got)
+	}
+
+	s := fds.stages[sampleStage]
+	if s.total != len(files) || s.open != 0 || s.peak > 3 || fds.peak > 3 {
+		t.Errorf("fdTracker counted %+v, overall peak %d, want %d files opened at most 3 at once and none left open", *s, fds.peak, len(files))
+	}
+
+	f, err := fds.openFile(verifyStage, files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if fds.stages[verifyStage].open != 1 {
+		t.Errorf("fdTracker didn't count a file left open")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// walkFilter decides which files found while walking the roots are considered at all
+type walkFilter struct {
+	ignore        *regexp.Regexp
+	includeExt    map[string]bool
+	excludeExt    map[string]bool
+	include       []pathPattern
+	exclude       []pathPattern
+	gitignore     bool
+	skipHidden    bool
+	oneFileSystem bool
+	skipEmpty     bool
+	hydrate       bool
+	maxDepth      int
+}
+
+// inodeInlineSize is the size up to which file systems may store the content of files in their inode, without
+// allocating blocks for them
+const inodeInlineSize = 4096
+
+// newWalkFilter creates a walk filter from an ignore regexp, comma separated extension lists and include / exclude
+// patterns, which are globs or regexps prefixed with "re:"
+func newWalkFilter(ignore, includeExt, excludeExt string, include, exclude []string) (walkFilter, error) {
+	var (
+		f   walkFilter
+		err error
+	)
+
+	if ignore != "" {
+		if f.ignore, err = regexp.Compile(ignore); err != nil {
+			return f, fmt.Errorf("invalid ignore regexp: %s, err %v", ignore, err)
+		}
+	}
+
+	f.includeExt = parseExtensions(includeExt)
+	f.excludeExt = parseExtensions(excludeExt)
+
+	if f.include, err = parsePathPatterns(include); err != nil {
+		return f, err
+	}
+
+	if f.exclude, err = parsePathPatterns(exclude); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// parseExtensions parses a comma separated list of extensions, with or without their leading dot
+func parseExtensions(list string) map[string]bool {
+	if list == "" {
+		return nil
+	}
+
+	res := map[string]bool{}
+	for _, ext := range strings.Split(list, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			res[normalizeExt(ext)] = true
+		}
+	}
+
+	return res
+}
+
+// extension returns the normalized lowercase extension of a file without its leading dot
+func extension(path string) string {
+	return normalizeExt(strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")))
+}
+
+// skipFile returns true if a file is to be left out
+// A file is kept if it matches every include rule given and none of the ignore and exclude rules.
+func (f walkFilter) skipFile(path string) bool {
+	if f.ignore != nil && f.ignore.MatchString(path) {
+		return true
+	}
+
+	if f.includeExt != nil && !f.includeExt[extension(path)] {
+		return true
+	}
+
+	if f.excludeExt[extension(path)] {
+		return true
+	}
+
+	if len(f.include) > 0 && !matchAny(f.include, path) {
+		return true
+	}
+
+	return matchAny(f.exclude, path)
+}
+
+// skipDir returns true if a directory matches an exclude pattern, so it is not walked at all
+// The .git directory is skipped too if .gitignore files are respected.
+func (f walkFilter) skipDir(path string) bool {
+	if f.gitignore && filepath.Base(path) == ".git" {
+		return true
+	}
+
+	return matchAny(f.exclude, path)
+}
+
+// hidden returns true if hidden files are skipped and a file is hidden, either by its name starting with a dot, or
+// by its hidden attribute on Windows
+func (f walkFilter) hidden(path string, fi os.FileInfo) bool {
+	if !f.skipHidden {
+		return false
+	}
+
+	name := filepath.Base(path)
+
+	return (strings.HasPrefix(name, ".") && name != "." && name != "..") || hiddenAttr(fi)
+}
+
+// empty returns true if empty files are skipped and a file is empty
+func (f walkFilter) empty(fi os.FileInfo) bool {
+	return f.skipEmpty && fi.Size() == 0
+}
+
+// placeholder returns true if a file is the placeholder of a file kept online by a cloud sync client, unless
+// placeholders are to be hydrated
+// Reading a placeholder downloads the file, which may be large, so placeholders are skipped by default.
+func (f walkFilter) placeholder(fi os.FileInfo) bool {
+	return !f.hydrate && placeholderAttr(fi)
+}
+
+// ignoreFiles returns the names of the per-directory ignore files to honour
+// .dblfinderignore files are always honoured, they are read after .gitignore files so their rules take precedence.
+func (f walkFilter) ignoreFiles() []string {
+	if f.gitignore {
+		return []string{gitignoreFile, dblfinderignoreFile}
+	}
+
+	return []string{dblfinderignoreFile}
+}
+
+// isRoot returns true if path is one of the roots being walked
+func isRoot(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readPatternFile reads patterns from a file, one per line, skipping blank lines and comments starting with #
+// Like in rsync exclude files, a leading "- " is allowed and a trailing / is dropped.
+func readPatternFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "- ")
+		if line != "/" {
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		res = append(res, line)
+	}
+
+	return res, nil
+}
+
+// depth returns how many levels below root a path is, files directly in root are at depth 1
+func depth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func Test_walkFilter_skipFile(t *testing.T) {
+	type args struct {
+		ignore, includeExt, excludeExt string
+		include, exclude               []string
+	}
+	tests := []struct {
+		name string
+		args args
+		path string
+		want bool
+	}{
+		{"no-rules", args{}, "/a/b.txt", false},
+		{"ignore-regexp", args{ignore: `\.txt$`}, "/a/b.txt", true},
+		{"include-ext-match", args{includeExt: "jpg, .PNG"}, "/a/b.png", false},
+		{"include-ext-miss", args{includeExt: "jpg,png"}, "/a/b.gif", true},
+		{"include-ext-no-ext", args{includeExt: "jpg"}, "/a/b", true},
+		{"exclude-ext", args{excludeExt: "tmp,log"}, "/a/b.LOG", true},
+		{"equivalent-ext", args{includeExt: "jpeg"}, "/a/b.JPG", false},
+		{"include-glob-match", args{include: []string{"IMG_*"}}, "/a/IMG_1.jpg", false},
+		{"include-glob-miss", args{include: []string{"IMG_*"}}, "/a/DSC_1.jpg", true},
+		{"exclude-glob", args{exclude: []string{"*~"}}, "/a/b.txt~", true},
+		{"exclude-wins-over-include", args{includeExt: "jpg", exclude: []string{"thumb_*"}}, "/a/thumb_1.jpg", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newWalkFilter(tt.args.ignore, tt.args.includeExt, tt.args.excludeExt, tt.args.include, tt.args.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.skipFile(filepath.FromSlash(tt.path)); got != tt.want {
+				t.Errorf("skipFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newWalkFilter(t *testing.T) {
+	if _, err := newWalkFilter("(", "", "", nil, nil); err == nil {
+		t.Errorf("newWalkFilter() expected an error for an invalid ignore regexp")
+	}
+	if _, err := newWalkFilter("", "", "", nil, []string{"["}); err == nil {
+		t.Errorf("newWalkFilter() expected an error for an invalid exclude glob")
+	}
+}
+
+func Test_readPatternFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-exclude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "excludes")
+	content := "# caches\n*.tmp\n\n  - node_modules/\nre:\\.bak$\r\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readPatternFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"*.tmp", "node_modules", `re:\.bak$`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readPatternFile() = %q, want %q", got, want)
+	}
+
+	if _, err := readPatternFile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("readPatternFile() expected an error for a missing file")
+	}
+}
+
+func Test_getAllFileSizes_skipHidden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-hidden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, ".root")
+	for _, name := range []string{"a.txt", ".b.txt", ".cache/c.txt", "sub/d.txt", "sub/.e"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileSizes, err := getAllFileSizes([]string{root}, walkFilter{skipHidden: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(root, "a.txt"), filepath.Join(root, "sub", "d.txt")}
+	if got := fileSizes[1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("getAllFileSizes() = %v, want %v", got, want)
+	}
+}
+
+func Test_getAllFileSizes_placeholders(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("placeholders are told by their attributes on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "dblfinder-placeholders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	empty, stub := filepath.Join(dir, "empty"), filepath.Join(dir, "stub")
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a file without any blocks allocated, the way sync clients present files kept online
+	if err := ioutil.WriteFile(stub, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(stub, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter walkFilter
+		want   int
+	}{
+		{"default", walkFilter{}, 1},
+		{"skip-empty", walkFilter{skipEmpty: true}, 0},
+		{"hydrate", walkFilter{hydrate: true}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileSizes, err := getAllFileSizes([]string{dir}, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(fileSizes[0]) + len(fileSizes[1<<20]); got != tt.want {
+				t.Errorf("getAllFileSizes() = %v, want %d file(s)", fileSizes, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getAllFileSizes_oneFileSystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-onefs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sub", "a.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileSizes, err := getAllFileSizes([]string{dir}, walkFilter{oneFileSystem: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fileSizes[1]; !reflect.DeepEqual(got, []string{path}) {
+		t.Errorf("getAllFileSizes() = %v, want %v", got, []string{path})
+	}
+}
+
+func Test_getAllFileSizes_maxDepth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-depth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a", "sub/b", "sub/deeper/c"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		maxDepth int
+		want     []string
+	}{
+		{0, []string{"a", "sub/b", "sub/deeper/c"}},
+		{1, []string{"a"}},
+		{2, []string{"a", "sub/b"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.maxDepth), func(t *testing.T) {
+			fileSizes, err := getAllFileSizes([]string{dir}, walkFilter{maxDepth: tt.maxDepth})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want []string
+			for _, name := range tt.want {
+				want = append(want, filepath.Join(dir, filepath.FromSlash(name)))
+			}
+			if got := fileSizes[1]; !reflect.DeepEqual(got, want) {
+				t.Errorf("getAllFileSizes() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -1,3 +1,12 @@
 module github.com/peteraba/dblfinder
 
-go 1.14
+go 1.21
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c
+	golang.org/x/term v0.0.0-20210615171337-6886f2dfbf5b
+	lukechampine.com/blake3 v1.1.7
+)
+
+require github.com/klauspost/cpuid/v2 v2.0.9 // indirect
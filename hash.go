@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"lukechampine.com/blake3"
+)
+
+const (
+	defaultHash     = "md5"
+	firstStageSize  = 4 * 1024
+	secondStageSize = 1024 * 1024
+	sampleHead      = "head"
+	sampleMiddle    = "middle"
+	sampleTail      = "tail"
+)
+
+// hashers contains the hash algorithms that can be selected via the hash flag
+var hashers = map[string]func() hash.Hash{}
+
+func init() {
+	registerHasher("md5", md5.New)
+	registerHasher("sha256", sha256.New)
+	registerHasher("xxhash64", func() hash.Hash { return xxhash.New() })
+	registerHasher("blake3", func() hash.Hash { return blake3.New(32, nil) })
+}
+
+// registerHasher makes a hash algorithm available under a name
+func registerHasher(name string, newHash func() hash.Hash) {
+	hashers[name] = newHash
+}
+
+// hasherNames returns the names of all registered hash algorithms in order
+func hasherNames() []string {
+	var res []string
+	for name := range hashers {
+		res = append(res, name)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// parseSampleStrategy parses a comma separated list of sample positions (head, middle, tail)
+func parseSampleStrategy(s string) ([]string, error) {
+	var res []string
+
+	for _, pos := range strings.Split(s, ",") {
+		pos = strings.TrimSpace(pos)
+
+		switch pos {
+		case sampleHead, sampleMiddle, sampleTail:
+		default:
+			return nil, fmt.Errorf("unknown sample position: %q, available: head, middle, tail", pos)
+		}
+
+		res = append(res, pos)
+	}
+
+	return uniqueStrings(res), nil
+}
+
+// sampleCoversFile returns true if sampling a file of the given size reads all of its content
+func sampleCoversFile(sampleSize int, strategy []string, size int64) bool {
+	return sampleSize == 0 || int64(sampleSize)*int64(len(strategy)) >= size
+}
+
+// sampleReader returns a reader over the sampled regions of a file, or the whole file if the sampled regions would
+// cover all of it anyway
+func sampleReader(r io.ReaderAt, size int64, sampleSize int, strategy []string) io.Reader {
+	if sampleCoversFile(sampleSize, strategy, size) {
+		return io.NewSectionReader(r, 0, size)
+	}
+
+	n := int64(sampleSize)
+
+	var readers []io.Reader
+	for _, pos := range strategy {
+		var offset int64
+
+		switch pos {
+		case sampleMiddle:
+			offset = (size - n) / 2
+		case sampleTail:
+			offset = size - n
+		}
+
+		readers = append(readers, io.NewSectionReader(r, offset, n))
+	}
+
+	return io.MultiReader(readers...)
+}
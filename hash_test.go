@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_hashers(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{
+			"md5",
+			"900150983cd24fb0d6963f7d28e17f72",
+		},
+		{
+			"sha256",
+			"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		},
+		{
+			"xxhash64",
+			"44bc2cf5ad770999",
+		},
+		{
+			"blake3",
+			"6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newHash, ok := hashers[tt.name]
+			if !ok {
+				t.Fatalf("hasher %s is not registered", tt.name)
+			}
+
+			h := newHash()
+			h.Write([]byte("abc"))
+
+			if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+				t.Errorf("%s(\"abc\") = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseSampleStrategy(t *testing.T) {
+	type args struct {
+		s string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+		ok   bool
+	}{
+		{
+			"head",
+			args{
+				"head",
+			},
+			[]string{"head"},
+			true,
+		},
+		{
+			"all-with-spaces-and-repeats",
+			args{
+				"tail, head,middle,tail",
+			},
+			[]string{"head", "middle", "tail"},
+			true,
+		},
+		{
+			"unknown",
+			args{
+				"head,end",
+			},
+			nil,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSampleStrategy(tt.args.s)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSampleStrategy() got = %v, want %v", got, tt.want)
+			}
+			if (err == nil) != tt.ok {
+				t.Errorf("parseSampleStrategy() err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_sampleReader(t *testing.T) {
+	content := "0123456789"
+
+	type args struct {
+		sampleSize int
+		strategy   []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"head",
+			args{
+				2,
+				[]string{"head"},
+			},
+			"01",
+		},
+		{
+			"head-middle-tail",
+			args{
+				2,
+				[]string{"head", "middle", "tail"},
+			},
+			"014589",
+		},
+		{
+			"covering-whole-file",
+			args{
+				4,
+				[]string{"head", "middle", "tail"},
+			},
+			content,
+		},
+		{
+			"full",
+			args{
+				0,
+				[]string{"tail"},
+			},
+			content,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := sampleReader(strings.NewReader(content), int64(len(content)), tt.args.sampleSize, tt.args.strategy)
+
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("sampleReader() = %v, want %v", string(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+)
+
+// hiddenAttr returns true if a file has the hidden attribute set, which only exists on Windows
+func hiddenAttr(fi os.FileInfo) bool {
+	return false
+}
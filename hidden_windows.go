@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// hiddenAttr returns true if a file has the hidden attribute set
+func hiddenAttr(fi os.FileInfo) bool {
+	data, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+
+	return data.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
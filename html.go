@@ -0,0 +1,140 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// dirWaste is the space taken by duplicates in a directory
+type dirWaste struct {
+	Dir   string
+	Files int
+	Waste int64
+}
+
+// htmlReport holds the data rendered into an HTML report
+type htmlReport struct {
+	report
+	Dirs      []dirWaste
+	Thumbs    []template.URL
+	Generated time.Time
+}
+
+// directoryWaste sums up the space taken by duplicates per directory, the most wasteful first
+// The first file of each group is considered the original, the rest are counted as waste, just like reclaimable bytes.
+func directoryWaste(groups []reportGroup) []dirWaste {
+	byDir := map[string]*dirWaste{}
+	for _, g := range groups {
+		for _, f := range g.Files[1:] {
+			dir := filepath.Dir(f.Path)
+
+			d, ok := byDir[dir]
+			if !ok {
+				d = &dirWaste{Dir: dir}
+				byDir[dir] = d
+			}
+
+			d.Files++
+			d.Waste += f.Size
+		}
+	}
+
+	var res []dirWaste
+	for _, d := range byDir {
+		res = append(res, *d)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Waste != res[j].Waste {
+			return res[i].Waste > res[j].Waste
+		}
+
+		return res[i].Dir < res[j].Dir
+	})
+
+	return res
+}
+
+// writeHTMLReport renders the duplicate groups, the waste per directory and the totals into a standalone HTML page
+// Groups of images and videos are shown with a thumbnail, embedded into the page from the thumbnail cache.
+func writeHTMLReport(w io.Writer, groups []reportGroup, opts options) error {
+	return htmlTemplate.Execute(w, htmlReport{newReport(groups, nil, opts), directoryWaste(groups), groupThumbnails(groups, opts.thumbs), time.Now()})
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+	"thumb": func(thumbs []template.URL, i int) template.URL {
+		if i < len(thumbs) {
+			return thumbs[i]
+		}
+
+		return ""
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Dblfinder report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #eee; cursor: pointer; }
+td.num { text-align: right; }
+tr.first td { border-top: 2px solid #888; }
+img.thumb { display: block; margin-top: 0.3em; }
+</style>
+</head>
+<body>
+<h1>Duplicates</h1>
+<p>Generated by dblfinder {{.Version}} on {{.Generated.Format "2006-01-02 15:04"}}, roots: {{range $i, $r := .Roots}}{{if $i}}, {{end}}{{$r}}{{end}}.</p>
+
+<h2>Totals</h2>
+<table>
+<tr><td>Groups</td><td class="num">{{.Summary.Groups}}</td></tr>
+<tr><td>Files</td><td class="num">{{.Summary.Files}}</td></tr>
+<tr><td>Duplicates</td><td class="num">{{.Summary.Duplicates}}</td></tr>
+<tr><td>Reclaimable bytes</td><td class="num">{{.Summary.Reclaimable}}</td></tr>
+</table>
+
+<h2>Waste per directory</h2>
+<table class="sortable">
+<thead><tr><th>Directory</th><th>Duplicates</th><th>Bytes</th></tr></thead>
+<tbody>
+{{range .Dirs}}<tr><td>{{.Dir}}</td><td class="num" data-value="{{.Files}}">{{.Files}}</td><td class="num" data-value="{{.Waste}}">{{.Waste}}</td></tr>
+{{end}}</tbody>
+</table>
+
+<h2>Groups</h2>
+<table class="sortable">
+<thead><tr><th>Group</th><th>Path</th><th>Size</th><th>Modified</th><th>Hash</th></tr></thead>
+<tbody>
+{{range $i, $g := .Groups}}{{range $j, $f := $g.Files}}<tr{{if not $j}} class="first"{{end}}><td class="num" data-value="{{inc $i}}">{{inc $i}}{{if not $j}}{{with thumb $.Thumbs $i}}<img class="thumb" src="{{.}}" alt="">{{end}}{{end}}</td><td>{{$f.Path}}</td><td class="num" data-value="{{$f.Size}}">{{$f.Size}}</td><td data-value="{{$f.ModTime.Unix}}">{{$f.ModTime.Format "2006-01-02 15:04"}}</td><td><code>{{$f.Hash}}</code></td></tr>
+{{end}}{{end}}</tbody>
+</table>
+
+<script>
+document.querySelectorAll("table.sortable").forEach(function (table) {
+  table.querySelectorAll("th").forEach(function (th, col) {
+    var asc = true;
+    th.addEventListener("click", function () {
+      var body = table.tBodies[0];
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[col], y = b.cells[col];
+        var vx = x.dataset.value, vy = y.dataset.value;
+        var c = vx !== undefined ? Number(vx) - Number(vy) : x.textContent.localeCompare(y.textContent);
+        return asc ? c : -c;
+      });
+      asc = !asc;
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_directoryWaste(t *testing.T) {
+	p := filepath.FromSlash
+	groups := []reportGroup{
+		{Files: []reportFile{{Path: p("/a/1"), Size: 10}, {Path: p("/b/1"), Size: 10}, {Path: p("/b/2"), Size: 10}}},
+		{Files: []reportFile{{Path: p("/b/3"), Size: 5}, {Path: p("/c/3"), Size: 5}}},
+	}
+
+	want := []dirWaste{{p("/b"), 2, 20}, {p("/c"), 1, 5}}
+	if got := directoryWaste(groups); !reflect.DeepEqual(got, want) {
+		t.Errorf("directoryWaste() = %v, want %v", got, want)
+	}
+}
+
+func Test_writeHTMLReport(t *testing.T) {
+	groups := []reportGroup{
+		{Size: 3, Reclaimable: 3, Files: []reportFile{{Path: "/a/<b>", Size: 3}, {Path: "/c/d", Size: 3}}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeHTMLReport(&buf, groups, options{roots: []string{"/"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "/a/&lt;b&gt;") || strings.Contains(out, "/a/<b>") {
+		t.Errorf("writeHTMLReport() didn't escape paths: %s", out)
+	}
+	if !strings.Contains(out, `<td class="num">3</td>`) {
+		t.Errorf("writeHTMLReport() didn't include the reclaimable bytes: %s", out)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// gitignoreFile is the name of the files listing the paths ignored by git
+	gitignoreFile = ".gitignore"
+	// dblfinderignoreFile is the name of the files listing the paths of a subtree never to be deduplicated
+	dblfinderignoreFile = ".dblfinderignore"
+)
+
+// ignoreRule is a single pattern of an ignore file following .gitignore semantics
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnoreRules parses the content of an ignore file, invalid patterns are skipped
+func parseIgnoreRules(content string) []ignoreRule {
+	var res []ignoreRule
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimRight(line, " ")
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		if line == "" {
+			continue
+		}
+
+		// patterns without a slash match at any depth, others are relative to the directory of the ignore file
+		prefix := "^(?:.*/)?"
+		if strings.Contains(line, "/") {
+			prefix = "^"
+			line = strings.TrimPrefix(line, "/")
+		}
+
+		re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+		if err != nil {
+			continue
+		}
+
+		rule.re = re
+		res = append(res, rule)
+	}
+
+	return res
+}
+
+// globToRegexp converts a gitignore glob into a regexp, ** matches across directories, other wildcards don't
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			sb.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return sb.String()
+}
+
+// ignoreTree holds the rules of the ignore files found in the directories walked so far
+type ignoreTree struct {
+	names []string
+	rules map[string][]ignoreRule
+}
+
+// newIgnoreTree creates an ignore tree honouring ignore files with the given names
+func newIgnoreTree(names []string) *ignoreTree {
+	return &ignoreTree{names, map[string][]ignoreRule{}}
+}
+
+// load reads the ignore files of a directory, it must be called for each directory before walking its content
+func (t *ignoreTree) load(dir string) {
+	var rules []ignoreRule
+	for _, name := range t.names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		rules = append(rules, parseIgnoreRules(string(data))...)
+	}
+
+	if len(rules) > 0 {
+		t.rules[dir] = rules
+	}
+}
+
+// ignored returns true if a path is ignored by the rules loaded for its parent directories
+// Like in git, rules in deeper directories override those above them and the last matching rule wins.
+func (t *ignoreTree) ignored(path string, isDir bool) bool {
+	if len(t.names) == 0 {
+		return false
+	}
+
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if len(t.rules[dir]) > 0 {
+			dirs = append(dirs, dir)
+		}
+
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], path)
+		if err != nil {
+			continue
+		}
+
+		rel = filepath.ToSlash(rel)
+		for _, rule := range t.rules[dirs[i]] {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+
+			if rule.re.MatchString(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	return ignored
+}
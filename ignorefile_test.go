@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_ignoreTree_ignored(t *testing.T) {
+	p := filepath.FromSlash
+	tree := newIgnoreTree([]string{gitignoreFile})
+	tree.rules[p("/repo")] = parseIgnoreRules("# build output\n*.o\nbuild/\n/vendor\ndocs/**/*.tmp\n!keep.o\n")
+	tree.rules[p("/repo/src")] = parseIgnoreRules("gen-?.go\n!main.o\n")
+	tree.rules[p("/repo/src/lib")] = nil
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"/repo/a.o", false, true},
+		{"/repo/src/lib/a.o", false, true},
+		{"/repo/keep.o", false, false},
+		{"/repo/src/main.o", false, false},
+		{"/repo/build", true, true},
+		{"/repo/build", false, false},
+		{"/repo/src/build", true, true},
+		{"/repo/vendor", true, true},
+		{"/repo/src/vendor", true, false},
+		{"/repo/docs/a/b/c.tmp", false, true},
+		{"/repo/docs/c.tmp", false, true},
+		{"/repo/c.tmp", false, false},
+		{"/repo/src/gen-1.go", false, true},
+		{"/repo/gen-1.go", false, false},
+		{"/repo/src/gen-10.go", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := tree.ignored(p(tt.path), tt.isDir); got != tt.want {
+				t.Errorf("ignored() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getAllFileSizes_ignoreFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		".gitignore":              "node_modules/\n*.log\n",
+		"a.txt":                   "a",
+		"debug.log":               "b",
+		"node_modules/x/c.txt":    "c",
+		"sub/.gitignore":          "!important.log\n",
+		"sub/important.log":       "d",
+		".git/objects/ab/cdef0":   "e",
+		"photos/.dblfinderignore": "*.jpg\n",
+		"photos/a.jpg":            "f",
+		"photos/a.png":            "g",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileSizes, err := getAllFileSizes([]string{dir}, walkFilter{gitignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, paths := range fileSizes {
+		for _, path := range paths {
+			rel, _ := filepath.Rel(dir, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(got)
+
+	want := []string{".gitignore", "a.txt", "photos/.dblfinderignore", "photos/a.png", "sub/.gitignore", "sub/important.log"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getAllFileSizes() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// fileID identifies the data of a file on a device, hard links to the same data share it
+type fileID struct {
+	dev, ino uint64
+}
+
+// errNoFileID is returned if the platform doesn't provide an identity for a file
+var errNoFileID = errors.New("file identity not available")
+
+// collapseHardlinks keeps a single path of the same size files which are hard links to the same data
+// It returns the remaining files and the groups of paths which are already linked, the path kept first.
+func collapseHardlinks(sameSizeFiles map[int64][]string) (map[int64][]string, [][]string) {
+	var (
+		res    = map[int64][]string{}
+		linked [][]string
+	)
+
+	for _, size := range sortedSizes(sameSizeFiles) {
+		var (
+			ids   []fileID
+			byID  = map[fileID][]string{}
+			files []string
+		)
+
+		for _, file := range sameSizeFiles[size] {
+			id, err := fileIdentity(file)
+			if err != nil {
+				files = append(files, file)
+				continue
+			}
+
+			if first, ok := byID[id]; !ok {
+				ids = append(ids, id)
+				files = append(files, file)
+			} else {
+				traceSkip(file, "hard link", "of", first[0])
+			}
+
+			byID[id] = append(byID[id], file)
+		}
+
+		for _, id := range ids {
+			if len(byID[id]) > 1 {
+				linked = append(linked, byID[id])
+			}
+		}
+
+		res[size] = files
+	}
+
+	return res, linked
+}
+
+// reportLinked prints the files which are already hard links of each other, so there is nothing to do about them
+func reportLinked(linked [][]string, verbose bool) {
+	if len(linked) == 0 {
+		return
+	}
+
+	count := 0
+	for _, files := range linked {
+		count += len(files) - 1
+	}
+
+	progressf("%d file(s) are already hard links of other files, they are hashed once and not offered for deletion\n", count)
+
+	if !verbose {
+		return
+	}
+
+	for _, files := range linked {
+		fmt.Println("The following files are already linked:")
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_collapseHardlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-inode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if err := ioutil.WriteFile(path(name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a2", "a3"} {
+		if err := os.Link(path("a"), path(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, linked := collapseHardlinks(map[int64][]string{1: {path("a"), path("b"), path("a2"), path("a3")}})
+
+	if want := map[int64][]string{1: {path("a"), path("b")}}; !reflect.DeepEqual(files, want) {
+		t.Errorf("collapseHardlinks() files = %v, want %v", files, want)
+	}
+	if want := [][]string{{path("a"), path("a2"), path("a3")}}; !reflect.DeepEqual(linked, want) {
+		t.Errorf("collapseHardlinks() linked = %v, want %v", linked, want)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	keepOldest        = "oldest"
+	keepNewest        = "newest"
+	keepShortestPath  = "shortest-path"
+	keepDeepestPath   = "deepest-path"
+	keepFirstRoot     = "first-root"
+	keepMostHardlinks = "most-hardlinks"
+)
+
+// keepPolicies lists the policies available to pick the file to keep of a group automatically
+var keepPolicies = []string{keepOldest, keepNewest, keepShortestPath, keepDeepestPath, keepFirstRoot, keepMostHardlinks}
+
+// validKeepPolicy returns true if policy is one of keepPolicies
+func validKeepPolicy(policy string) bool {
+	for _, p := range keepPolicies {
+		if p == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rankSurvivor returns the first file of a group with the best score according to a keep policy
+// and whether other files share that score.
+func rankSurvivor(files []string, policy string, roots []string) (string, bool, error) {
+	var (
+		best      string
+		bestScore int64
+		tied      bool
+	)
+
+	for i, file := range files {
+		score, err := keepScore(file, policy, roots)
+		if err != nil {
+			return "", false, err
+		}
+
+		switch {
+		case i == 0 || score > bestScore:
+			best, bestScore, tied = file, score, false
+		case score == bestScore:
+			tied = true
+		}
+	}
+
+	return best, tied, nil
+}
+
+// keepScore rates a file according to a keep policy, the file with the highest score is kept
+func keepScore(file, policy string, roots []string) (int64, error) {
+	switch policy {
+	case keepOldest, keepNewest:
+		fi, err := os.Stat(file)
+		if err != nil {
+			return 0, err
+		}
+
+		if policy == keepOldest {
+			return -fi.ModTime().UnixNano(), nil
+		}
+
+		return fi.ModTime().UnixNano(), nil
+	case keepShortestPath:
+		return -int64(len(file)), nil
+	case keepDeepestPath:
+		return int64(strings.Count(filepath.Clean(file), string(filepath.Separator))), nil
+	case keepFirstRoot:
+		i, err := rootIndex(file, roots)
+
+		return -int64(i), err
+	case keepMostHardlinks:
+		n, err := linkCount(file)
+
+		return int64(n), err
+	}
+
+	return 0, fmt.Errorf("unknown keep policy: %s", policy)
+}
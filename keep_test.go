@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_applyKeepPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+
+	now := time.Now()
+	files := map[string]time.Time{
+		"new/a":         now,
+		"old/deep/er/a": now.Add(-time.Hour),
+		"mid/a":         now.Add(-time.Minute),
+	}
+	for name, mtime := range files {
+		if err := os.MkdirAll(filepath.Dir(path(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path(name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path(name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(path("mid/a"), path("mid/b")); err != nil {
+		t.Fatal(err)
+	}
+
+	group := []string{path("new/a"), path("old/deep/er/a"), path("mid/a")}
+	answerMap := map[int]string{0: group[0], 1: group[1], 2: group[2]}
+	roots := []string{path("mid"), path("new"), path("old")}
+
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{
+			"oldest",
+			keepOldest,
+			path("old/deep/er/a"),
+		},
+		{
+			"newest",
+			keepNewest,
+			path("new/a"),
+		},
+		{
+			"shortest-path-first-on-tie",
+			keepShortestPath,
+			path("new/a"),
+		},
+		{
+			"deepest-path",
+			keepDeepestPath,
+			path("old/deep/er/a"),
+		},
+		{
+			"first-root",
+			keepFirstRoot,
+			path("mid/a"),
+		},
+		{
+			"most-hardlinks",
+			keepMostHardlinks,
+			path("mid/a"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyKeepPolicy(group, answerMap, tt.policy, roots, false)
+			if len(got) != len(group)-1 || keptFile(group, got) != tt.want {
+				t.Errorf("applyKeepPolicy() = %v, want all but %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rankSurvivor(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		wantKeep string
+		wantTied bool
+	}{
+		{
+			"clear",
+			[]string{"/a/bb", "/a/b", "/a/bbb"},
+			"/a/b",
+			false,
+		},
+		{
+			"tied",
+			[]string{"/a/bb", "/a/b", "/a/c"},
+			"/a/b",
+			true,
+		},
+		{
+			"tie-below-best",
+			[]string{"/a/bb", "/a/cc", "/a/b"},
+			"/a/b",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, tied, err := rankSurvivor(tt.files, keepShortestPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keep != tt.wantKeep || tied != tt.wantTied {
+				t.Errorf("rankSurvivor() = %v, %v, want %v, %v", keep, tied, tt.wantKeep, tt.wantTied)
+			}
+		})
+	}
+}
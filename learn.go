@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// learnThreshold is how many times files under a directory have to be kept over files under another before this
+// is inferred to be the user's preference
+const learnThreshold = 3
+
+// dirPreference is a preference to keep files under a directory over their duplicates under another one
+type dirPreference struct {
+	keep, over string
+}
+
+// String returns a human readable description of a directory preference
+func (p dirPreference) String() string {
+	return fmt.Sprintf("keep %s over %s", p.keep, p.over)
+}
+
+// dirLearner infers directory preferences from the decisions made by the user during a session
+type dirLearner struct {
+	counts  map[dirPreference]int
+	offered map[dirPreference]bool
+	enabled map[dirPreference]bool
+}
+
+func newDirLearner() *dirLearner {
+	return &dirLearner{
+		counts:  map[dirPreference]int{},
+		offered: map[dirPreference]bool{},
+		enabled: map[dirPreference]bool{},
+	}
+}
+
+// observe records a decision made by the user and returns the directory preferences newly inferred from it
+func (l *dirLearner) observe(files, deleteFiles []string) []dirPreference {
+	deleted := map[string]bool{}
+	for _, file := range deleteFiles {
+		deleted[file] = true
+	}
+
+	var seen []dirPreference
+	for _, kept := range files {
+		if deleted[kept] {
+			continue
+		}
+
+		for _, file := range deleteFiles {
+			keepDir, overDir, ok := divergence(kept, file)
+			if !ok {
+				continue
+			}
+
+			p := dirPreference{keepDir, overDir}
+			l.counts[p]++
+			seen = append(seen, p)
+		}
+	}
+
+	var res []dirPreference
+	for _, p := range seen {
+		if l.offered[p] || !l.inferred(p) {
+			continue
+		}
+
+		l.offered[p] = true
+		res = append(res, p)
+	}
+
+	return res
+}
+
+// inferred returns true if files under a directory were kept often enough over the other one, and never the other way
+func (l *dirLearner) inferred(p dirPreference) bool {
+	return l.counts[p] >= learnThreshold && l.counts[dirPreference{p.over, p.keep}] == 0
+}
+
+// enable makes a directory preference apply to the groups decided from now on
+func (l *dirLearner) enable(p dirPreference) {
+	l.enabled[p] = true
+}
+
+// decide returns the files to delete from a group based on the enabled directory preferences
+// A group is only decided if each file to choose from is either under a directory preferred over another one of the
+// group, or under a directory which the group has a preferred alternative to. Preferences contradicted since they were
+// enabled are no longer used.
+func (l *dirLearner) decide(files []string, answerMap map[int]string) ([]string, []dirPreference, bool) {
+	var used []dirPreference
+	for p := range l.enabled {
+		if l.inferred(p) && underAny(files, p.keep) && underAny(files, p.over) {
+			used = append(used, p)
+		}
+	}
+
+	if len(used) == 0 {
+		return nil, nil, false
+	}
+
+	var keys []int
+	for key := range answerMap {
+		keys = append(keys, key)
+	}
+
+	sort.Ints(keys)
+
+	var res []string
+	for _, key := range keys {
+		file := answerMap[key]
+
+		switch {
+		case underPreference(file, used, true):
+			continue
+		case underPreference(file, used, false):
+			res = append(res, file)
+		default:
+			return nil, nil, false
+		}
+	}
+
+	if len(res) == 0 || len(res) == len(files) {
+		return nil, nil, false
+	}
+
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].String() < used[j].String()
+	})
+
+	return res, used, true
+}
+
+// underPreference returns true if a file is under the kept (or the other) directory of any of the preferences
+func underPreference(file string, prefs []dirPreference, keep bool) bool {
+	for _, p := range prefs {
+		dir := p.over
+		if keep {
+			dir = p.keep
+		}
+
+		if under(file, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// underAny returns true if any of the files is under dir
+func underAny(files []string, dir string) bool {
+	for _, file := range files {
+		if under(file, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// under returns true if path is located anywhere below dir
+func under(path, dir string) bool {
+	return strings.HasPrefix(filepath.Clean(path), dir+string(filepath.Separator))
+}
+
+// divergence returns the directories in which the paths of two files part ways
+// If either file is located directly in the directory the other one is below, false is returned.
+func divergence(a, b string) (string, string, bool) {
+	sep := string(filepath.Separator)
+	pa, pb := strings.Split(filepath.Clean(a), sep), strings.Split(filepath.Clean(b), sep)
+
+	i := 0
+	for i < len(pa)-1 && i < len(pb)-1 && pa[i] == pb[i] {
+		i++
+	}
+
+	if i == len(pa)-1 || i == len(pb)-1 {
+		return "", "", false
+	}
+
+	return strings.Join(pa[:i+1], sep), strings.Join(pb[:i+1], sep), true
+}
+
+// reportLearned lists the groups decided by learned directory preferences, and returns whether to act on them
+func reportLearned(decisions []decision, opts options) (bool, error) {
+	if len(decisions) == 0 {
+		return false, nil
+	}
+
+	fmt.Printf("%d group(s) were decided by learned directory preferences:\n", len(decisions))
+	for _, d := range decisions {
+		fmt.Printf("  %s (%s), %d file(s) to be %s\n", d.keep, d.reason, len(d.files), describeAction(opts))
+	}
+
+	return confirm("Apply these decisions?", opts.promptTimeout)
+}
+
+// joinPreferences returns the description of several directory preferences
+func joinPreferences(prefs []dirPreference) string {
+	var res []string
+	for _, p := range prefs {
+		res = append(res, p.String())
+	}
+
+	return strings.Join(res, ", ")
+}
+
+// offerPreferences enables newly inferred directory preferences, with -learn right away, otherwise if the user agrees
+func offerPreferences(learner *dirLearner, prefs []dirPreference, opts options) error {
+	for _, p := range prefs {
+		if opts.learn {
+			fmt.Printf("Learned to %s in similar groups.\n\n", p)
+			learner.enable(p)
+			continue
+		}
+
+		agreed, err := confirm(fmt.Sprintf("Files under %s were kept over files under %s %d times. Decide similar groups the same way?", p.keep, p.over, learner.counts[p]), opts.promptTimeout)
+		if err != nil {
+			return err
+		}
+
+		if agreed {
+			learner.enable(p)
+		}
+	}
+
+	return nil
+}
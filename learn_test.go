@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_divergence(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		wantA    string
+		wantB    string
+		wantDirs bool
+	}{
+		{"sibling-dirs", "/data/archive/2020/a.jpg", "/data/downloads/a.jpg", "/data/archive", "/data/downloads", true},
+		{"top-level", "/archive/a", "/downloads/b", "/archive", "/downloads", true},
+		{"same-dir", "/data/a", "/data/b", "", "", false},
+		{"file-next-to-dir", "/data/a", "/data/sub/a", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b, ok := divergence(filepath.FromSlash(tt.a), filepath.FromSlash(tt.b))
+			if a != filepath.FromSlash(tt.wantA) || b != filepath.FromSlash(tt.wantB) || ok != tt.wantDirs {
+				t.Errorf("divergence() = %v, %v, %v, want %v, %v, %v", a, b, ok, tt.wantA, tt.wantB, tt.wantDirs)
+			}
+		})
+	}
+}
+
+func Test_dirLearner(t *testing.T) {
+	p := func(s string) string {
+		return filepath.FromSlash(s)
+	}
+
+	l := newDirLearner()
+	for i, name := range []string{"a", "b"} {
+		if got := l.observe([]string{p("/archive/" + name), p("/downloads/" + name)}, []string{p("/downloads/" + name)}); len(got) != 0 {
+			t.Fatalf("observe() #%d = %v, want nothing inferred yet", i, got)
+		}
+	}
+
+	want := []dirPreference{{p("/archive"), p("/downloads")}}
+	if got := l.observe([]string{p("/archive/c"), p("/downloads/c")}, []string{p("/downloads/c")}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("observe() = %v, want %v", got, want)
+	}
+
+	files := []string{p("/downloads/d"), p("/archive/x/d"), p("/downloads/e")}
+	answerMap := map[int]string{0: files[0], 1: files[1], 2: files[2]}
+
+	if _, _, ok := l.decide(files, answerMap); ok {
+		t.Errorf("decide() decided a group before the preference was enabled")
+	}
+
+	l.enable(want[0])
+
+	got, prefs, ok := l.decide(files, answerMap)
+	if !ok || !reflect.DeepEqual(got, []string{files[0], files[2]}) || !reflect.DeepEqual(prefs, want) {
+		t.Errorf("decide() = %v, %v, %v", got, prefs, ok)
+	}
+
+	other := []string{p("/downloads/f"), p("/archive/f"), p("/other/f")}
+	if _, _, ok := l.decide(other, map[int]string{0: other[0], 1: other[1], 2: other[2]}); ok {
+		t.Errorf("decide() decided a group with a file not covered by any preference")
+	}
+
+	l.observe([]string{p("/archive/g"), p("/downloads/g")}, []string{p("/archive/g")})
+	if _, _, ok := l.decide(files, answerMap); ok {
+		t.Errorf("decide() used a contradicted preference")
+	}
+}
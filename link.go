@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// linkers contains the functions creating a link to oldname at newname for each linking action
+var linkers = map[action]func(oldname, newname string) error{
+	linkAction:    os.Link,
+	reflinkAction: cloneFile,
+}
+
+// linkFiles replaces files with hard links or clones of keep depending on the action, unless dryRun is set
+// Links can't span filesystems, so files on a different device than keep are left untouched.
+func linkFiles(keep string, files []string, useAction action, dryRun bool) {
+	keepDev, err := deviceID(keep)
+	if err != nil {
+		fmt.Printf("can't determine device of file: %s, err %v\n", keep, err)
+		return
+	}
+
+	for _, file := range files {
+		dev, err := deviceID(file)
+		if err != nil {
+			fmt.Printf("can't determine device of file: %s, err %v\n", file, err)
+			continue
+		}
+
+		if dev != keepDev {
+			fmt.Printf("Linking: %s (skipped, not on the same device as %s)\n", file, keep)
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("Linking: %s => %s (%s, skipped)\n", file, keep, useAction)
+			continue
+		}
+
+		fmt.Printf("Linking: %s => %s (%s)\n", file, keep, useAction)
+
+		if err := replaceWithLink(keep, file, linkers[useAction]); err != nil {
+			fmt.Printf("%v\n", err)
+		} else {
+			fmt.Println("done.")
+		}
+	}
+}
+
+// replaceWithLink creates a link to keep next to file and renames it over file, so that file is never missing
+func replaceWithLink(keep, file string, link func(oldname, newname string) error) error {
+	tmp := file + ".dblfinder-link"
+
+	if err := link(keep, tmp); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_linkFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	for _, path := range []string{keep, dup} {
+		if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		dryRun bool
+		want   bool
+	}{
+		{
+			"dry-run",
+			true,
+			false,
+		},
+		{
+			"link",
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linkFiles(keep, []string{dup}, linkAction, tt.dryRun)
+
+			a, err := os.Stat(keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.Stat(dup)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := os.SameFile(a, b); got != tt.want {
+				t.Errorf("linkFiles() linked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
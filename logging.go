@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+
+	"golang.org/x/term"
+)
+
+const (
+	// log formats of -log-format
+	logText = "text"
+	logJSON = "json"
+)
+
+// logFormats lists the formats log records can be written in
+var logFormats = []string{logText, logJSON}
+
+// logLevels maps the levels of -log-level to the levels of slog
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// logLevelNames returns the names of the log levels, from the most to the least verbose
+func logLevelNames() []string {
+	var res []string
+	for name := range logLevels {
+		res = append(res, name)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return logLevels[res[i]] < logLevels[res[j]]
+	})
+
+	return res
+}
+
+// validLogFormat returns true if format is one of logFormats
+func validLogFormat(format string) bool {
+	for _, f := range logFormats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newLogger returns a logger writing records of at least the given level to w in the given format
+func newLogger(w io.Writer, level, format string) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: logLevels[level]}
+
+	if format == logJSON {
+		return slog.New(slog.NewJSONHandler(w, handlerOpts))
+	}
+
+	return slog.New(slog.NewTextHandler(w, handlerOpts))
+}
+
+// setupLogging makes the default logger write to the log file, or stderr if none is given
+// Warnings and errors are colored in color mode. Records are also kept in the ring buffer of recent log lines for
+// diagnostics bundles. The standard logger writes through the default logger as well. The returned function closes
+// the log file.
+func setupLogging(level, file, format string) (func() error, error) {
+	var out io.Writer = os.Stderr
+	closeLog := func() error { return nil }
+
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		out, closeLog = f, f.Close
+	} else if colorMode && format == logText && term.IsTerminal(int(os.Stderr.Fd())) {
+		out = levelColorWriter{os.Stderr}
+	}
+
+	slog.SetDefault(newLogger(io.MultiWriter(out, recentLog), level, format))
+
+	return closeLog, nil
+}
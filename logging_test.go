@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_newLogger(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  string
+		format string
+		want   int
+	}{
+		{"debug-text", "debug", logText, 3},
+		{"info-json", "info", logJSON, 2},
+		{"error-json", "error", logJSON, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := newLogger(&buf, tt.level, tt.format)
+
+			logger.Debug("symlink found", "path", "a")
+			logger.Info("scan started")
+			logger.Error("can't hash file", "path", "b")
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != tt.want {
+				t.Fatalf("newLogger() wrote %q, want %d record(s)", buf.String(), tt.want)
+			}
+
+			if tt.format != logJSON {
+				return
+			}
+
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+				t.Fatal(err)
+			}
+			if record["level"] != "ERROR" || record["path"] != "b" {
+				t.Errorf("newLogger() wrote %v", record)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// extAliases maps extensions to the canonical one of the equivalent extensions
+var extAliases = map[string]string{
+	"jpeg": "jpg",
+	"jpe":  "jpg",
+	"tif":  "tiff",
+	"htm":  "html",
+	"mpeg": "mpg",
+	"mpe":  "mpg",
+	"aif":  "aiff",
+	"yml":  "yaml",
+}
+
+// normalizeExt returns the canonical form of an extension given in lowercase without its leading dot
+func normalizeExt(ext string) string {
+	if alias, ok := extAliases[ext]; ok {
+		return alias
+	}
+
+	return ext
+}
+
+// magicType is a file type recognizable by the bytes found at an offset of its content
+type magicType struct {
+	name   string
+	mime   string
+	offset int
+	magic  []byte
+	exts   []string
+}
+
+// magicTypes lists the file types detected, container formats accept the extensions of the formats built on them
+var magicTypes = []magicType{
+	{"jpg", "image/jpeg", 0, []byte{0xff, 0xd8, 0xff}, []string{"jpg"}},
+	{"png", "image/png", 0, []byte("\x89PNG\r\n\x1a\n"), []string{"png"}},
+	{"gif", "image/gif", 0, []byte("GIF8"), []string{"gif"}},
+	{"bmp", "image/bmp", 0, []byte("BM"), []string{"bmp", "dib"}},
+	{"tiff", "image/tiff", 0, []byte("II*\x00"), []string{"tiff", "dng", "nef", "cr2", "arw"}},
+	{"tiff", "image/tiff", 0, []byte("MM\x00*"), []string{"tiff", "dng", "nef", "cr2", "arw"}},
+	{"webp", "image/webp", 8, []byte("WEBP"), []string{"webp"}},
+	{"wav", "audio/wav", 8, []byte("WAVE"), []string{"wav"}},
+	{"avi", "video/avi", 8, []byte("AVI "), []string{"avi"}},
+	{"pdf", "application/pdf", 0, []byte("%PDF-"), []string{"pdf", "ai"}},
+	{"zip", "application/zip", 0, []byte("PK\x03\x04"), []string{"zip", "docx", "xlsx", "pptx", "odt", "ods", "odp", "jar", "apk", "epub", "kmz", "xpi"}},
+	{"gz", "application/gzip", 0, []byte{0x1f, 0x8b}, []string{"gz", "tgz"}},
+	{"7z", "application/x-7z-compressed", 0, []byte("7z\xbc\xaf\x27\x1c"), []string{"7z"}},
+	{"rar", "application/vnd.rar", 0, []byte("Rar!\x1a\x07"), []string{"rar"}},
+	{"mp3", "audio/mpeg", 0, []byte("ID3"), []string{"mp3"}},
+	{"flac", "audio/flac", 0, []byte("fLaC"), []string{"flac"}},
+	{"ogg", "application/ogg", 0, []byte("OggS"), []string{"ogg", "oga", "ogv", "opus"}},
+	{"heic", "image/heic", 4, []byte("ftypheic"), []string{"heic", "heif"}},
+	{"heic", "image/heic", 4, []byte("ftypheix"), []string{"heic", "heif"}},
+	{"heif", "image/heif", 4, []byte("ftypmif1"), []string{"heic", "heif", "avif"}},
+	{"avif", "image/avif", 4, []byte("ftypavif"), []string{"avif"}},
+	{"mp4", "video/mp4", 4, []byte("ftyp"), []string{"mp4", "m4a", "m4v", "mov", "3gp", "heic", "heif", "avif"}},
+	{"mkv", "video/x-matroska", 0, []byte{0x1a, 0x45, 0xdf, 0xa3}, []string{"mkv", "webm"}},
+}
+
+// magicHeadSize is the number of bytes needed to detect any of the magic types
+const magicHeadSize = 16
+
+// sniffType returns the magic type matching the beginning of a file's content, or nil if none matches
+func sniffType(head []byte) *magicType {
+	for i, t := range magicTypes {
+		end := t.offset + len(t.magic)
+		if len(head) >= end && bytes.Equal(head[t.offset:end], t.magic) {
+			return &magicTypes[i]
+		}
+	}
+
+	return nil
+}
+
+// sniffMime returns the MIME type of a file detected from the beginning of its content
+// Types unknown to magicTypes are detected as described by https://mimesniff.spec.whatwg.org/.
+func sniffMime(file string) (string, error) {
+	f, err := fds.openFile(sniffStage, file)
+	if err != nil {
+		return "", err
+	}
+	defer fds.closeFile(sniffStage, f)
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	if t := sniffType(head[:n]); t != nil {
+		return t.mime, nil
+	}
+
+	mime := http.DetectContentType(head[:n])
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+
+	return mime, nil
+}
+
+// parseMimePatterns parses a comma separated list of MIME types, which may use wildcards (eg. image/*)
+func parseMimePatterns(list string) ([]string, error) {
+	var res []string
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil || !strings.Contains(pattern, "/") {
+			return nil, fmt.Errorf("invalid MIME type: %s", pattern)
+		}
+
+		res = append(res, pattern)
+	}
+
+	return res, nil
+}
+
+// matchMime returns true if a MIME type matches any of the patterns
+func matchMime(patterns []string, mime string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, mime); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterMime keeps the same size files whose content matches any of the MIME type patterns
+func filterMime(sameSizeFiles map[int64][]string, patterns []string) map[int64][]string {
+	res := map[int64][]string{}
+	for size, files := range sameSizeFiles {
+		for _, file := range files {
+			mime, err := sniffMime(file)
+			if err != nil {
+				fmt.Printf("can't read file: %s, err %v\n", file, err)
+				continue
+			}
+
+			if !matchMime(patterns, mime) {
+				traceSkip(file, "mime type", "mime", mime)
+				continue
+			}
+
+			res[size] = append(res[size], file)
+		}
+	}
+
+	return res
+}
+
+// typeMismatch returns the detected type of a file if its content disagrees with its extension
+// Files of unknown types, and files without an extension are not reported.
+func typeMismatch(path string) (string, error) {
+	ext := extension(path)
+	if ext == "" {
+		return "", nil
+	}
+
+	f, err := fds.openFile(sniffStage, path)
+	if err != nil {
+		return "", err
+	}
+	defer fds.closeFile(sniffStage, f)
+
+	head := make([]byte, magicHeadSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	t := sniffType(head[:n])
+	if t == nil {
+		return "", nil
+	}
+
+	for _, e := range t.exts {
+		if e == ext {
+			return "", nil
+		}
+	}
+
+	return t.name, nil
+}
+
+// typeNote returns a note to print next to a file whose content disagrees with its extension
+func typeNote(path string) string {
+	name, err := typeMismatch(path)
+	if err != nil || name == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" (content is %s)", name)
+}
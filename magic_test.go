@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_typeMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-magic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	jpg := "\xff\xd8\xff\xe0\x00\x10JFIF"
+	mp4 := "\x00\x00\x00\x18ftypmp42"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"photo.png", png, ""},
+		{"photo.jpg", png, "png"},
+		{"photo.JPEG", jpg, ""},
+		{"clip.mov", mp4, ""},
+		{"clip.avi", mp4, "mp4"},
+		{"notes.txt", "hello", ""},
+		{"noext", png, ""},
+		{"short.png", "\x89P", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := typeMismatch(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("typeMismatch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_filterMime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-mime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"photo":      "\xff\xd8\xff\xe0\x00\x10JFIF",
+		"photo.heic": "\x00\x00\x00\x18ftypheic\x00\x00",
+		"clip.bin":   "\x00\x00\x00\x18ftypmp42\x00\x00",
+		"notes.jpg":  "just some text",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	tests := []struct {
+		name string
+		list string
+		want []string
+	}{
+		{"images", "image/*", []string{"photo", "photo.heic"}},
+		{"images-and-video", "image/jpeg, video/*", []string{"clip.bin", "photo"}},
+		{"text", "text/plain", []string{"notes.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns, err := parseMimePatterns(tt.list)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, path := range filterMime(map[int64][]string{10: paths}, patterns)[10] {
+				got = append(got, filepath.Base(path))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterMime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseMimePatterns("image"); err == nil {
+		t.Errorf("parseMimePatterns() expected an error for a type without a subtype")
+	}
+}
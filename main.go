@@ -5,54 +5,255 @@ When duplicates are found, it can provide an option to delete one of the them.
 package main
 
 import (
-	"bufio"
-	"crypto/md5"
+	"encoding/hex"
 	"flag"
 	"fmt"
-	"log"
+	"hash"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/peteraba/dblfinder/model"
 )
 
-type action string
+// action is what's done with the duplicates decided on
+type action = model.Action
 
 const (
-	version           = "0.5.2"
-	KB                = 20
-	keepAction action = "keep"
-	listAction action = "list"
+	version              = "0.5.2"
+	KB                   = 20
+	keepAction    action = "keep"
+	listAction    action = "list"
+	markAction    action = "mark"
+	linkAction    action = "hardlink"
+	reflinkAction action = "reflink"
+	dedupeAction  action = "dedupe"
+	trashAction   action = "trash"
+	moveAction    action = "move"
+	deleteAction  action = "delete"
 )
 
-func getFlags() (action, int, bool, []string, string, string, bool, bool, int) {
+// commandsUsage lists the commands, each of them takes -help to list its own flags
+const commandsUsage = `Usage: dblfinder [scan] [flags] <root>...
+       dblfinder <command> [flags] [args]
+
+Commands:
+  scan             find duplicates under the roots and act on them, the default without a command
+  apply            act on the decisions of a plan, the audit log of a -dry-run scan
+  report           render the JSON report of a scan in another format
+  cache            list or clear the caches kept between runs
+  undo             revert the decisions recorded in an audit log
+  serve            serve a web dashboard of the duplicates under the roots
+  check            fail if duplicates waste more space than a budget, for CI
+  cp               copy a tree, skipping files already present at the destination
+  export-manifest  write the files under the roots as a manifest
+  verify-matches   compare the content of files matched by name and size
+  purge-marked     delete the files marked long enough ago
+  self-update      update dblfinder to the latest release
+
+Flags of scan:
+`
+
+// options contains the settings read from the command line
+type options struct {
+	action        action
+	fsLimit       int
+	verbose       bool
+	roots         []string
+	filter        walkFilter
+	prefer        []string
+	skipManual    bool
+	dryRun        bool
+	verify        bool
+	asAdmin       bool
+	quick         bool
+	match         string
+	matchesOut    string
+	marksFile     string
+	target        string
+	keep          string
+	bucketMax     int
+	bucketMode    string
+	sampleSize    int
+	tuneSample    int
+	strategy      []string
+	newHash       func() hash.Hash
+	manifest      string
+	backup        manifest
+	settle        time.Duration
+	protect       []pathPattern
+	acrossRoots   bool
+	sameDir       bool
+	expected      string
+	mime          []string
+	promptTimeout time.Duration
+	hashName      string
+	output        string
+	learn         bool
+	auditLog      string
+	reportFile    string
+	debugFDs      bool
+	plain         bool
+	print0        bool
+	groupSep      string
+	trashBackend  string
+	format        *template.Template
+	storage       storage
+	export        string
+	requireBackup bool
+	maxDuration   time.Duration
+	top           int
+	minWaste      int64
+	failOnDups    bool
+	chownTo       *ownership
+	refuseMixed   bool
+	quiet         bool
+	profile       string
+	progress      string
+	thumbs        thumbCache
+	logLevel      string
+	logFile       string
+	logFormat     string
+	traceFile     string
+	noColor       bool
+	exactSizes    bool
+	tui           bool
+	listen        string
+}
+
+func getFlags() options {
 	var (
-		showHelp, showVersion, skipManual bool
-		verbose, dryRun                   bool
-		fsLimit, sampleSize               int
-		useAction, ignore, prefer         string
-		roots                             []string
+		showHelp, showVersion, skipManual  bool
+		verbose, dryRun, fullHash, verify  bool
+		asAdmin, quick, acrossRoots        bool
+		gitignore, sameDir, skipHidden     bool
+		oneFileSystem, learn, debugFDs     bool
+		print0, requireBackup, plain       bool
+		skipEmpty, hydrate, failOnDups     bool
+		refuseMixed, quiet, noColor        bool
+		exactSizes, tui                    bool
+		fsLimit, sampleSize, bucketMax     int
+		maxDepth, thumbCacheSize, top      int
+		useAction, ignore                  string
+		includeExt, excludeExt, mime       string
+		excludeFrom                        listFlag
+		minWaste                           sizeFlag
+		include, exclude                   listFlag
+		prefer, protect                    listFlag
+		hashName, sampleStrategy, output   string
+		marksFile, match, matchesFile      string
+		reportFile, groupSep, trashBackend string
+		format, export, thumbCacheDir      string
+		chownTo, profile, progress         string
+		logLevel, logFile, logFormat       string
+		traceFile, listen                  string
+		target, keep, bucketMode           string
+		manifestFile, expected, auditLog   string
+		roots                              []string
+		settle, promptTimeout, maxDuration time.Duration
 	)
 
 	flag.BoolVar(&showHelp, "help", false, "display help")
 	flag.BoolVar(&showVersion, "version", false, "display the version number")
 	flag.BoolVar(&verbose, "verbose", false, "provide verbose output")
+	flag.StringVar(&progress, "progress", progressAuto, "how to show progress ("+strings.Join(progressModes, ", ")+"), auto draws a progress bar on terminals, json writes progress events to stderr")
+	flag.StringVar(&logLevel, "log-level", "info", "minimum level of the log records written ("+strings.Join(logLevelNames(), ", ")+"), -verbose lowers it to debug")
+	flag.StringVar(&logFile, "log-file", "", "append log records to this file instead of stderr")
+	flag.StringVar(&traceFile, "trace-file", "", "record each file visited, each file skipped and why, and each hash calculated into this file")
+	flag.StringVar(&logFormat, "log-format", logText, "format to write log records in ("+strings.Join(logFormats, ", ")+")")
+	flag.BoolVar(&quiet, "quiet", false, "only print the results of the run, without progress messages and the details of each group decided automatically")
+	flag.BoolVar(&tui, "tui", false, "review the duplicate groups on a full screen terminal UI, marking the files to keep and to delete before applying all of them")
+	flag.StringVar(&listen, "listen", "", "serve a JSON API on this address (eg. :8080) to start scans, poll their progress, fetch the duplicate groups found and act on them, instead of scanning the roots")
+	flag.BoolVar(&exactSizes, "bytes", false, "list sizes in bytes instead of human readable units (eg. 1.4 GiB)")
+	flag.BoolVar(&noColor, "no-color", false, "don't color the output, also disabled by setting NO_COLOR")
+	flag.BoolVar(&plain, "plain", false, "screen reader friendly output: no line editing or in-place updates, groups announced as group N of M")
+	flag.StringVar(&profile, "profile", defaultProfile, "runtime profile ("+strings.Join(profileNames(), ", ")+"), nas-lite reads few files at once with small buffers and frees memory eagerly for low-memory devices")
 	flag.IntVar(&fsLimit, "fs-limit", 10, "limit the maximum number open files")
-	flag.StringVar(&useAction, "action", "list", "action to use for duplicates found (list, keep, delete)")
+	flag.BoolVar(&debugFDs, "debug-fds", false, "report the peak number of open files per stage and files left open at the end of the run")
+	flag.StringVar(&useAction, "action", string(listAction), "action to use for duplicates found ("+strings.Join(actionNames(), ", ")+")")
 	flag.StringVar(&ignore, "ignore", "", "regexp to ignore files completely")
-	flag.StringVar(&prefer, "prefer", "", "regexp to keep files if a duplicate matches it")
+	flag.StringVar(&includeExt, "include-ext", "", "comma separated extensions, only files with these are considered (eg. jpg,png)")
+	flag.StringVar(&excludeExt, "exclude-ext", "", "comma separated extensions, files with these are ignored (eg. tmp,log)")
+	flag.StringVar(&mime, "mime", "", "comma separated MIME types detected from the content of files to consider (eg. image/*,video/*)")
+	flag.Var(&include, "include", "glob (or regexp prefixed with re:) of files to consider, can be repeated")
+	flag.Var(&exclude, "exclude", "glob (or regexp prefixed with re:) of files and directories to ignore, can be repeated")
+	flag.BoolVar(&skipHidden, "skip-hidden", false, "skip hidden files and directories (dot-files, and files with the hidden attribute on Windows)")
+	flag.IntVar(&maxDepth, "max-depth", 0, "only consider files at most this many levels below the roots, 0 means no limit")
+	flag.BoolVar(&skipEmpty, "skip-empty", false, "skip empty files, which are all duplicates of each other")
+	flag.BoolVar(&hydrate, "hydrate", false, "read placeholders of files kept online by cloud sync clients (OneDrive, Dropbox, iCloud, Google Drive), which downloads them")
+	flag.BoolVar(&oneFileSystem, "one-file-system", false, "don't descend into directories on other file systems than the root")
+	flag.BoolVar(&gitignore, "respect-gitignore", false, "skip files ignored by .gitignore files and .git directories")
+	flag.Var(&excludeFrom, "exclude-from", "file of exclude patterns, one per line, can be repeated")
+	flag.Var(&prefer, "prefer", "regexp to keep files if a duplicate matches it, can be repeated to try patterns in order of priority")
+	flag.Var(&protect, "protect", "glob (or regexp prefixed with re:) of files which are always kept, can be repeated")
+	flag.StringVar(&keep, "keep", "", "policy to pick the file to keep without asking ("+strings.Join(keepPolicies, ", ")+")")
 	flag.BoolVar(&skipManual, "skip-manual", false, "skip decisions if prefer did not find anything")
 	flag.BoolVar(&dryRun, "dry-run", false, "dry run, nothing will be deleted but deletion logic will be executed")
-	flag.IntVar(&sampleSize, "sample-size", 1024, "sample size to use for calculating file hashes (KB)")
+	flag.StringVar(&match, "match", matchContent, "how to match files: content, or name-size to pair files across roots by name and size only")
+	flag.StringVar(&matchesFile, "matches-file", "", "save name-size matches into this file, to be verified later by verify-matches")
+	flag.BoolVar(&quick, "quick", false, "only list same size files sharing their extension or name, without hashing them")
+	flag.BoolVar(&acrossRoots, "across-roots-only", false, "only report duplicates found in more than one root")
+	flag.BoolVar(&sameDir, "same-dir-only", false, "only report duplicates located in the same directory")
+	flag.BoolVar(&asAdmin, "as-admin", false, "also act on duplicates owned by other users")
+	flag.StringVar(&chownTo, "chown-to", "", "with -as-admin, make this user[:group] own the file kept of groups owned by multiple users")
+	flag.BoolVar(&refuseMixed, "refuse-mixed-owners", false, "with -as-admin, don't act on groups owned by multiple users")
+	flag.StringVar(&trashBackend, "trash-backend", trashAuto, "how the trash action moves files to the trash ("+strings.Join(trashBackends, ", ")+"), auto uses gio or trash-put when available")
+	flag.StringVar(&target, "target", "", "quarantine directory used by the move action")
+	flag.StringVar(&auditLog, "audit-log", "", "append the decisions acted on to this file as JSON lines, with the rule which made each of them")
+	flag.StringVar(&marksFile, "marks-file", defaultMarksFile(), "file storing the list of files marked for deletion by the mark action")
+	flag.BoolVar(&learn, "learn", false, "decide groups like earlier answers once files under a directory were repeatedly kept over another one")
+	flag.DurationVar(&promptTimeout, "prompt-timeout", 0, "skip a group if the keep prompt is not answered within this duration (eg. 2m), 0 waits forever")
+	flag.BoolVar(&failOnDups, "fail-on-duplicates", false, "exit with code 1 if duplicates are found, eg. to validate backups in CI")
+	flag.Var(&minWaste, "min-waste", "ignore duplicate groups whose extra copies take less space than this (eg. 50M)")
+	flag.IntVar(&top, "top", 0, "only report and handle the N duplicate groups with the most reclaimable space, 0 means all of them")
+	flag.DurationVar(&maxDuration, "max-duration", 0, "stop hashing once the run took this long (eg. 30m) and report the duplicates confirmed so far, 0 means no limit")
+	flag.DurationVar(&settle, "settle", 0, "never act on files modified within this duration (eg. 10m), only report them")
+	flag.BoolVar(&verify, "verify", false, "compare files byte by byte with a kept duplicate before deleting them")
+	flag.IntVar(&sampleSize, "sample-size", 1024, "sample size to use for calculating file hashes (KB), 0 hashes whole files")
+	flag.StringVar(&sampleStrategy, "sample-strategy", sampleHead, "comma separated positions to sample files at (head, middle, tail)")
+	flag.BoolVar(&fullHash, "full-hash", false, "hash whole files instead of samples, same as -sample-size 0")
+	flag.IntVar(&bucketMax, "bucket-limit", 10000, "number of same size files above which a size is considered pathological, 0 disables the check")
+	flag.StringVar(&bucketMode, "bucket-mode", bucketHash, "how to handle pathological sizes (hash, skip, shard-dir, same-ext)")
+	flag.StringVar(&expected, "expected", "", "JSON manifest of expected duplicates (glob pairs or SHA-256 hashes) which are not reported")
+	flag.StringVar(&manifestFile, "manifest", "", "JSON lines manifest of a backup (eg. restic ls --json), restic:<repo> or borg:<repo>::<archive>, groups already backed up are reported")
+	flag.BoolVar(&requireBackup, "require-backup", false, "only act on groups whose content is found in the -manifest by its hash")
+	flag.StringVar(&output, "output", textOutput, "format to report duplicates in ("+strings.Join(outputFormats, ", ")+"), other than text requires -action list")
+	flag.StringVar(&format, "format", "", "Go template to list each file of the duplicates with (eg. '{{.Group}} {{.Path}} {{.Size}}'), fields: Group, Path, Size, ModTime, Hash")
+	flag.BoolVar(&print0, "print0", false, "list the paths of duplicates terminated by NUL bytes instead of newlines, for xargs -0")
+	flag.StringVar(&groupSep, "group-separator", "", "written after each group listed by -output fdupes or -print0, defaults to an empty line (an empty record with -print0)")
+	flag.StringVar(&export, "export", "", "export the duplicates and the actions taken, eg. sqlite:dups.db writes a SQLite database (requires sqlite3)")
+	flag.StringVar(&thumbCacheDir, "thumb-cache", defaultThumbCache(), "directory caching the thumbnails of images and videos shown in HTML reports, empty disables thumbnails")
+	flag.IntVar(&thumbCacheSize, "thumb-cache-size", 100, "maximum size of the thumbnail cache (MB), the least recently used thumbnails are removed above it")
+	flag.StringVar(&reportFile, "report-file", "", "write the report of -output to this file, while duplicates are handled by -action as usual")
+	flag.StringVar(&hashName, "hash", defaultHash, "hash algorithm to use ("+strings.Join(hasherNames(), ", ")+")")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), commandsUsage)
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 
+	if err := applyEnv(flag.CommandLine, os.LookupEnv); err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	if err := applyProfile(flag.CommandLine, profile); err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
 	roots = flag.Args()
 
 	if showHelp {
-		flag.PrintDefaults()
+		flag.Usage()
 		os.Exit(0)
 	}
 
@@ -61,83 +262,700 @@ func getFlags() (action, int, bool, []string, string, string, bool, bool, int) {
 		os.Exit(0)
 	}
 
-	a := listAction
-	if useAction == string(keepAction) {
-		a = keepAction
+	a, err := parseAction(useAction)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	for _, file := range excludeFrom {
+		patterns, err := readPatternFile(file)
+		if err != nil {
+			fmt.Printf("can't read exclude file: %v\n", err)
+			os.Exit(exitError)
+		}
+
+		exclude = append(exclude, patterns...)
+	}
+
+	filter, err := newWalkFilter(ignore, includeExt, excludeExt, include, exclude)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+	filter.gitignore = gitignore
+	filter.skipEmpty = skipEmpty
+	filter.hydrate = hydrate
+	filter.skipHidden = skipHidden
+	filter.oneFileSystem = oneFileSystem
+	filter.maxDepth = maxDepth
+
+	mimePatterns, err := parseMimePatterns(mime)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	protectPatterns, err := parsePathPatterns(protect)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	if !validBucketMode(bucketMode) {
+		fmt.Println(unknownValue("bucket mode", bucketMode, []string{bucketHash, bucketSkip, bucketShardDir, bucketSameExt}))
+		os.Exit(exitError)
+	}
+
+	if keep != "" && !validKeepPolicy(keep) {
+		fmt.Println(unknownValue("keep policy", keep, keepPolicies))
+		os.Exit(exitError)
+	}
+
+	if match != matchContent && match != matchNameSize {
+		fmt.Println(unknownValue("match mode", match, []string{matchContent, matchNameSize}))
+		os.Exit(exitError)
+	}
+
+	if !validOutput(output) {
+		fmt.Println(unknownValue("output format", output, outputFormats))
+		os.Exit(exitError)
 	}
 
+	if _, ok := logLevels[logLevel]; !ok {
+		fmt.Println(unknownValue("log level", logLevel, logLevelNames()))
+		os.Exit(exitError)
+	}
+
+	if !validLogFormat(logFormat) {
+		fmt.Println(unknownValue("log format", logFormat, logFormats))
+		os.Exit(exitError)
+	}
+
+	// verbose runs log the details of the scan, unless a level is given explicitly
+	if verbose && !givenFlags(flag.CommandLine)["log-level"] {
+		logLevel = "debug"
+	}
+
+	if !validProgressMode(progress) {
+		fmt.Println(unknownValue("progress mode", progress, progressModes))
+		os.Exit(exitError)
+	}
+
+	if !validTrashBackend(trashBackend) {
+		fmt.Println(unknownValue("trash backend", trashBackend, trashBackends))
+		os.Exit(exitError)
+	}
+
+	if export != "" {
+		if _, _, err := parseExport(export); err != nil {
+			fmt.Println(err)
+			os.Exit(exitError)
+		}
+	}
+
+	if print0 {
+		if output != textOutput && output != fdupesOutput {
+			fmt.Printf("-print0 lists paths only, it can't be used with -output %s\n", output)
+			os.Exit(exitError)
+		}
+
+		output = fdupesOutput
+	}
+
+	var owner *ownership
+	if chownTo != "" {
+		o, err := parseOwnership(chownTo)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitError)
+		}
+
+		owner = &o
+	}
+
+	var tmpl *template.Template
+	if format != "" {
+		if output != textOutput {
+			fmt.Printf("-format shapes the output itself, it can't be used with -output %s or -print0\n", output)
+			os.Exit(exitError)
+		}
+
+		if tmpl, err = template.New("format").Parse(format); err != nil {
+			fmt.Printf("invalid -format template: %v\n", err)
+			os.Exit(exitError)
+		}
+
+		output = templateOutput
+	}
+
+	newHash, ok := hashers[hashName]
+	if !ok {
+		fmt.Println(unknownValue("hash algorithm", hashName, hasherNames()))
+		os.Exit(exitError)
+	}
+
+	strategy, err := parseSampleStrategy(sampleStrategy)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	// full hash runs check how samples of the size given would have fared, for the sample size tuning
 	sampleSize *= KB
+	tuneSample := sampleSize
+	if fullHash {
+		sampleSize = 0
+	}
+
+	opts := options{
+		action:        a,
+		fsLimit:       fsLimit,
+		verbose:       verbose,
+		roots:         roots,
+		filter:        filter,
+		prefer:        prefer,
+		skipManual:    skipManual,
+		dryRun:        dryRun,
+		verify:        verify,
+		asAdmin:       asAdmin,
+		quick:         quick,
+		match:         match,
+		matchesOut:    matchesFile,
+		marksFile:     marksFile,
+		target:        target,
+		keep:          keep,
+		bucketMax:     bucketMax,
+		bucketMode:    bucketMode,
+		sampleSize:    sampleSize,
+		tuneSample:    tuneSample,
+		strategy:      strategy,
+		newHash:       newHash,
+		manifest:      manifestFile,
+		settle:        settle,
+		protect:       protectPatterns,
+		acrossRoots:   acrossRoots,
+		sameDir:       sameDir,
+		expected:      expected,
+		mime:          mimePatterns,
+		promptTimeout: promptTimeout,
+		hashName:      hashName,
+		output:        output,
+		learn:         learn,
+		auditLog:      auditLog,
+		reportFile:    reportFile,
+		debugFDs:      debugFDs,
+		plain:         plain,
+		print0:        print0,
+		groupSep:      groupSep,
+		trashBackend:  trashBackend,
+		format:        tmpl,
+		storage:       localStorage{},
+		export:        export,
+		requireBackup: requireBackup,
+		maxDuration:   maxDuration,
+		top:           top,
+		minWaste:      int64(minWaste),
+		failOnDups:    failOnDups,
+		chownTo:       owner,
+		refuseMixed:   refuseMixed,
+		quiet:         quiet,
+		profile:       profile,
+		progress:      progress,
+		thumbs:        thumbCache{thumbCacheDir, int64(thumbCacheSize) << 20},
+		logLevel:      logLevel,
+		logFile:       logFile,
+		logFormat:     logFormat,
+		traceFile:     traceFile,
+		noColor:       noColor,
+		exactSizes:    exactSizes,
+		tui:           tui,
+		listen:        listen,
+	}
+
+	// sampling reads parts of files, storages which can only read whole files hash them whole
+	if !opts.storage.capabilities().has(capRangeRead) {
+		opts.sampleSize = 0
+	}
 
-	return a, fsLimit, verbose, roots, ignore, prefer, skipManual, dryRun, sampleSize
+	if err := validateOptions(opts); err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
 
+	return opts
 }
 
 func main() {
-	useAction, fsLimit, verbose, roots, ignore, prefer, skipManual, dryRun, sampleSize := getFlags()
+	setupDiagnostics()
+	defer exitWithCode()
+	defer recoverPanic()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "self-update":
+			if err := selfUpdate(); err != nil {
+				fmt.Printf("self-update failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "verify-matches":
+			if err := verifyMatches(os.Args[2:]); err != nil {
+				fmt.Printf("verify-matches failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "purge-marked":
+			if err := purgeMarked(os.Args[2:]); err != nil {
+				fmt.Printf("purge-marked failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "export-manifest":
+			if err := exportManifest(os.Args[2:]); err != nil {
+				fmt.Printf("export-manifest failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "check":
+			if err := runCheck(os.Args[2:]); err != nil {
+				fmt.Printf("check failed: %v\n", err)
+				setExitCode(exitError)
+			}
+			return
+		case "serve":
+			if err := runServe(os.Args[2:]); err != nil {
+				fmt.Printf("serve failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "cp":
+			if err := copyTree(os.Args[2:]); err != nil {
+				fmt.Printf("cp failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "apply":
+			if err := runApply(os.Args[2:]); err != nil {
+				fmt.Printf("apply failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "report":
+			if err := runReport(os.Args[2:]); err != nil {
+				fmt.Printf("report failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "cache":
+			if err := runCache(os.Args[2:]); err != nil {
+				fmt.Printf("cache failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "undo":
+			if err := runUndo(os.Args[2:]); err != nil {
+				fmt.Printf("undo failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		case "scan":
+			// scanning is what dblfinder does without a command, the flags of the scan follow
+			os.Args = append(os.Args[:1:1], os.Args[2:]...)
+		}
+	}
+
+	start := time.Now()
+
+	opts := getFlags()
+	plainMode = opts.plain
+	quietMode = opts.quiet
+	exactSizes = opts.exactSizes
+	colorMode = useColor(opts.noColor, opts.plain, os.LookupEnv)
+	tuneRuntime(opts.profile)
+
+	if opts.traceFile != "" {
+		closeTrace, err := setupTrace(opts.traceFile)
+		if err != nil {
+			fmt.Printf("can't create trace file: %v\n", err)
+			os.Exit(exitError)
+		}
+		defer closeTrace()
+	}
+
+	closeLog, err := setupLogging(opts.logLevel, opts.logFile, opts.logFormat)
+	if err != nil {
+		fmt.Printf("can't open log file: %v\n", err)
+		os.Exit(exitError)
+	}
+	defer closeLog()
+
+	if opts.listen != "" {
+		if err := runAPI(opts); err != nil {
+			fmt.Printf("listen failed: %v\n", err)
+			setExitCode(exitError)
+		}
+		return
+	}
+
+	if len(opts.roots) == 0 {
+		opts.roots = []string{"."}
+	}
+	roots := opts.roots
+
+	if opts.match == matchNameSize {
+		matches, unmatched, err := matchByNameSize(roots, opts.filter)
+		if err != nil {
+			fmt.Printf("filepath.Walk() returned an error: %v\n", err)
+			setExitCode(exitError)
+			return
+		}
+
+		listNameSizeMatches(matches, unmatched, roots[0])
+
+		if opts.matchesOut != "" {
+			if err := saveMatches(opts.matchesOut, matches); err != nil {
+				fmt.Printf("failed saving matches: %v\n", err)
+			}
+		}
+
+		return
+	}
+
+	if opts.manifest != "" {
+		backup, err := loadManifest(opts.manifest)
+		if err != nil {
+			fmt.Printf("failed loading manifest: %v\n", err)
+			os.Exit(exitError)
+		}
+
+		opts.backup = backup
+	}
+
+	var expected expectedDuplicates
+
+	if opts.expected != "" {
+		if expected, err = loadExpected(opts.expected); err != nil {
+			fmt.Printf("failed loading expected duplicates: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	defer updateTuning(defaultTuningFile(), roots, opts)
+	if !opts.quiet {
+		defer stats.report()
+	}
+	if opts.debugFDs {
+		defer fds.report(opts.fsLimit)
+	}
+
+	var (
+		sameHashFiles [][]string
+		hashes        map[string]string
+		decisions     []decision
+		reported      []reportGroup
+		found         func([]string, string)
+	)
 
-	if len(roots) == 0 {
-		roots = []string{"."}
+	if opts.export != "" {
+		defer func() {
+			_, db, _ := parseExport(opts.export)
+			if err := exportSQLite(db, reported, decisions, opts); err != nil {
+				fmt.Printf("failed exporting results: %v\n", err)
+				setExitCode(exitError)
+			}
+		}()
+	}
+
+	if opts.output != textOutput {
+		out := os.Stdout
+
+		if opts.reportFile != "" {
+			f, err := os.Create(opts.reportFile)
+			if err != nil {
+				fmt.Printf("failed creating report file: %v\n", err)
+				os.Exit(exitError)
+			}
+			defer f.Close()
+
+			out = f
+		} else {
+			// the report goes to stdout, progress messages are moved out of its way
+			os.Stdout = os.Stderr
+		}
+
+		switch opts.output {
+		case jsonOutput:
+			defer func() {
+				if err := writeJSONReport(out, newReport(reported, decisions, opts)); err != nil {
+					fmt.Printf("failed writing report: %v\n", err)
+					setExitCode(exitError)
+				}
+			}()
+		case csvOutput:
+			defer func() {
+				if err := writeCSVReport(out, reported, decisions, opts); err != nil {
+					fmt.Printf("failed writing report: %v\n", err)
+					setExitCode(exitError)
+				}
+			}()
+		case fdupesOutput:
+			defer func() {
+				if err := writeFdupesReport(out, reported, opts.print0, opts.groupSep); err != nil {
+					fmt.Printf("failed writing report: %v\n", err)
+					setExitCode(exitError)
+				}
+			}()
+		case htmlOutput:
+			defer func() {
+				if err := writeHTMLReport(out, reported, opts); err != nil {
+					fmt.Printf("failed writing report: %v\n", err)
+					setExitCode(exitError)
+				}
+			}()
+		case markdownOutput:
+			defer func() {
+				if err := writeMarkdownReport(out, reported, decisions, opts); err != nil {
+					fmt.Printf("failed writing report: %v\n", err)
+					setExitCode(exitError)
+				}
+			}()
+		case templateOutput:
+			defer func() {
+				if err := writeTemplateReport(out, reported, opts.format); err != nil {
+					fmt.Printf("failed writing report: %v\n", err)
+					setExitCode(exitError)
+				}
+			}()
+		case ndjsonOutput:
+			found = groupStreamer(out, opts, expected)
+		}
+	}
+
+	if opts.progress == progressJSON {
+		jsonProgress = newProgressEmitter(os.Stderr)
+		defer jsonProgress.finish()
 	}
 
-	fileSizes, err := getAllFileSizes(roots, ignore, verbose)
+	jsonProgress.setPhase(walkStage, 0)
+	stopWalk := stats.track(walkStage)
+	fileSizes, err := getAllFileSizes(roots, opts.filter)
+	stopWalk()
 	if err != nil {
 		fmt.Printf("filepath.Walk() returned an error: %v\n", err)
+		setExitCode(exitError)
 		return
 	} else {
-		fmt.Printf("Found %d unique file sizes\n", len(fileSizes))
+		progressf("Found %d unique file sizes\n", len(fileSizes))
+	}
+
+	sameSizeFiles, _ := filterSameSizeFiles(fileSizes)
+	if len(opts.mime) > 0 {
+		sameSizeFiles, _ = filterSameSizeFiles(filterMime(sameSizeFiles, opts.mime))
+	}
+
+	sameSizeFiles, linked := collapseHardlinks(sameSizeFiles)
+	sameSizeFiles, _ = filterSameSizeFiles(sameSizeFiles)
+	reportLinked(linked, opts.verbose)
+	if opts.quick {
+		listQuickGroups(quickGroups(sameSizeFiles))
+		return
 	}
 
-	sameSizeFiles, count := filterSameSizeFiles(fileSizes)
+	buckets, count := toBuckets(sameSizeFiles, opts.bucketMax, opts.bucketMode)
 	if count > 0 {
-		fmt.Printf("%d files need to be hashed:\n", count)
+		progressf("%d files need to be hashed:\n", count)
 	} else {
-		fmt.Printf("No files need to be hashed\n")
+		progressf("No files need to be hashed\n")
 		return
 	}
 
-	sameHashFiles, count := filterSameHashFiles(sameSizeFiles, fsLimit, sampleSize, verbose)
+	var deadline time.Time
+	if opts.maxDuration > 0 {
+		deadline = start.Add(opts.maxDuration)
+		buckets = interleaveBuckets(buckets, roots)
+	}
+
+	jsonProgress.setPhase(sampleStage, int64(count))
+	if opts.progress == progressAuto && showProgress() && !opts.verbose {
+		sampled := stats.stage(sampleStage)
+		hashProgress = newProgressBar(os.Stdout, int64(count), func() int64 { return atomic.LoadInt64(&sampled.bytes) })
+	}
+
+	stopSampling := stats.track(sampleStage)
+	sameHashFiles, hashes, count = filterSameHashFiles(buckets, opts.fsLimit, opts.sampleSize, opts.tuneSample, opts.strategy, opts.newHash, opts.verbose, deadline, found)
+	stopSampling()
+	hashProgress.finish()
 	if count > 0 {
-		fmt.Printf("%d files have duplicated hashes\n", count)
+		progressf("%d files have duplicated hashes\n", count)
 	} else {
-		fmt.Printf("No files have duplicated hashes\n")
+		progressf("No files have duplicated hashes\n")
 		return
 	}
 
-	execute(sameHashFiles, useAction, prefer, skipManual, dryRun)
+	if opts.acrossRoots {
+		sameHashFiles = acrossRootsOnly(sameHashFiles, roots)
+		progressf("%d group(s) span more than one root\n", len(sameHashFiles))
+	}
+
+	if opts.expected != "" {
+		n := len(sameHashFiles)
+		sameHashFiles = withoutExpected(sameHashFiles, expected, roots)
+		progressf("%d group(s) of expected duplicates ignored\n", n-len(sameHashFiles))
+	}
+
+	if opts.sameDir {
+		sameHashFiles = sameDirOnly(sameHashFiles)
+		progressf("%d group(s) of duplicates within a directory\n", len(sameHashFiles))
+	}
+
+	if opts.minWaste > 0 {
+		n := len(sameHashFiles)
+		sameHashFiles = minWasteOnly(sameHashFiles, opts.minWaste)
+		progressf("%d group(s) wasting less than %s ignored\n", n-len(sameHashFiles), humanSize(opts.minWaste))
+	}
+
+	sortByWaste(sameHashFiles)
+
+	if opts.top > 0 && len(sameHashFiles) > opts.top {
+		progressf("Only the %d of %d group(s) with the most reclaimable space are handled\n", opts.top, len(sameHashFiles))
+		sameHashFiles = sameHashFiles[:opts.top]
+	}
+
+	reported = newReportGroups(sameHashFiles, hashes)
+
+	if opts.failOnDups && len(reported) > 0 {
+		setExitCode(exitDuplicates)
+	}
+
+	if opts.output != textOutput && opts.reportFile == "" {
+		return
+	}
+
+	owned, crossUser := sameHashFiles, [][]string(nil)
+	if !opts.asAdmin {
+		owned, crossUser = splitByOwner(sameHashFiles, os.Getuid(), fileOwner)
+	}
+
+	var mixed [][]string
+	if opts.refuseMixed {
+		owned, mixed = splitMixedOwners(owned)
+	}
+
+	if opts.tui {
+		decisions = reviewGroups(owned, opts)
+	} else if decisions, err = execute(owned, opts); err != nil {
+		fmt.Printf("run %v\n", err)
+		setExitCode(exitError)
+		return
+	}
+
+	reportCrossUser(crossUser)
+	reportMixedOwners(mixed)
+
+	printSummary(summarize(reported, decisions, opts))
 }
 
 // getAllFileSizes scans root directories recursively and returns the path of each file found
-func getAllFileSizes(roots []string, ignore string, verbose bool) (map[int64][]string, error) {
+func getAllFileSizes(roots []string, filter walkFilter) (map[int64][]string, error) {
+	fileSizes := make(map[int64][]string)
+	ignores := newIgnoreTree(filter.ignoreFiles())
+
 	var (
-		ignoreRegexp *regexp.Regexp
+		rootPath     string
+		rootDevice   uint64
+		placeholders int
 	)
 
-	if ignore != "" {
-		ignoreRegexp = regexp.MustCompile(ignore)
-	}
+	// excluded returns why a file or directory is left out by the filters, or an empty string if it isn't
+	excluded := func(path string, f os.FileInfo) string {
+		switch {
+		case f.IsDir() && filter.skipDir(path), !f.IsDir() && filter.skipFile(path):
+			return "excluded"
+		case filter.hidden(path, f):
+			return "hidden"
+		case ignores.ignored(path, f.IsDir()):
+			return "gitignored"
+		}
 
-	fileSizes := make(map[int64][]string)
+		return ""
+	}
 
 	visit := func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			// an unreadable file or directory is skipped, the rest of the tree is still scanned
+			slog.Warn("can't read path", "path", path, "err", err)
+			traceSkip(path, "unreadable", "err", err)
+			setExitCode(exitError)
+
+			return nil
+		}
+
+		if filter.maxDepth > 0 && depth(rootPath, path) > filter.maxDepth {
+			traceSkip(path, "max depth")
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
 		if f.IsDir() {
+			if !isRoot(path, roots) {
+				if reason := excluded(path, f); reason != "" {
+					traceSkip(path, reason)
+					return filepath.SkipDir
+				}
+			}
+
+			if filter.oneFileSystem {
+				if dev, err := deviceID(path); err != nil || dev != rootDevice {
+					slog.Debug("not descending into another file system", "path", path)
+					traceSkip(path, "other file system")
+					return filepath.SkipDir
+				}
+			}
+
+			ignores.load(path)
+
 			return nil
 		}
 
-		if ignoreRegexp != nil && ignoreRegexp.MatchString(path) {
+		if reason := excluded(path, f); reason != "" {
+			traceSkip(path, reason)
 			return nil
 		}
 
-		p, err2 := filepath.EvalSymlinks(path)
-		if err2 != nil {
-			panic(err2)
+		p, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			slog.Warn("can't resolve symlinks", "path", path, "err", err)
+			traceSkip(path, "unreadable", "err", err)
+			setExitCode(exitError)
+
+			return nil
 		}
 		if p != path {
-			if verbose {
-				log.Printf("symlink found: %s <-> %s\n", p, path)
-			}
+			slog.Debug("symlink found", "path", path, "target", p)
+			traceSkip(path, "symlink", "target", p)
+			return nil
+		}
+
+		if filter.empty(f) {
+			traceSkip(path, "empty")
 			return nil
 		}
 
+		if filter.placeholder(f) {
+			slog.Debug("cloud placeholder skipped", "path", path)
+			traceSkip(path, "cloud placeholder")
+			placeholders++
+			return nil
+		}
+
+		trace("visited", "path", path, "size", f.Size())
+		stats.add(walkStage, 1, 0)
+		setCurrentPath(path)
+
 		if val, ok := fileSizes[f.Size()]; ok {
 			fileSizes[f.Size()] = append(val, path)
 		} else {
@@ -148,12 +966,27 @@ func getAllFileSizes(roots []string, ignore string, verbose bool) (map[int64][]s
 	}
 
 	for _, root := range roots {
+		rootPath = root
+
+		if filter.oneFileSystem {
+			dev, err := deviceID(root)
+			if err != nil {
+				return nil, err
+			}
+
+			rootDevice = dev
+		}
+
 		err := filepath.Walk(root, visit)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if placeholders > 0 {
+		progressf("%d cloud placeholder file(s) skipped, use -hydrate to download and compare them\n", placeholders)
+	}
+
 	for size, paths := range fileSizes {
 		fileSizes[size] = uniqueStrings(paths)
 	}
@@ -168,6 +1001,9 @@ func filterSameSizeFiles(fileSizes map[int64][]string) (map[int64][]string, int)
 
 	for size, files := range fileSizes {
 		if len(files) <= 1 {
+			for _, file := range files {
+				traceSkip(file, "unique size", "size", size)
+			}
 			continue
 		}
 
@@ -178,114 +1014,224 @@ func filterSameSizeFiles(fileSizes map[int64][]string) (map[int64][]string, int)
 	return sameSizeFiles, count
 }
 
-// filterSameHashFiles removes strings from a sameSizeFiles, and map all files that have a unique md5 hash
-func filterSameHashFiles(sameSizeFiles map[int64][]string, fsLimit, sampleSize int, verbose bool) ([][]string, int) {
+// filterSameHashFiles removes files with a unique hash from buckets of same size files and returns the rest grouped
+// Files are hashed in stages of growing sample sizes (see hashStages) and groups are dropped as soon as their files
+// diverge, so that files differing early on never need to be read in full. If found is not nil, it's called with each
+// group and its hash as soon as the group is confirmed. Once deadline passes (unless it's zero), the remaining buckets
+// are left unhashed.
+func filterSameHashFiles(buckets []bucket, fsLimit, sampleSize, tuneSample int, strategy []string, newHash func() hash.Hash, verbose bool, deadline time.Time, found func([]string, string)) ([][]string, map[string]string, int) {
 	var (
 		sameHashFiles [][]string
-		count, cur    int
+		hashes        = map[string]string{}
+		count         int
 	)
 
-	for _, files := range sameSizeFiles {
-		if verbose {
-			fmt.Printf("Hashing files: %v\n", files)
+	stages := hashStages(sampleSize, tuneSample)
+
+	for n, b := range buckets {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			hashProgress.finish()
+			fmt.Printf("-max-duration reached, %d bucket(s) of same size files were not hashed\n", len(buckets)-n)
+			for _, b := range buckets[n:] {
+				for _, file := range b.files {
+					traceSkip(file, "max duration reached")
+				}
+			}
+			break
 		}
 
-		uniqueHashes := getUniqueHashes(files, fsLimit, sampleSize, verbose)
+		var (
+			groups      = [][]string{b.files}
+			groupHashes []string
+			tuned       [][]string
+		)
 
-		for _, paths := range uniqueHashes {
-			if len(paths) > 1 {
-				sameHashFiles = append(sameHashFiles, paths)
-				count += len(paths)
+		for _, stage := range stages {
+			if verbose {
+				fmt.Printf("Hashing files (sample size: %d): %v\n", stage, groups)
+			}
+
+			groups, groupHashes = hashStage(groups, fsLimit, stage, strategy, newHash, verbose)
+
+			covered := sampleCoversFile(stage, strategy, b.size)
+			switch {
+			case stage == tuneSample && stage > 0:
+				tuned = groups
+			case covered && tuned != nil:
+				recordSampleFeedback(tuneSample, tuned, groups)
+			}
+
+			if len(groups) == 0 || covered {
+				break
+			}
+		}
+
+		hashProgress.advance(len(b.files))
+		jsonProgress.advance(len(b.files))
+
+		for i, paths := range groups {
+			sameHashFiles = append(sameHashFiles, paths)
+			count += len(paths)
+
+			for _, path := range paths {
+				hashes[path] = groupHashes[i]
+			}
+
+			if found != nil {
+				found(paths, groupHashes[i])
 			}
 		}
-		cur += 1
 	}
 
-	fmt.Println()
+	return sameHashFiles, hashes, count
+}
+
+// hashStages returns the sample sizes to hash files with in order, 0 meaning the whole file
+// Hashing whole files, the tuned sample size is a stage as well, so that it can be checked against the whole content.
+func hashStages(sampleSize, tuneSample int) []int {
+	var stages []int
+
+	for _, stage := range []int{firstStageSize, secondStageSize} {
+		if sampleSize > 0 && stage >= sampleSize {
+			break
+		}
+
+		stages = append(stages, stage)
+	}
+
+	if sampleSize == 0 && tuneSample > 0 {
+		if i := sort.SearchInts(stages, tuneSample); i == len(stages) || stages[i] != tuneSample {
+			stages = append(stages[:i], append([]int{tuneSample}, stages[i:]...)...)
+		}
+	}
+
+	return append(stages, sampleSize)
+}
+
+// hashStage splits groups of files by their hashes calculated on a sample size and drops the ones left alone
+func hashStage(groups [][]string, fsLimit, sampleSize int, strategy []string, newHash func() hash.Hash, verbose bool) ([][]string, []string) {
+	var (
+		res    [][]string
+		hashes []string
+	)
+
+	for _, files := range groups {
+		uniqueHashes := getUniqueHashes(files, fsLimit, sampleSize, strategy, newHash, verbose)
+
+		for sum, paths := range uniqueHashes {
+			if len(paths) > 1 {
+				res = append(res, paths)
+				hashes = append(hashes, sum)
+				continue
+			}
+
+			traceSkip(paths[0], "unique hash", "sample_size", sampleSize)
+		}
+	}
 
-	return sameHashFiles, count
+	return res, hashes
 }
 
-type md5ToHash struct {
+type pathToHash struct {
 	path string
-	md5  string
+	hash string
 	err  error
 }
 
-// hashWorker calculates the md5 hash value of a file and pushes it into a channel
-func hashWorker(path string, md5s chan *md5ToHash, sampleSize int, verbose bool) {
+// hashWorker calculates the hash value of a file and pushes it into a channel
+// Only sampleSize bytes at each position of the sample strategy are hashed, unless sampleSize is 0, in which case the
+// whole file is streamed through the hasher.
+func hashWorker(path string, hashes chan *pathToHash, sampleSize int, strategy []string, newHash func() hash.Hash, verbose bool) {
 	if verbose {
 		fmt.Printf("About to read \"%s\"\n", path)
 	}
 
-	fi, err := os.Stat(path)
-	if err != nil {
-		log.Fatalf("can't stat file: %s, err: %v", path, err)
-	}
+	setCurrentPath(path)
 
-	if fi.Size() < 1024 {
-		sampleSize = int(fi.Size())
+	f, err := fds.openFile(sampleStage, path)
+	if err != nil {
+		hashes <- &pathToHash{path, "", err}
+		return
 	}
 
-	f, err := os.Open(path)
+	fi, err := f.Stat()
 	if err != nil {
-		log.Fatal(err)
+		fds.closeFile(sampleStage, f)
+		hashes <- &pathToHash{path, "", fmt.Errorf("can't stat file: %v", err)}
+		return
 	}
 
-	data := make([]byte, sampleSize)
-
-	_, err = f.Read(data)
+	hasher := newHash()
+	n, err := io.CopyBuffer(hasher, sampleReader(f, fi.Size(), sampleSize, strategy), make([]byte, readBufferSize))
 	if err != nil {
-		log.Fatalf("error reading file: %s, err %v", path, err)
+		fds.closeFile(sampleStage, f)
+		hashes <- &pathToHash{path, "", fmt.Errorf("failed calculating hash: %v", err)}
+		return
 	}
 
-	if err := f.Close(); err != nil {
-		log.Fatalf("failed closing file: %s, err %v", path, err)
+	if err := fds.closeFile(sampleStage, f); err != nil {
+		hashes <- &pathToHash{path, "", fmt.Errorf("failed closing file: %v", err)}
+		return
 	}
 
-	md5Hasher := md5.New()
-	_, err = md5Hasher.Write(data)
-	if err != nil {
-		log.Fatalf("failed calculating hash for file: %s, err %v", path, err)
-	}
-	sum := md5Hasher.Sum(nil)
+	sum := hasher.Sum(nil)
+	trace("hashed", "path", path, "sample_size", sampleSize, "bytes", n, "hash", hex.EncodeToString(sum))
+
+	stats.add(sampleStage, 1, n)
 
 	if verbose {
-		fmt.Printf("calculated md5 for file: %s\n", path)
-	} else {
-		fmt.Print(".")
+		fmt.Printf("calculated hash for file: %s\n", path)
 	}
 
-	md5s <- &md5ToHash{path, string(sum), nil}
+	hashes <- &pathToHash{path, string(sum), nil}
 }
 
-// getUniqueHashes calculates the md5 hash of each file present in a map of sizes to paths of same size files
-func getUniqueHashes(files []string, fsLimit, samleSize int, verbose bool) map[string][]string {
-	md5s := make(chan *md5ToHash, fsLimit)
+// getUniqueHashes calculates the hash of each file present in a map of sizes to paths of same size files
+// At most fsLimit files are hashed at once, by as many workers, so that memory use doesn't grow with the files.
+func getUniqueHashes(files []string, fsLimit, samleSize int, strategy []string, newHash func() hash.Hash, verbose bool) map[string][]string {
+	hashes := make(chan *pathToHash, fsLimit)
+	paths := make(chan string)
 
-	for _, path := range files {
-		go hashWorker(path, md5s, samleSize, verbose)
+	for i := 0; i < fsLimit && i < len(files); i++ {
+		go func() {
+			defer recoverPanic()
+
+			for path := range paths {
+				hashWorker(path, hashes, samleSize, strategy, newHash, verbose)
+			}
+		}()
 	}
 
-	return getHashResults(md5s, len(files))
+	go func() {
+		defer recoverPanic()
+
+		for _, path := range files {
+			paths <- path
+		}
+		close(paths)
+	}()
+
+	return getHashResults(hashes, len(files))
 }
 
 // collects worker results
-func getHashResults(md5s chan *md5ToHash, max int) map[string][]string {
+func getHashResults(hashes chan *pathToHash, max int) map[string][]string {
 	uniqueHashes := make(map[string][]string)
 
 	for i := 0; i < max; i++ {
-		md5ToHash := <-md5s
+		pathToHash := <-hashes
 
-		if md5ToHash.err != nil {
-			fmt.Printf("\nhash returned an error: %v\n", md5ToHash.err)
+		if pathToHash.err != nil {
+			// the file is left out of the comparison, the others are still compared
+			slog.Error("can't hash file", "path", pathToHash.path, "err", pathToHash.err)
+			setExitCode(exitError)
 			continue
 		}
 
-		if val, ok := uniqueHashes[md5ToHash.md5]; ok {
-			uniqueHashes[md5ToHash.md5] = append(val, md5ToHash.path)
+		if val, ok := uniqueHashes[pathToHash.hash]; ok {
+			uniqueHashes[pathToHash.hash] = append(val, pathToHash.path)
 		} else {
-			uniqueHashes[md5ToHash.md5] = []string{md5ToHash.path}
+			uniqueHashes[pathToHash.hash] = []string{pathToHash.path}
 		}
 	}
 
@@ -293,82 +1239,407 @@ func getHashResults(md5s chan *md5ToHash, max int) map[string][]string {
 }
 
 // execute deletes duplicates based on rules (prefer) and user input (unless skipManual is set)
-func execute(sameSizeFiles [][]string, useAction action, prefer string, skipManual, dryRun bool) {
+// Decisions are only applied once all groups are decided, see apply for what each action does with them. If the user
+// aborts the run, errAborted is returned and nothing is acted on.
+func execute(sameSizeFiles [][]string, opts options) ([]decision, error) {
+	preferRegexps := compilePreferred(opts.prefer)
+
 	var (
-		preferRegexp *regexp.Regexp
+		undecided [][]string
+		learner   = newDirLearner()
+		learned   []decision
 	)
 
-	if prefer != "" {
-		preferRegexp = regexp.MustCompile(prefer)
-	}
+	// in quiet mode groups are decided automatically, their files are only listed as the result of the list action
+	show := !opts.quiet || opts.action == listAction
+
+	progressf("\n")
 
-	fmt.Println()
+	var (
+		decisions []decision
+		skipRest  bool
+	)
 
+groups:
 	for i, files := range sameSizeFiles {
-		fmt.Printf("The following files are the same (%d / %d):\n", i, len(sameSizeFiles))
+		size := ""
+		if fi, err := os.Stat(files[0]); err == nil {
+			size = ", " + humanSize(fi.Size()) + " each"
+		}
+
+		if opts.plain {
+			progressf("Group %d of %d, %d identical files%s:\n", i+1, len(sameSizeFiles), len(files), size)
+		} else {
+			progressf("%s\n", colorize(headerColor, fmt.Sprintf("The following files are the same (%d / %d)%s:", i, len(sameSizeFiles), size)))
+		}
+
+		// files of large groups are listed a page at a time once all of them are known, unless just listing them
+		paginate := len(files) > groupPageSize && opts.action != listAction
 
 		var answerMap = map[int]string{}
+		preferred, preferRank := preferredFiles(files, preferRegexps)
 		for key, file := range files {
-			if preferRegexp != nil && preferRegexp.MatchString(file) {
-				fmt.Printf("[preferred] %s\n", file)
+			if matchAny(opts.protect, file) {
+				if show {
+					fmt.Printf("%s %s\n", colorize(protectedColor, fileLabel("protected", opts.plain)), file)
+				}
 				continue
 			}
 
-			fmt.Printf("[%d] %s\n", key+1, file)
+			if preferred[key] {
+				if show {
+					fmt.Printf("%s %s\n", colorize(preferredColor, fileLabel("preferred", opts.plain)), colorize(preferredColor, file))
+				}
+				continue
+			}
+
+			if show && !paginate {
+				fmt.Printf("%s %s%s%s\n", fileLabel(strconv.Itoa(key+1), opts.plain), file, fileDetails(file), typeNote(file))
+			}
 
 			answerMap[key] = file
 		}
 
-		if useAction == listAction {
+		if show && paginate {
+			listChoices(answerMap, 0, "")
+		}
+
+		backedUp := reportBackupCopy(files, opts.backup)
+
+		if opts.action == listAction {
 			fmt.Printf("\n")
 			continue
 		}
 
-		if len(answerMap) == len(files) && skipManual {
-			fmt.Printf("Preferred file not found, deletion skipped.\n\n")
+		if opts.requireBackup && !backedUp {
+			progressf("Content not found in the backup, deletion skipped.\n\n")
 			continue
 		}
 
-		var deleteFiles []string
-		if !skipManual && useAction == keepAction {
-			deleteFiles = readKeep(answerMap, len(files))
+		if len(answerMap) == len(files) && opts.skipManual && opts.keep == "" {
+			progressf("Preferred file not found, deletion skipped.\n\n")
+			continue
+		}
+
+		if len(answerMap) == len(files) && opts.action == deleteAction && opts.keep == "" {
+			progressf("Preferred file not found, no clear survivor, deletion skipped.\n\n")
+			continue
+		}
+
+		var (
+			deleteFiles []string
+			reason      = manualReason
+			learnt      bool
+		)
+
+		switch {
+		case opts.keep != "":
+			deleteFiles = applyKeepPolicy(files, answerMap, opts.keep, opts.roots, opts.action == deleteAction)
+			reason = keepReason(opts.keep, preferRank)
+		case opts.action == deleteAction:
+			deleteFiles = notPreferred(answerMap)
+			reason = preferReason(preferRank)
+		case !opts.skipManual && !skipRest:
+			var (
+				prefs   []dirPreference
+				ok      bool
+				outcome promptOutcome
+			)
+			if deleteFiles, prefs, ok = learner.decide(files, answerMap); ok {
+				fmt.Printf("Keeping (learned, %s): %s\n", joinPreferences(prefs), keptFile(files, deleteFiles))
+				reason, learnt = learnedReason(prefs), true
+				break
+			}
+
+			deleteFiles, outcome = readKeep(answerMap, len(files), opts.promptTimeout)
+			switch outcome {
+			case promptTimedOut:
+				fmt.Printf("\nNo answer within %s, group left undecided.\n\n", opts.promptTimeout)
+				undecided = append(undecided, files)
+				continue
+			case promptSkipRest:
+				fmt.Printf("Skipping the remaining groups which need an answer.\n\n")
+				skipRest = true
+				continue
+			case promptQuit:
+				fmt.Printf("Quitting, %d group(s) left undecided.\n\n", len(sameSizeFiles)-i)
+				break groups
+			case promptAborted:
+				return nil, errAborted
+			}
+
+			if err := offerPreferences(learner, learner.observe(files, deleteFiles), opts); err != nil {
+				return nil, err
+			}
 		}
 
 		if len(deleteFiles) == 0 {
-			fmt.Printf("Deletion skipped.\n\n")
+			progressf("Deletion skipped.\n\n")
 			continue
 		}
 
 		if len(deleteFiles) == len(files) {
-			fmt.Printf("All files marked for deletion, therefore aborting!\n\n")
+			progressf("All files marked for deletion, therefore aborting!\n\n")
+			continue
+		}
+
+		deleteFiles = withoutProtected(deleteFiles, opts.protect)
+		if len(deleteFiles) == 0 {
+			progressf("Deletion skipped.\n\n")
 			continue
 		}
 
-		deleteOtherFiles(deleteFiles, dryRun)
+		keep := keptFile(files, deleteFiles)
 
-		fmt.Printf("\n\n")
+		if opts.settle > 0 {
+			deleteFiles = settledFiles(deleteFiles, opts.settle, time.Now())
+			if len(deleteFiles) == 0 {
+				progressf("Deletion skipped.\n\n")
+				continue
+			}
+		}
+
+		if opts.verify {
+			deleteFiles = verifyDeleteFiles(keep, deleteFiles)
+		}
+
+		if reason != manualReason {
+			progressf("Reason: %s\n", reason)
+		}
+
+		if learnt {
+			learned = append(learned, decision{keep, deleteFiles, reason})
+			fmt.Printf("%d file(s) will be %s if confirmed.\n\n", len(deleteFiles), describeAction(opts))
+			continue
+		}
+
+		decisions = append(decisions, decision{keep, deleteFiles, reason})
+
+		progressf("%d file(s) will be %s.\n\n", len(deleteFiles), describeAction(opts))
+	}
+
+	reportUndecided(undecided)
+
+	agreed, err := reportLearned(learned, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if agreed {
+		decisions = append(decisions, learned...)
+	}
+
+	return act(decisions, opts), nil
+}
+
+// act applies the decisions made, changing the owner of the files kept and recording the decisions in the audit log
+// if needed
+func act(decisions []decision, opts options) []decision {
+	// ownership is recorded before acting, as the files acted on may be gone afterwards
+	owners := groupOwnerships(decisions)
+	sizes := actedSizes(decisions)
+
+	apply(decisions, opts)
+	countReclaimed(decisions, sizes, opts)
+
+	if opts.chownTo != nil {
+		chownKept(decisions, owners, *opts.chownTo, opts.dryRun)
+	}
+
+	if opts.auditLog != "" {
+		if err := writeAuditLog(opts.auditLog, decisions, owners, opts); err != nil {
+			fmt.Printf("failed writing audit log: %v\n", err)
+		}
+	}
+
+	return decisions
+}
+
+// keptFile returns the first file of a group which is not marked for deletion
+func keptFile(files, deleteFiles []string) string {
+	marked := map[string]bool{}
+	for _, file := range deleteFiles {
+		marked[file] = true
+	}
+
+	for _, file := range files {
+		if !marked[file] {
+			return file
+		}
+	}
+
+	return ""
+}
+
+// applyKeepPolicy picks the file to keep with a keep policy and returns the other files which are not preferred
+// If there are preferred files, the file to keep is picked among those. If strict is set, nothing is returned
+// when several candidates are equally good to keep.
+func applyKeepPolicy(files []string, answerMap map[int]string, policy string, roots []string, strict bool) []string {
+	var preferred []string
+	for key, file := range files {
+		if _, ok := answerMap[key]; !ok {
+			preferred = append(preferred, file)
+		}
+	}
+
+	candidates := files
+	if len(preferred) > 0 {
+		candidates = preferred
+	}
+
+	keep, tied, err := rankSurvivor(candidates, policy, roots)
+	if err != nil {
+		fmt.Printf("%s policy failed: %v\n", policy, err)
+		return nil
+	}
+
+	if strict && tied {
+		fmt.Printf("No clear survivor (%s).\n", policy)
+		return nil
 	}
+
+	progressf("Keeping (%s): %s\n", policy, keep)
+
+	var res []string
+	for _, file := range answerMap {
+		if file != keep {
+			res = append(res, file)
+		}
+	}
+
+	sort.Strings(res)
+
+	return res
 }
 
+// notPreferred returns the files of a group which are not preferred
+func notPreferred(answerMap map[int]string) []string {
+	var res []string
+	for _, file := range answerMap {
+		res = append(res, file)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// promptOutcome tells how the keep prompt of a group was left
+type promptOutcome int
+
+const (
+	// promptAnswered means the files to delete were chosen, possibly none of them
+	promptAnswered promptOutcome = iota
+	// promptTimedOut means no answer arrived in time, the group is left undecided
+	promptTimedOut
+	// promptQuit means no more groups are to be decided, the decisions made so far are acted on
+	promptQuit
+	// promptSkipRest means the remaining groups are only handled if decided without asking
+	promptSkipRest
+	// promptAborted means the user aborted the run, none of the groups are acted on
+	promptAborted
+)
+
+// groupPageSize is the number of files of a group listed at once in the keep prompt
+const groupPageSize = 20
+
+// keepHelp lists the commands understood by the keep prompt
+const keepHelp = `Commands:
+  1 2 3, 2-3   keep the files listed
+  !3 5, d 3 5  delete the files listed
+  invert       switch between listing the files to keep and the files to delete
+  a            keep all the files of the group, skipping it
+  s            skip this group and the remaining ones which need an answer
+  q            quit, acting on the groups decided so far and summarizing
+  o N          open file N with the system's default application
+  n, p         list the next or the previous page of the files of a large group
+  /text        only list the files whose path contains text, "/" lists all of them again
+  repeat       list the files of the group again
+  ?            show this help`
+
 // readKeep reads standard in to figure out which duplicates to keep
-func readKeep(answerMap map[int]string, max int) []string {
+// Answers starting with "!" or "d " list the duplicates to delete instead, and "invert" switches the question between
+// keeping and deleting. Other commands are listed by keepHelp, unknown answers are asked again. If no answer arrives
+// within timeout (if positive), the group is left undecided.
+func readKeep(answerMap map[int]string, max int, timeout time.Duration) ([]string, promptOutcome) {
 	var (
-		parsed []int
-		res    []string
-		ok     bool
+		parsed     []int
+		ok         bool
+		deleteMode bool
+		deleting   bool
+		page       int
+		filter     string
 	)
 
-	fmt.Println("Which one of these should we keep? (eg: 1 2 3, 2-3)")
+	fmt.Println(keepQuestion(deleteMode))
 
-	scanner := bufio.NewScanner(os.Stdin)
 	for !ok {
-		scanner.Scan()
-		s := scanner.Text()
+		s, answered, err := readLine(timeout)
+		if err != nil {
+			return nil, promptAborted
+		}
+		if !answered {
+			return nil, promptTimedOut
+		}
+
+		// nothing was chosen, so when asked which ones to delete, nothing is deleted
 		if s == "" {
+			deleting = deleteMode
 			break
 		}
 
-		parsed, ok = parseRead(s, max)
+		fields := strings.Fields(s)
+
+		s = strings.TrimSpace(s)
+
+		switch s {
+		case "invert":
+			deleteMode = !deleteMode
+			fmt.Println(keepQuestion(deleteMode))
+			continue
+		case "repeat":
+			page = listChoices(answerMap, page, filter)
+			fmt.Println(keepQuestion(deleteMode))
+			continue
+		case "n", "p":
+			if s == "n" {
+				page++
+			} else {
+				page--
+			}
+
+			page = listChoices(answerMap, page, filter)
+			fmt.Println(keepQuestion(deleteMode))
+			continue
+		case "?", "help":
+			fmt.Println(keepHelp)
+			fmt.Println(keepQuestion(deleteMode))
+			continue
+		case "a":
+			return nil, promptAnswered
+		case "s":
+			return nil, promptSkipRest
+		case "q":
+			return nil, promptQuit
+		}
+
+		if strings.HasPrefix(s, "/") {
+			filter, page = strings.TrimPrefix(s, "/"), 0
+			listChoices(answerMap, page, filter)
+			fmt.Println(keepQuestion(deleteMode))
+			continue
+		}
+
+		if len(fields) == 2 && fields[0] == "o" {
+			openChoice(answerMap, fields[1])
+			fmt.Print("again: ")
+			continue
+		}
+
+		var selection string
+		selection, deleting = parseSelection(s, deleteMode)
+
+		parsed, ok = parseRead(selection, max)
 		if !ok {
 			fmt.Print("again: ")
 			continue
@@ -380,18 +1651,159 @@ func readKeep(answerMap map[int]string, max int) []string {
 		}
 	}
 
-	for _, v := range parsed {
-		_, ok := answerMap[v-1]
-		if !ok {
-			fmt.Print("again: ")
-			continue
+	return selectDeletions(answerMap, parsed, deleting), promptAnswered
+}
+
+// openChoice opens the file of a group listed with the given number with the system's default application
+func openChoice(answerMap map[int]string, number string) {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		fmt.Printf("not a file number: %s\n", number)
+		return
+	}
+
+	file, ok := answerMap[n-1]
+	if !ok {
+		fmt.Printf("no file listed as %d\n", n)
+		return
+	}
+
+	if err := openWithSystem(file); err != nil {
+		fmt.Printf("can't open file: %s, err %v\n", file, err)
+	}
+}
+
+// fileLabel returns the label a file of a group is listed with, a number to choose it by or its role in the group
+// In plain mode labels are words which read well to screen readers instead of brackets.
+func fileLabel(label string, plain bool) string {
+	if !plain {
+		return "[" + label + "]"
+	}
+
+	if _, err := strconv.Atoi(label); err == nil {
+		return "File " + label + ":"
+	}
+
+	return strings.ToUpper(label[:1]) + label[1:] + " file:"
+}
+
+// fileDetails returns the metadata of a file telling which copy is the original, to be listed next to its path
+// The modification time, size, owner, permissions and the number of hard links are listed, as far as available.
+func fileDetails(path string) string {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return ""
+	}
+
+	details := []string{fi.ModTime().Format("2006-01-02 15:04"), humanSize(fi.Size())}
+
+	if uid, ok := fileOwner(path); ok {
+		details = append(details, userName(uid))
+	}
+
+	details = append(details, fi.Mode().Perm().String())
+
+	if n, err := linkCount(path); err == nil && n > 1 {
+		details = append(details, fmt.Sprintf("%d links", n))
+	}
+
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+// pageChoices returns the keys of the files of a group listed on a page, out of the files whose path contains filter
+// The page is clamped to the pages available, it's returned along with the number of pages.
+func pageChoices(answerMap map[int]string, page int, filter string) ([]int, int, int) {
+	var keys []int
+	for key, file := range answerMap {
+		if strings.Contains(file, filter) {
+			keys = append(keys, key)
 		}
+	}
 
-		delete(answerMap, v-1)
+	sort.Ints(keys)
+
+	pages := (len(keys) + groupPageSize - 1) / groupPageSize
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	from, to := page*groupPageSize, (page+1)*groupPageSize
+	if to > len(keys) {
+		to = len(keys)
 	}
 
-	for _, f := range answerMap {
-		res = append(res, f)
+	return keys[from:to], page, pages
+}
+
+// listChoices lists a page of the files of a group which can be chosen in the keep prompt, out of the files whose
+// path contains filter, and returns the page listed
+func listChoices(answerMap map[int]string, page int, filter string) int {
+	keys, page, pages := pageChoices(answerMap, page, filter)
+
+	for _, key := range keys {
+		fmt.Printf("%s %s%s%s\n", fileLabel(strconv.Itoa(key+1), plainMode), answerMap[key], fileDetails(answerMap[key]), typeNote(answerMap[key]))
+	}
+
+	switch {
+	case len(keys) == 0:
+		fmt.Printf("No files containing %q, \"/\" lists all of them again.\n", filter)
+	case pages > 1 || filter != "":
+		fmt.Printf("Page %d of %d, \"n\" and \"p\" list the next and previous pages, \"/text\" only the files containing text.\n", page+1, pages)
+	}
+
+	return page
+}
+
+// keepQuestion returns the question asked for a group, depending on whether the files to keep or to delete are asked
+func keepQuestion(deleteMode bool) string {
+	question := "Which one of these should we keep? (eg: 1 2 3, 2-3, \"!3 5\" or \"d 3 5\" to list the ones to delete instead)"
+	if deleteMode {
+		question = "Which ones should we delete? (eg: 1 2 3, 2-3, \"invert\" to list the ones to keep instead)"
+	}
+
+	if plainMode {
+		question += " Answer \"repeat\" to list the files again."
+	}
+
+	question += " \"?\" lists the other commands."
+
+	return question
+}
+
+// parseSelection strips the prefix of a negative selection from an answer and returns whether it lists files to delete
+func parseSelection(s string, deleteMode bool) (string, bool) {
+	switch {
+	case strings.HasPrefix(s, "!"):
+		return strings.TrimSpace(s[1:]), true
+	case strings.HasPrefix(s, "d "):
+		return strings.TrimSpace(s[2:]), true
+	}
+
+	return s, deleteMode
+}
+
+// selectDeletions returns the files to delete given the numbers of the files either to keep or to delete
+func selectDeletions(answerMap map[int]string, parsed []int, deleting bool) []string {
+	selected := map[int]bool{}
+	for _, v := range parsed {
+		selected[v-1] = true
+	}
+
+	var keys []int
+	for key := range answerMap {
+		keys = append(keys, key)
+	}
+
+	sort.Ints(keys)
+
+	var res []string
+	for _, key := range keys {
+		if selected[key] == deleting {
+			res = append(res, answerMap[key])
+		}
 	}
 
 	return res
@@ -486,22 +1898,89 @@ func generateRange(from, to string, max int) ([]int, bool) {
 }
 
 // deleteOtherFiles deletes a list of files, unless dryRun is set
+// Files are removed in batches per device and every directory touched by a batch is synced before the next
+// batch starts, so an interrupted run leaves each filesystem in a consistent state.
 func deleteOtherFiles(deleteFiles []string, dryRun bool) {
-	for _, file := range deleteFiles {
-		if dryRun {
-			fmt.Printf("Removing: %s (skipped)\n", file)
-			continue
+	for _, batch := range groupByDevice(deleteFiles) {
+		dirs := map[string]bool{}
+
+		for _, file := range batch {
+			if dryRun {
+				fmt.Printf("%s %s (skipped)\n", colorize(deleteColor, "Removing:"), file)
+				continue
+			}
+
+			fmt.Printf("%s %s\n", colorize(deleteColor, "Removing:"), file)
+
+			err := os.Remove(file)
+			if err != nil {
+				fmt.Printf("%s\n", colorize(errorColor, err.Error()))
+			} else {
+				fmt.Println("done.")
+				dirs[filepath.Dir(file)] = true
+			}
+		}
+
+		for _, dir := range sortedKeys(dirs) {
+			if err := syncDir(dir); err != nil {
+				fmt.Printf("failed syncing directory: %s, err %v\n", dir, err)
+			}
 		}
+	}
+}
 
-		fmt.Printf("Removing: %s\n", file)
+// groupByDevice splits a list of files into batches of files residing on the same device, ordered by device
+func groupByDevice(files []string) [][]string {
+	var (
+		devices []uint64
+		batches = map[uint64][]string{}
+	)
 
-		err := os.Remove(file)
+	for _, file := range files {
+		dev, err := deviceID(file)
 		if err != nil {
-			fmt.Printf("%v\n", err)
-		} else {
-			fmt.Println("done.")
+			fmt.Printf("can't determine device of file: %s, err %v\n", file, err)
+		}
+
+		if _, ok := batches[dev]; !ok {
+			devices = append(devices, dev)
 		}
+
+		batches[dev] = append(batches[dev], file)
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i] < devices[j] })
+
+	var res [][]string
+	for _, dev := range devices {
+		res = append(res, batches[dev])
 	}
+
+	return res
+}
+
+// sortedKeys returns the keys of a set of strings in order
+func sortedKeys(set map[string]bool) []string {
+	var res []string
+	for key := range set {
+		res = append(res, key)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// sortedSizes returns the sizes of same size files, largest first
+func sortedSizes(sameSizeFiles map[int64][]string) []int64 {
+	var sizes []int64
+	for size := range sameSizeFiles {
+		sizes = append(sizes, size)
+	}
+
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	return sizes
 }
 
 // uniqueInts returns unique integers from a list of integers
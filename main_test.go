@@ -1,8 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_parseRead(t *testing.T) {
@@ -162,4 +169,385 @@ func Test_uniqueStrings(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func Test_groupByDevice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for _, path := range []string{a, b} {
+		if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type args struct {
+		files []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want [][]string
+	}{
+		{
+			"empty",
+			args{
+				nil,
+			},
+			nil,
+		},
+		{
+			"same-device-keeps-order",
+			args{
+				[]string{b, a},
+			},
+			[][]string{{b, a}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupByDevice(tt.args.files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groupByDevice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getUniqueHashes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := ioutil.WriteFile(a, []byte("same-prefix-1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("same-prefix-2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		files      []string
+		sampleSize int
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			"sample-covers-prefix-only",
+			args{
+				[]string{a, b},
+				4,
+			},
+			1,
+		},
+		{
+			"full-hash",
+			args{
+				[]string{a, b},
+				0,
+			},
+			2,
+		},
+		{
+			"unreadable-file-skipped",
+			args{
+				[]string{a, b, filepath.Join(dir, "missing")},
+				4,
+			},
+			1,
+		},
+	}
+	defer func() { exitCode = exitOK }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getUniqueHashes(tt.args.files, 2, tt.args.sampleSize, []string{sampleHead}, hashers[defaultHash], false); len(got) != tt.want {
+				t.Errorf("getUniqueHashes() = %v, want %d unique hashes", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hashStages(t *testing.T) {
+	type args struct {
+		sampleSize int
+		tuneSample int
+	}
+	tests := []struct {
+		name string
+		args args
+		want []int
+	}{
+		{
+			"small-sample",
+			args{
+				1024,
+				1024,
+			},
+			[]int{1024},
+		},
+		{
+			"medium-sample",
+			args{
+				20480,
+				20480,
+			},
+			[]int{firstStageSize, 20480},
+		},
+		{
+			"full-hash",
+			args{
+				0,
+				0,
+			},
+			[]int{firstStageSize, secondStageSize, 0},
+		},
+		{
+			"full-hash-tuning-stage",
+			args{
+				0,
+				secondStageSize,
+			},
+			[]int{firstStageSize, secondStageSize, 0},
+		},
+		{
+			"full-hash-tuning-sample",
+			args{
+				0,
+				20480,
+			},
+			[]int{firstStageSize, 20480, secondStageSize, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hashStages(tt.args.sampleSize, tt.args.tuneSample); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hashStages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_filterSameHashFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := strings.Repeat("x", firstStageSize)
+	files := map[string]string{
+		"a":      prefix + "a",
+		"a-dup":  prefix + "a",
+		"b":      prefix + "b",
+		"c":      "c" + prefix,
+		"c-late": "c" + prefix[1:] + "y",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buckets := []bucket{
+		{firstStageSize + 1, []string{
+			filepath.Join(dir, "a"),
+			filepath.Join(dir, "a-dup"),
+			filepath.Join(dir, "b"),
+			filepath.Join(dir, "c"),
+			filepath.Join(dir, "c-late"),
+		}},
+	}
+
+	defer func(f *feedback) { sampleFeedback = f }(sampleFeedback)
+	sampleFeedback = &feedback{}
+
+	// samples of 8 bytes are checked against the whole content, as runs with -sample-size 8 would use them
+	got, hashes, count := filterSameHashFiles(buckets, 2, 0, 8, []string{sampleHead}, hashers[defaultHash], false, time.Time{}, nil)
+	want := [][]string{{filepath.Join(dir, "a"), filepath.Join(dir, "a-dup")}}
+
+	for _, paths := range got {
+		sort.Strings(paths)
+	}
+
+	if !reflect.DeepEqual(got, want) || count != 2 {
+		t.Errorf("filterSameHashFiles() = %v, %d, want %v, %d", got, count, want, 2)
+	}
+
+	if len(hashes) != 2 || hashes[want[0][0]] == "" || hashes[want[0][0]] != hashes[want[0][1]] {
+		t.Errorf("filterSameHashFiles() hashes = %v, want the same hash for %v", hashes, want[0])
+	}
+
+	for _, res := range sampleFeedback.results {
+		if res.sampleSize != 8 || !res.falsePositive {
+			t.Errorf("filterSameHashFiles() recorded %+v, want false positives of samples of 8 bytes", res)
+		}
+	}
+	if len(sampleFeedback.results) != 2 {
+		t.Errorf("filterSameHashFiles() recorded %d sample results, want 2", len(sampleFeedback.results))
+	}
+}
+
+func Test_selectDeletions(t *testing.T) {
+	answerMap := map[int]string{0: "a", 1: "b", 2: "c", 4: "e"}
+
+	tests := []struct {
+		name       string
+		answer     string
+		deleteMode bool
+		want       []string
+	}{
+		{"keep", "1 2", false, []string{"c", "e"}},
+		{"negative-bang", "!3 5", false, []string{"c", "e"}},
+		{"negative-d", "d 1-2", false, []string{"a", "b"}},
+		{"delete-mode", "2", true, []string{"b"}},
+		{"bang-in-delete-mode", "! 1", true, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, deleting := parseSelection(tt.answer, tt.deleteMode)
+
+			parsed, ok := parseRead(selection, 5)
+			if !ok {
+				t.Fatalf("parseRead(%q) failed", selection)
+			}
+
+			if got := selectDeletions(answerMap, parsed, deleting); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectDeletions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fileLabel(t *testing.T) {
+	tests := []struct {
+		label string
+		plain bool
+		want  string
+	}{
+		{"2", false, "[2]"},
+		{"preferred", false, "[preferred]"},
+		{"2", true, "File 2:"},
+		{"protected", true, "Protected file:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := fileLabel(tt.label, tt.plain); got != tt.want {
+				t.Errorf("fileLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readKeep(t *testing.T) {
+	stdinLines = make(chan string, 10)
+	stdinOnce.Do(func() {})
+
+	answerMap := map[int]string{0: "a", 1: "b", 2: "c"}
+
+	tests := []struct {
+		name    string
+		lines   []string
+		want    []string
+		outcome promptOutcome
+	}{
+		{"keep", []string{"2"}, []string{"a", "c"}, promptAnswered},
+		{"unknown-command-asked-again", []string{"x", "?", "1"}, []string{"b", "c"}, promptAnswered},
+		{"keep-all", []string{"a"}, nil, promptAnswered},
+		{"skip-rest", []string{"s"}, nil, promptSkipRest},
+		{"quit", []string{"q"}, nil, promptQuit},
+		{"open-unknown-file", []string{"o 7", "q"}, nil, promptQuit},
+		{"filtered-pages", []string{"/b", "n", "p", "/", "1 2"}, []string{"c"}, promptAnswered},
+		{"empty-delete-answer", []string{"invert", ""}, nil, promptAnswered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, line := range tt.lines {
+				stdinLines <- line
+			}
+
+			got, outcome := readKeep(answerMap, 3, 0)
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) || outcome != tt.outcome {
+				t.Errorf("readKeep() = %v, %v, want %v, %v", got, outcome, tt.want, tt.outcome)
+			}
+		})
+	}
+}
+
+func Test_pageChoices(t *testing.T) {
+	answerMap := map[int]string{}
+	for i := 0; i < 45; i++ {
+		answerMap[i] = fmt.Sprintf("gen/%02d.o", i)
+	}
+	answerMap[45] = "src/main.c"
+
+	tests := []struct {
+		name      string
+		page      int
+		filter    string
+		wantFirst int
+		wantLen   int
+		wantPage  int
+		wantPages int
+	}{
+		{"first-page", 0, "", 0, groupPageSize, 0, 3},
+		{"last-page", 2, "", 40, 6, 2, 3},
+		{"clamped-page", 5, "", 40, 6, 2, 3},
+		{"filtered", 1, "main", 45, 1, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, page, pages := pageChoices(answerMap, tt.page, tt.filter)
+
+			if len(keys) != tt.wantLen || keys[0] != tt.wantFirst || page != tt.wantPage || pages != tt.wantPages {
+				t.Errorf("pageChoices() = %v, %d, %d", keys, page, pages)
+			}
+		})
+	}
+}
+
+func Test_fileDetails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := ioutil.WriteFile(a, []byte("abcd"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(a, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(a, b); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 15, 4, 0, 0, time.Local)
+	if err := os.Chtimes(a, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	got := fileDetails(a)
+	for _, want := range []string{" (2020-01-02 15:04, 4 B, ", "-rw-r-----", ", 2 links)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("fileDetails() = %q, want it to contain %q", got, want)
+		}
+	}
+
+	if got := fileDetails(filepath.Join(dir, "missing")); got != "" {
+		t.Errorf("fileDetails() = %q for a missing file, want none", got)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestEntry describes a file listed in a manifest
+// The fields are a subset of what `restic ls --json` and `borg list --json-lines` print, so their output can be
+// used as a manifest directly. Restic doesn't list content hashes, borg does with `--format '{sha256}'`, such entries
+// can only be matched by name and size.
+type manifestEntry struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256,omitempty"`
+	Type       string `json:"type,omitempty"`
+	StructType string `json:"struct_type,omitempty"`
+}
+
+// manifest holds the regular files of a manifest, grouped by their size
+type manifest map[int64][]manifestEntry
+
+// isFile returns true if an entry is a regular file, restic calls those "file", borg "-"
+func (e manifestEntry) isFile() bool {
+	if e.StructType != "" && e.StructType != "node" {
+		return false
+	}
+
+	return e.Type == "" || e.Type == "file" || e.Type == "-"
+}
+
+// loadManifest reads a manifest stored as JSON lines
+// Instead of a file, a restic repository (restic:<repo>, using its latest snapshot) or a borg archive
+// (borg:<repo>::<archive>) can be given, which are listed with the restic and borg tools.
+func loadManifest(manifestFile string) (manifest, error) {
+	switch {
+	case strings.HasPrefix(manifestFile, "restic:"):
+		return listBackup(exec.Command("restic", "-r", strings.TrimPrefix(manifestFile, "restic:"), "ls", "--json", "latest"))
+	case strings.HasPrefix(manifestFile, "borg:"):
+		return listBackup(exec.Command("borg", "list", "--json-lines", "--format", "{sha256}", strings.TrimPrefix(manifestFile, "borg:")))
+	}
+
+	f, err := os.Open(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readManifest(f)
+}
+
+// listBackup reads the manifest of a backup from the output of a backup tool
+// Credentials are expected to be set in the environment as usual for the tool, eg. RESTIC_PASSWORD or BORG_PASSPHRASE.
+func listBackup(cmd *exec.Cmd) (manifest, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %v %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return readManifest(bytes.NewReader(out))
+}
+
+// readManifest parses JSON lines into a manifest, skipping entries which are not regular files
+func readManifest(r io.Reader) (manifest, error) {
+	m := manifest{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var e manifestEntry
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			return nil, fmt.Errorf("can't parse line %d: %v", line, err)
+		}
+
+		if e.isFile() {
+			m[e.Size] = append(m[e.Size], e)
+		}
+	}
+
+	return m, scanner.Err()
+}
+
+// backupCopy returns an entry of the manifest with the same content as a file, or an empty string if there is none
+// Entries with a hash are compared by hash, entries without one by name and size, the latter is reported as uncertain.
+func (m manifest) backupCopy(file string, size int64) (string, bool, error) {
+	entries := m[size]
+	if len(entries) == 0 {
+		return "", false, nil
+	}
+
+	var sum string
+	for _, e := range entries {
+		if e.SHA256 == "" {
+			continue
+		}
+
+		if sum == "" {
+			var err error
+			if sum, err = hashFile(file, sha256.New); err != nil {
+				return "", false, err
+			}
+		}
+
+		if strings.EqualFold(e.SHA256, sum) {
+			return e.Path, true, nil
+		}
+	}
+
+	for _, e := range entries {
+		if e.SHA256 == "" && filepath.Base(filepath.FromSlash(e.Path)) == filepath.Base(file) {
+			return e.Path, false, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// exportManifest implements the export-manifest command which writes the files under the given roots as a manifest
+func exportManifest(args []string) error {
+	var (
+		out, ignore string
+	)
+
+	fs := flag.NewFlagSet("export-manifest", flag.ExitOnError)
+	fs.StringVar(&out, "out", "", "write the manifest into this file instead of the standard output")
+	fs.StringVar(&ignore, "ignore", "", "regexp to ignore files completely")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder export-manifest [-out <file>] [-ignore <regexp>] <root>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	filter, err := newWalkFilter(ignore, "", "", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	fileSizes, err := getAllFileSizes(roots, filter)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	return writeManifest(w, fileSizes)
+}
+
+// writeManifest writes files as JSON lines, sorted by path, with the SHA-256 hash of their content
+func writeManifest(w io.Writer, fileSizes map[int64][]string) error {
+	var entries []manifestEntry
+	for size, paths := range fileSizes {
+		for _, path := range paths {
+			sum, err := hashFile(path, sha256.New)
+			if err != nil {
+				slog.Warn("can't hash file", "path", path, "err", err)
+				continue
+			}
+
+			entries = append(entries, manifestEntry{Path: filepath.ToSlash(path), Size: size, SHA256: sum, Type: "file"})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reportBackupCopy prints whether the content of a group is already present in the backup manifest, and returns true
+// if it is certainly present, that is its content hash is listed
+func reportBackupCopy(files []string, backup manifest) bool {
+	if len(backup) == 0 || len(files) == 0 {
+		return false
+	}
+
+	fi, err := os.Stat(files[0])
+	if err != nil {
+		return false
+	}
+
+	path, sure, err := backup.backupCopy(files[0], fi.Size())
+	switch {
+	case err != nil:
+		fmt.Printf("can't check backup manifest: %v\n", err)
+	case path != "" && sure:
+		fmt.Printf("[backed up: yes] %s\n", path)
+	case path != "":
+		fmt.Printf("[backed up: maybe] %s (name and size only)\n", path)
+	default:
+		fmt.Printf("[backed up: no]\n")
+	}
+
+	return path != "" && sure
+}
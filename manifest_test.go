@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_readManifest(t *testing.T) {
+	input := `{"time":"2021-01-01T00:00:00Z","paths":["/data"],"struct_type":"snapshot"}
+{"name":"data","type":"dir","path":"/data","struct_type":"node"}
+{"name":"a.txt","type":"file","path":"/data/a.txt","size":3,"struct_type":"node"}
+
+{"type":"-","path":"data/b.txt","size":3,"sha256":"abc"}
+{"type":"d","path":"data","size":0}
+`
+
+	got, err := readManifest(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || len(got[3]) != 2 {
+		t.Fatalf("readManifest() = %v, want two files of size 3", got)
+	}
+	if got[3][0].Path != "/data/a.txt" || got[3][1].SHA256 != "abc" {
+		t.Errorf("readManifest() = %v", got)
+	}
+
+	if _, err := readManifest(strings.NewReader("{")); err == nil {
+		t.Errorf("readManifest() expected an error for invalid JSON")
+	}
+}
+
+func Test_manifest_backupCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{"a.txt": "aaa", "b.txt": "bbb", "c.txt": "ccc"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeManifest(&buf, map[int64][]string{3: {filepath.Join(dir, "a.txt")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := readManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m[3] = append(m[3], manifestEntry{Path: "/backup/c.txt", Size: 3})
+
+	tests := []struct {
+		name     string
+		file     string
+		wantPath string
+		wantSure bool
+	}{
+		{"by-hash", "a.txt", filepath.ToSlash(filepath.Join(dir, "a.txt")), true},
+		{"not-found", "b.txt", "", false},
+		{"by-name-and-size", "c.txt", "/backup/c.txt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, sure, err := m.backupCopy(filepath.Join(dir, tt.file), 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != tt.wantPath || sure != tt.wantSure {
+				t.Errorf("backupCopy() = %v, %v, want %v, %v", path, sure, tt.wantPath, tt.wantSure)
+			}
+		})
+	}
+}
+
+func Test_listBackup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on the echo and false utilities")
+	}
+
+	m, err := listBackup(exec.Command("echo", `{"path":"/a","size":3,"type":"file","sha256":"ab"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m[3]) != 1 || m[3][0].SHA256 != "ab" {
+		t.Errorf("listBackup() = %v", m)
+	}
+
+	if _, err := listBackup(exec.Command("false")); err == nil {
+		t.Errorf("listBackup() succeeded for a failing tool")
+	}
+}
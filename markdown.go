@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeMarkdownReport writes a Markdown document with a summary table and a collapsible section for each group
+// The files are listed with the action taken on them, so the document can be attached to an issue after a cleanup.
+func writeMarkdownReport(w io.Writer, groups []reportGroup, decisions []decision, opts options) error {
+	r := newReport(groups, decisions, opts)
+	actions := fileActions(groups, decisions, opts.action)
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# Duplicates\n\n")
+	fmt.Fprintf(bw, "| | |\n|---|---:|\n")
+	fmt.Fprintf(bw, "| Roots | %s |\n", markdownEscape(strings.Join(r.Roots, ", ")))
+	fmt.Fprintf(bw, "| Action | %s |\n", opts.action)
+	fmt.Fprintf(bw, "| Groups | %d |\n", r.Summary.Groups)
+	fmt.Fprintf(bw, "| Files | %d |\n", r.Summary.Files)
+	fmt.Fprintf(bw, "| Duplicates | %d |\n", r.Summary.Duplicates)
+	fmt.Fprintf(bw, "| Reclaimable bytes | %d |\n", r.Summary.Reclaimable)
+
+	for i, g := range r.Groups {
+		fmt.Fprintf(bw, "\n<details>\n<summary>Group %d: %d files of %d bytes, %d bytes reclaimable</summary>\n\n", i+1, len(g.Files), g.Size, g.Reclaimable)
+		fmt.Fprintf(bw, "| Path | Modified | Action |\n|---|---|---|\n")
+
+		for _, f := range g.Files {
+			fmt.Fprintf(bw, "| `%s` | %s | %s |\n", markdownCode(f.Path), f.ModTime.Format("2006-01-02 15:04"), actions[f.Path])
+		}
+
+		fmt.Fprintf(bw, "\n</details>\n")
+	}
+
+	return bw.Flush()
+}
+
+// markdownEscape escapes the characters of a text which would break a Markdown table cell or format the text
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_", "`", "\\`", "<", "&lt;").Replace(s)
+}
+
+// markdownCode prepares a text to be shown as code in a Markdown table cell
+// Backticks can't be escaped within code, and pipes are escaped even there by GitHub flavoured Markdown.
+func markdownCode(s string) string {
+	return strings.NewReplacer("`", "'", "|", "\\|").Replace(s)
+}
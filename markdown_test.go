@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_writeMarkdownReport(t *testing.T) {
+	groups := []reportGroup{
+		{Size: 4, Reclaimable: 4, Files: []reportFile{{Path: "/a/x", Size: 4}, {Path: "/b/x", Size: 4}}},
+	}
+	decisions := []decision{{"/a/x", []string{"/b/x"}, manualReason}}
+
+	var buf bytes.Buffer
+	if err := writeMarkdownReport(&buf, groups, decisions, options{action: trashAction, roots: []string{"/a|b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"| Roots | /a\\|b |",
+		"| Reclaimable bytes | 4 |",
+		"<summary>Group 1: 2 files of 4 bytes, 4 bytes reclaimable</summary>",
+		"| `/b/x` | 0001-01-01 00:00 | trash |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("writeMarkdownReport() = %s, want it to contain %q", out, want)
+		}
+	}
+}
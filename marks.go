@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mark is a file recorded for deletion at a later time, together with the duplicate which is kept instead of it
+type mark struct {
+	Path     string    `json:"path"`
+	Keep     string    `json:"keep"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	MarkedAt time.Time `json:"marked_at"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// defaultMarksFile returns the location of the pending deletions list in the user's config directory
+func defaultMarksFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "dblfinder-marked.json"
+	}
+
+	return filepath.Join(dir, "dblfinder", "marked.json")
+}
+
+// newMarks creates marks for files to be deleted later in favour of keep, reason being the rule which decided so
+// Paths are stored as absolute paths, as purge-marked may be run from any directory.
+func newMarks(keep string, files []string, reason string) []mark {
+	var res []mark
+
+	keep, err := filepath.Abs(keep)
+	if err != nil {
+		fmt.Printf("can't resolve path: %s, err %v\n", keep, err)
+		return nil
+	}
+
+	now := time.Now()
+	for _, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			fmt.Printf("can't resolve path: %s, err %v\n", file, err)
+			continue
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			fmt.Printf("can't stat file: %s, err %v\n", path, err)
+			continue
+		}
+
+		res = append(res, mark{path, keep, fi.Size(), fi.ModTime(), now, reason})
+	}
+
+	return res
+}
+
+// markFiles adds marks to the pending deletions list, unless dryRun is set
+func markFiles(marksFile string, marks []mark, dryRun bool) {
+	for _, m := range marks {
+		if dryRun {
+			fmt.Printf("%s %s (skipped)\n", colorize(deleteColor, "Marking:"), m.Path)
+		} else {
+			fmt.Printf("%s %s\n", colorize(deleteColor, "Marking:"), m.Path)
+		}
+	}
+
+	if dryRun {
+		return
+	}
+
+	existing, err := loadMarks(marksFile)
+	if err != nil {
+		fmt.Printf("failed loading marked files: %v\n", err)
+		return
+	}
+
+	if err := saveMarks(marksFile, mergeMarks(existing, marks)); err != nil {
+		fmt.Printf("failed saving marked files: %v\n", err)
+		return
+	}
+
+	fmt.Printf("%d file(s) marked for deletion in %s\n", len(marks), marksFile)
+}
+
+// mergeMarks adds new marks to a list of existing ones, replacing earlier marks of the same paths
+func mergeMarks(existing, marks []mark) []mark {
+	added := map[string]bool{}
+	for _, m := range marks {
+		added[m.Path] = true
+	}
+
+	var res []mark
+	for _, m := range existing {
+		if !added[m.Path] {
+			res = append(res, m)
+		}
+	}
+
+	return append(res, marks...)
+}
+
+// loadMarks reads the pending deletions list, a missing file is treated as an empty list
+func loadMarks(marksFile string) ([]mark, error) {
+	data, err := ioutil.ReadFile(marksFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var marks []mark
+	if err := json.Unmarshal(data, &marks); err != nil {
+		return nil, fmt.Errorf("can't parse %s: %v", marksFile, err)
+	}
+
+	return marks, nil
+}
+
+// saveMarks writes the pending deletions list via a temp file, so that it's never left half written
+func saveMarks(marksFile string, marks []mark) error {
+	if marks == nil {
+		marks = []mark{}
+	}
+
+	data, err := json.MarshalIndent(marks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(marksFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".marked-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), marksFile)
+}
+
+// purgeMarked implements the purge-marked command which deletes files marked long enough ago
+func purgeMarked(args []string) error {
+	var (
+		olderThan, marksFile, stage, trashBackend string
+		dryRun                                    bool
+	)
+
+	fs := flag.NewFlagSet("purge-marked", flag.ExitOnError)
+	fs.StringVar(&olderThan, "older-than", "14d", "only delete files marked at least this long ago (e.g. 36h, 14d)")
+	fs.StringVar(&marksFile, "marks-file", defaultMarksFile(), "file storing the list of files marked for deletion")
+	fs.StringVar(&stage, "stage", string(deleteAction), "what to do with the files due: delete, or trash to keep them restorable until the trash is emptied")
+	fs.StringVar(&trashBackend, "trash-backend", trashAuto, "how the trash stage moves files to the trash ("+strings.Join(trashBackends, ", ")+")")
+	fs.BoolVar(&dryRun, "dry-run", false, "dry run, nothing will be deleted")
+	fs.Parse(args)
+
+	if stage != string(deleteAction) && stage != string(trashAction) {
+		return fmt.Errorf("unknown stage: %s, available: %s, %s", stage, deleteAction, trashAction)
+	}
+
+	if !validTrashBackend(trashBackend) {
+		return unknownValue("trash backend", trashBackend, trashBackends)
+	}
+
+	age, err := parseAge(olderThan)
+	if err != nil {
+		return err
+	}
+
+	marks, err := loadMarks(marksFile)
+	if err != nil {
+		return err
+	}
+
+	var (
+		remaining []mark
+		due       []mark
+	)
+
+	now := time.Now()
+	for _, m := range marks {
+		if now.Sub(m.MarkedAt) < age {
+			remaining = append(remaining, m)
+		} else {
+			due = append(due, m)
+		}
+	}
+
+	marked := map[string]bool{}
+	for _, m := range due {
+		marked[m.Path] = true
+	}
+
+	// marks are checked right before their file is removed, so that removing one file can't make another one unsafe
+	var (
+		purged  int
+		removed = map[string]bool{}
+	)
+	for _, m := range due {
+		if marked[m.Keep] || removed[m.Keep] {
+			fmt.Printf("Dropping mark, the file kept instead of it is marked as well: %s\n", m.Path)
+			continue
+		}
+
+		if err := checkMark(m); err != nil {
+			fmt.Printf("Dropping mark, %v\n", err)
+			continue
+		}
+
+		purged++
+		if stage == string(trashAction) {
+			trashFiles([]string{m.Path}, trashBackend, dryRun)
+		} else {
+			deleteOtherFiles([]string{m.Path}, dryRun)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		// files which couldn't be deleted or trashed keep their marks, so that the next purge retries them
+		if _, err := os.Lstat(m.Path); err == nil {
+			remaining = append(remaining, m)
+		} else {
+			removed[m.Path] = true
+		}
+	}
+
+	if purged == 0 {
+		fmt.Println("No marked files are due for deletion")
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	return saveMarks(marksFile, remaining)
+}
+
+// checkMark makes sure that a marked file is unchanged and the file kept instead of it still exists with the same content
+func checkMark(m mark) error {
+	fi, err := os.Stat(m.Path)
+	if err != nil {
+		return fmt.Errorf("can't stat file: %s, err %v", m.Path, err)
+	}
+
+	if fi.Size() != m.Size || !fi.ModTime().Equal(m.ModTime) {
+		return fmt.Errorf("file changed since marking: %s", m.Path)
+	}
+
+	same, err := sameContent(m.Keep, m.Path)
+	if err != nil {
+		return fmt.Errorf("can't compare %s with kept file %s, err %v", m.Path, m.Keep, err)
+	}
+
+	if !same {
+		return fmt.Errorf("file is no longer a duplicate of %s: %s", m.Keep, m.Path)
+	}
+
+	return nil
+}
+
+// parseAge parses a duration which besides the units known by time.ParseDuration may also be given in days (e.g. 14d)
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(s)
+}
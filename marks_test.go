@@ -0,0 +1,222 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func Test_parseAge(t *testing.T) {
+	type args struct {
+		s string
+	}
+	tests := []struct {
+		name string
+		args args
+		want time.Duration
+		ok   bool
+	}{
+		{
+			"days",
+			args{
+				"14d",
+			},
+			14 * 24 * time.Hour,
+			true,
+		},
+		{
+			"hours",
+			args{
+				"36h",
+			},
+			36 * time.Hour,
+			true,
+		},
+		{
+			"invalid-days",
+			args{
+				"xd",
+			},
+			0,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAge(tt.args.s)
+			if got != tt.want {
+				t.Errorf("parseAge() got = %v, want %v", got, tt.want)
+			}
+			if (err == nil) != tt.ok {
+				t.Errorf("parseAge() err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_mergeMarks(t *testing.T) {
+	type args struct {
+		existing []mark
+		marks    []mark
+	}
+	tests := []struct {
+		name string
+		args args
+		want []mark
+	}{
+		{
+			"replaces-same-path",
+			args{
+				[]mark{{Path: "a", Keep: "x"}, {Path: "b", Keep: "x"}},
+				[]mark{{Path: "a", Keep: "y"}},
+			},
+			[]mark{{Path: "b", Keep: "x"}, {Path: "a", Keep: "y"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeMarks(tt.args.existing, tt.args.marks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeMarks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_purgeMarked_failedRemovalKeepsMark(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("the native trash can only be made to fail through XDG_DATA_HOME on other systems")
+	}
+
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keep, file := filepath.Join(dir, "keep"), filepath.Join(dir, "file")
+	for _, path := range []string{keep, file} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	marksFile := filepath.Join(dir, "marked.json")
+	if err := saveMarks(marksFile, newMarks(keep, []string{file}, manualReason)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the home trash can't be created under a file, so trashing fails
+	t.Setenv("XDG_DATA_HOME", keep)
+
+	if err := purgeMarked([]string{"-marks-file", marksFile, "-older-than", "0s", "-stage", "trash", "-trash-backend", trashNative}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(file) {
+		t.Fatalf("purgeMarked() trashed %s", file)
+	}
+
+	if marks, err := loadMarks(marksFile); err != nil || len(marks) != 1 || marks[0].Path != file {
+		t.Errorf("purgeMarked() left marks %+v, %v, want the mark of the file which failed", marks, err)
+	}
+}
+
+func Test_purgeMarked_stages(t *testing.T) {
+	tests := []struct {
+		stage string
+	}{
+		{string(deleteAction)},
+		{string(trashAction)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stage, func(t *testing.T) {
+			if tt.stage == string(trashAction) && (runtime.GOOS == "windows" || runtime.GOOS == "darwin") {
+				t.Skip("the native trash can only be redirected through XDG_DATA_HOME on other systems")
+			}
+
+			dir, err := ioutil.TempDir("", "dblfinder")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			keep, due, recent := filepath.Join(dir, "keep"), filepath.Join(dir, "due"), filepath.Join(dir, "recent")
+			for _, path := range []string{keep, due, recent} {
+				if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			marks := newMarks(keep, []string{due, recent}, manualReason)
+			marks[0].MarkedAt = marks[0].MarkedAt.Add(-48 * time.Hour)
+
+			marksFile := filepath.Join(dir, "marked.json")
+			if err := saveMarks(marksFile, marks); err != nil {
+				t.Fatal(err)
+			}
+
+			data := filepath.Join(dir, "data")
+			t.Setenv("XDG_DATA_HOME", data)
+
+			if err := purgeMarked([]string{"-marks-file", marksFile, "-older-than", "1d", "-stage", tt.stage, "-trash-backend", trashNative}); err != nil {
+				t.Fatal(err)
+			}
+
+			if exists(due) || !exists(recent) || !exists(keep) {
+				t.Errorf("purgeMarked() left due %v, recent %v, keep %v, want only the due file gone", exists(due), exists(recent), exists(keep))
+			}
+
+			if trashed := exists(filepath.Join(data, "Trash", "files", "due")); trashed != (tt.stage == string(trashAction)) {
+				t.Errorf("purgeMarked() moved the due file to the trash: %v", trashed)
+			}
+
+			if left, err := loadMarks(marksFile); err != nil || len(left) != 1 || left[0].Path != recent {
+				t.Errorf("purgeMarked() left marks %+v, %v, want the mark of the recent file", left, err)
+			}
+		})
+	}
+}
+
+func Test_purgeMarked_mutualKeep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c, k := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c"), filepath.Join(dir, "k")
+	for _, path := range []string{a, b, c, k} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b was marked keeping a, and a later run marked a keeping b
+	marks := mergeMarks(newMarks(a, []string{b}, manualReason), newMarks(b, []string{a}, manualReason))
+	marks = append(marks, newMarks(k, []string{c}, manualReason)...)
+
+	marksFile := filepath.Join(dir, "marked.json")
+	if err := saveMarks(marksFile, marks); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := purgeMarked([]string{"-marks-file", marksFile, "-older-than", "0s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(a) || !exists(b) {
+		t.Errorf("purgeMarked() left a %v, b %v, want both kept", exists(a), exists(b))
+	}
+
+	if exists(c) {
+		t.Errorf("purgeMarked() kept %s, whose kept file is not marked", c)
+	}
+
+	if left, err := loadMarks(marksFile); err != nil || len(left) != 0 {
+		t.Errorf("purgeMarked() left marks %+v, %v, want none", left, err)
+	}
+}
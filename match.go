@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	matchContent  = "content"
+	matchNameSize = "name-size"
+)
+
+// nameSizeMatch is a set of files in different roots sharing their name and size
+type nameSizeMatch struct {
+	Name  string   `json:"name"`
+	Size  int64    `json:"size"`
+	Paths []string `json:"paths"`
+}
+
+type nameSizeKey struct {
+	name string
+	size int64
+}
+
+// matchByNameSize pairs files across roots by their name and size, without reading them
+// It returns the matches found and the files of the first root without a match in any of the other roots.
+func matchByNameSize(roots []string, filter walkFilter) ([]nameSizeMatch, []string, error) {
+	var (
+		keys      []nameSizeKey
+		byKey     = map[nameSizeKey][]string{}
+		rootCount = map[nameSizeKey]int{}
+	)
+
+	for _, root := range roots {
+		fileSizes, err := getAllFileSizes([]string{root}, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		seen := map[nameSizeKey]bool{}
+		for size, paths := range fileSizes {
+			for _, path := range paths {
+				key := nameSizeKey{filepath.Base(path), size}
+				if _, ok := byKey[key]; !ok {
+					keys = append(keys, key)
+				}
+
+				byKey[key] = append(byKey[key], path)
+
+				if !seen[key] {
+					seen[key] = true
+					rootCount[key]++
+				}
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].size != keys[j].size {
+			return keys[i].size > keys[j].size
+		}
+
+		return keys[i].name < keys[j].name
+	})
+
+	var (
+		matches   []nameSizeMatch
+		unmatched []string
+	)
+
+	for _, key := range keys {
+		if rootCount[key] > 1 {
+			matches = append(matches, nameSizeMatch{key.name, key.size, byKey[key]})
+			continue
+		}
+
+		for _, path := range byKey[key] {
+			if inRoot(path, roots[0]) {
+				unmatched = append(unmatched, path)
+			}
+		}
+	}
+
+	sort.Strings(unmatched)
+
+	return matches, unmatched, nil
+}
+
+// inRoot returns true if path is root or is located under it
+func inRoot(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	return rel == "." || (rel != ".." && !hasPrefixDir(rel, ".."))
+}
+
+// hasPrefixDir returns true if the first element of a relative path is dir
+func hasPrefixDir(rel, dir string) bool {
+	return len(rel) > len(dir) && rel[:len(dir)] == dir && rel[len(dir)] == filepath.Separator
+}
+
+// listNameSizeMatches prints matched files and the files of the first root without a match
+func listNameSizeMatches(matches []nameSizeMatch, unmatched []string, firstRoot string) {
+	fmt.Println()
+
+	for i, m := range matches {
+		fmt.Printf("The following files have the same name and size (%d / %d):\n", i, len(matches))
+
+		for key, path := range m.Paths {
+			fmt.Printf("[%d] %s\n", key+1, path)
+		}
+
+		fmt.Println()
+	}
+
+	if len(unmatched) == 0 {
+		fmt.Printf("All files in %s have a match in the other roots\n", firstRoot)
+		return
+	}
+
+	fmt.Printf("%d file(s) in %s have no match in the other roots:\n", len(unmatched), firstRoot)
+	for _, path := range unmatched {
+		fmt.Println(path)
+	}
+}
+
+// saveMatches writes name-size matches into a JSON file, to be verified later by verify-matches
+func saveMatches(matchesFile string, matches []nameSizeMatch) error {
+	if matches == nil {
+		matches = []nameSizeMatch{}
+	}
+
+	data, err := json.MarshalIndent(matches, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(matchesFile, data, 0644)
+}
+
+// loadMatches reads name-size matches saved by saveMatches
+func loadMatches(matchesFile string) ([]nameSizeMatch, error) {
+	data, err := ioutil.ReadFile(matchesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []nameSizeMatch
+	if err := json.Unmarshal(data, &matches); err != nil {
+		return nil, fmt.Errorf("can't parse %s: %v", matchesFile, err)
+	}
+
+	return matches, nil
+}
+
+// verifyMatches implements the verify-matches command which compares the content of previously matched files
+func verifyMatches(args []string) error {
+	var out string
+
+	fs := flag.NewFlagSet("verify-matches", flag.ExitOnError)
+	fs.StringVar(&out, "out", "", "write the confirmed matches into this file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder verify-matches [-out <file>] <matches.json>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one matches file is expected")
+	}
+
+	matches, err := loadMatches(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	confirmed, differs := confirmMatches(matches)
+
+	fmt.Printf("Confirmed (%d):\n", len(confirmed))
+	for _, m := range confirmed {
+		for _, path := range m.Paths {
+			fmt.Println(path)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Not confirmed (%d):\n", len(differs))
+	for _, path := range differs {
+		fmt.Println(path)
+	}
+
+	if out == "" {
+		return nil
+	}
+
+	return saveMatches(out, confirmed)
+}
+
+// confirmMatches compares each matched file with the first file of its match
+// It returns the matches reduced to the files proven identical and the files which differ or could not be compared.
+func confirmMatches(matches []nameSizeMatch) ([]nameSizeMatch, []string) {
+	var (
+		confirmed []nameSizeMatch
+		differs   []string
+	)
+
+	for _, m := range matches {
+		if len(m.Paths) < 2 {
+			continue
+		}
+
+		same := []string{m.Paths[0]}
+		for _, path := range m.Paths[1:] {
+			ok, err := sameContent(m.Paths[0], path)
+			if err != nil {
+				fmt.Printf("can't compare %s with %s, err %v\n", m.Paths[0], path, err)
+			}
+
+			if !ok {
+				differs = append(differs, path)
+				continue
+			}
+
+			same = append(same, path)
+		}
+
+		if len(same) > 1 {
+			confirmed = append(confirmed, nameSizeMatch{m.Name, m.Size, same})
+		} else {
+			differs = append(differs, m.Paths[0])
+		}
+	}
+
+	return confirmed, differs
+}
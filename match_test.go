@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_matchByNameSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sd, archive := filepath.Join(dir, "sd"), filepath.Join(dir, "archive")
+	files := map[string]string{
+		"sd/a.jpg":             "aaa",
+		"sd/b.jpg":             "bbb",
+		"sd/c.jpg":             "ccc",
+		"archive/2020/a.jpg":   "xxx",
+		"archive/2020/b.jpg":   "bbbb",
+		"archive/other/d.jpg":  "ddd",
+		"archive/other/d2.jpg": "ddd",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, unmatched, err := matchByNameSize([]string{sd, archive}, walkFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantMatches := []nameSizeMatch{
+		{"a.jpg", 3, []string{filepath.Join(sd, "a.jpg"), filepath.Join(archive, "2020", "a.jpg")}},
+	}
+	wantUnmatched := []string{filepath.Join(sd, "b.jpg"), filepath.Join(sd, "c.jpg")}
+
+	if !reflect.DeepEqual(matches, wantMatches) {
+		t.Errorf("matchByNameSize() matches = %v, want %v", matches, wantMatches)
+	}
+	if !reflect.DeepEqual(unmatched, wantUnmatched) {
+		t.Errorf("matchByNameSize() unmatched = %v, want %v", unmatched, wantUnmatched)
+	}
+}
+
+func Test_confirmMatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a":  "aaa",
+		"a2": "aaa",
+		"a3": "aab",
+		"b":  "bbb",
+		"b2": "bbc",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	matches := []nameSizeMatch{
+		{"a", 3, []string{path("a"), path("a2"), path("a3")}},
+		{"b", 3, []string{path("b"), path("b2")}},
+	}
+
+	confirmed, differs := confirmMatches(matches)
+
+	wantConfirmed := []nameSizeMatch{{"a", 3, []string{path("a"), path("a2")}}}
+	wantDiffers := []string{path("a3"), path("b2"), path("b")}
+
+	if !reflect.DeepEqual(confirmed, wantConfirmed) {
+		t.Errorf("confirmMatches() confirmed = %v, want %v", confirmed, wantConfirmed)
+	}
+	if !reflect.DeepEqual(differs, wantDiffers) {
+		t.Errorf("confirmMatches() differs = %v, want %v", differs, wantDiffers)
+	}
+}
+
+func Test_inRoot(t *testing.T) {
+	type args struct {
+		path string
+		root string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			"below",
+			args{
+				"sd/a/b.jpg",
+				"sd",
+			},
+			true,
+		},
+		{
+			"sibling-with-same-prefix",
+			args{
+				"sd2/b.jpg",
+				"sd",
+			},
+			false,
+		},
+		{
+			"dot-dot-named-file",
+			args{
+				"sd/..b.jpg",
+				"sd",
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inRoot(tt.args.path, tt.args.root); got != tt.want {
+				t.Errorf("inRoot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// runMetrics counts what the run statistics don't, for the /metrics endpoint of the long-running modes
+type runMetrics struct {
+	groupsFound    int64
+	bytesReclaimed int64
+	errors         int64
+}
+
+// metrics holds the metrics of the current process
+var metrics runMetrics
+
+// metricsHandler serves the metrics of the process in the Prometheus text format
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	writeMetrics(w)
+}
+
+// writeMetrics writes the metrics of the process in the Prometheus text format
+// Counters add up the scans and actions run since the process started, the hash throughput is measured over the time
+// spent hashing so far.
+func writeMetrics(w io.Writer) {
+	walk, sample := stats.stage(walkStage), stats.stage(sampleStage)
+
+	bytesHashed := atomic.LoadInt64(&sample.bytes)
+
+	var throughput float64
+	if elapsed := time.Duration(atomic.LoadInt64(&sample.elapsed)); elapsed > 0 {
+		throughput = float64(bytesHashed) / elapsed.Seconds()
+	}
+
+	writeMetric(w, "dblfinder_files_scanned_total", "counter", "Files found by the scans.", float64(atomic.LoadInt64(&walk.files)))
+	writeMetric(w, "dblfinder_bytes_hashed_total", "counter", "Bytes read to hash files.", float64(bytesHashed))
+	writeMetric(w, "dblfinder_hash_throughput_bytes_per_second", "gauge", "Bytes hashed per second spent hashing.", throughput)
+	writeMetric(w, "dblfinder_duplicate_groups_found_total", "counter", "Duplicate groups found by the scans.", float64(atomic.LoadInt64(&metrics.groupsFound)))
+	writeMetric(w, "dblfinder_bytes_reclaimed_total", "counter", "Bytes freed by acting on duplicates.", float64(atomic.LoadInt64(&metrics.bytesReclaimed)))
+	writeMetric(w, "dblfinder_errors_total", "counter", "Errors met while scanning and acting on files.", float64(atomic.LoadInt64(&metrics.errors)))
+}
+
+// writeMetric writes a metric with its help and type lines
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+}
+
+// actedSizes returns the size of the files acted on by the decisions, recorded before acting as they may be gone
+// afterwards
+func actedSizes(decisions []decision) map[string]int64 {
+	res := map[string]int64{}
+	for _, d := range decisions {
+		for _, file := range d.files {
+			if fi, err := os.Lstat(file); err == nil {
+				res[file] = fi.Size()
+			}
+		}
+	}
+
+	return res
+}
+
+// countReclaimed adds the size of the files freed by the decisions to the bytes reclaimed
+func countReclaimed(decisions []decision, sizes map[string]int64, opts options) {
+	for _, d := range decisions {
+		for _, file := range reclaimedFiles(d, opts) {
+			atomic.AddInt64(&metrics.bytesReclaimed, sizes[file])
+		}
+	}
+}
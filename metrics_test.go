@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_writeMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for _, path := range []string{a, b} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(m runMetrics) { metrics = m }(metrics)
+	metrics = runMetrics{}
+
+	act([]decision{{a, []string{b}, manualReason}}, options{action: deleteAction})
+
+	var buf bytes.Buffer
+	writeMetrics(&buf)
+
+	for _, want := range []string{
+		"# TYPE dblfinder_files_scanned_total counter\n",
+		"# TYPE dblfinder_hash_throughput_bytes_per_second gauge\n",
+		"\ndblfinder_bytes_reclaimed_total 4\n",
+		"\ndblfinder_errors_total 0\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("writeMetrics() = %s, want %q", buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package model defines the results of a dblfinder run, as serialized by its reports, exports and logs
+// The JSON field names are part of the schema, changes to them bump SchemaVersion. dblfinder.proto describes the same
+// types as a protobuf schema.
+package model
+
+import (
+	"time"
+)
+
+// SchemaVersion is the version of the result model, increased on incompatible changes
+const SchemaVersion = 1
+
+// Action is what's done with the duplicates decided on, like trash or hardlink
+type Action string
+
+// FileRef is a file of a duplicate group
+type FileRef struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"`
+}
+
+// Group is a group of files with the same content
+// Reclaimable is the space freed by keeping a single file of the group.
+type Group struct {
+	Size        int64     `json:"size"`
+	Hash        string    `json:"hash"`
+	Reclaimable int64     `json:"reclaimable"`
+	Files       []FileRef `json:"files"`
+}
+
+// Decision is a file of a group which is kept, and the duplicates of it which are acted on
+// Reason is the rule which made the decision, like manual, prefer:1 or keep:newest.
+type Decision struct {
+	Action Action   `json:"action"`
+	Keep   string   `json:"keep"`
+	Files  []string `json:"files"`
+	Reason string   `json:"reason"`
+}
+
+// WasteStats is the space taken by duplicates within a part of the scanned files, like a root or an extension
+type WasteStats struct {
+	Duplicates  int   `json:"duplicates"`
+	Reclaimable int64 `json:"reclaimable"`
+	Reclaimed   int64 `json:"reclaimed"`
+}
+
+// Summary sums up a run and the duplicate groups it found
+type Summary struct {
+	FilesScanned int64                 `json:"files_scanned"`
+	BytesHashed  int64                 `json:"bytes_hashed"`
+	Groups       int                   `json:"groups"`
+	Files        int                   `json:"files"`
+	Duplicates   int                   `json:"duplicates"`
+	Reclaimable  int64                 `json:"reclaimable"`
+	Reclaimed    int64                 `json:"reclaimed"`
+	ByRoot       map[string]WasteStats `json:"by_root"`
+	ByExtension  map[string]WasteStats `json:"by_extension"`
+}
+
+// Run is the result of a run: the duplicate groups found under the roots and what was decided about them
+// Hashes are calculated with HashAlgorithm over samples of SampleSize bytes, 0 meaning whole files.
+type Run struct {
+	SchemaVersion int        `json:"schema_version"`
+	Version       string     `json:"version"`
+	Roots         []string   `json:"roots"`
+	HashAlgorithm string     `json:"hash_algorithm"`
+	SampleSize    int        `json:"sample_size"`
+	Groups        []Group    `json:"groups"`
+	Decisions     []Decision `json:"decisions,omitempty"`
+	Summary       Summary    `json:"summary"`
+}
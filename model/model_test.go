@@ -0,0 +1,48 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRun_JSON(t *testing.T) {
+	r := Run{
+		SchemaVersion: SchemaVersion,
+		Version:       "0.5.2",
+		Roots:         []string{"photos"},
+		HashAlgorithm: "xxhash64",
+		Groups: []Group{{
+			Size:        4,
+			Hash:        "01",
+			Reclaimable: 4,
+			Files:       []FileRef{{Path: "a", Size: 4, ModTime: time.Unix(0, 0).UTC(), Hash: "01"}, {Path: "b", Size: 4, ModTime: time.Unix(0, 0).UTC(), Hash: "01"}},
+		}},
+		Decisions: []Decision{{Action: "trash", Keep: "a", Files: []string{"b"}, Reason: "manual"}},
+		Summary:   Summary{Groups: 1, ByRoot: map[string]WasteStats{"photos": {Duplicates: 1, Reclaimable: 4}}},
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"schema_version", "version", "roots", "hash_algorithm", "sample_size", "groups", "decisions", "summary"} {
+		if _, ok := keys[key]; !ok {
+			t.Errorf("json.Marshal() = %s, missing key %q", data, key)
+		}
+	}
+
+	var decoded Run
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, r) {
+		t.Errorf("json round trip = %+v, want %+v", decoded, r)
+	}
+}
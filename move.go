@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// moveFiles relocates files under target, preserving their path relative to the root they were found under
+// Files which would overwrite something already present in target are left untouched.
+func moveFiles(files, roots []string, target string, dryRun bool) {
+	for _, file := range files {
+		dst, err := quarantinePath(file, roots, target)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+
+		if exists(dst) {
+			fmt.Printf("Moving: %s (skipped, %s already exists)\n", file, dst)
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("%s %s => %s (skipped)\n", colorize(deleteColor, "Moving:"), file, dst)
+			continue
+		}
+
+		fmt.Printf("%s %s => %s\n", colorize(deleteColor, "Moving:"), file, dst)
+
+		if err := moveFile(file, dst); err != nil {
+			fmt.Printf("%v\n", err)
+		} else {
+			fmt.Println("done.")
+		}
+	}
+}
+
+// quarantinePath returns where a file is moved to under target, based on its path relative to its root
+func quarantinePath(file string, roots []string, target string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil || !inRoot(abs, absRoot) {
+			continue
+		}
+
+		rel, err := filepath.Rel(absRoot, abs)
+		if err != nil {
+			return "", err
+		}
+
+		return filepath.Join(target, rel), nil
+	}
+
+	return "", fmt.Errorf("can't find the root of file: %s", file)
+}
+
+// moveFile renames src to dst, falling back to copying and removing src if they are on different devices
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// copyFile copies the content, permissions and modification time of src to a new file at dst
+func copyFile(src, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	fi, err := s.Stat()
+	if err != nil {
+		return err
+	}
+
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(d, s)
+	if err == nil {
+		err = d.Sync()
+	}
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_quarantinePath(t *testing.T) {
+	type args struct {
+		file  string
+		roots []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+		ok   bool
+	}{
+		{
+			"relative-to-root",
+			args{
+				"/data/photos/2020/a.jpg",
+				[]string{"/backup", "/data/photos"},
+			},
+			"/quarantine/2020/a.jpg",
+			true,
+		},
+		{
+			"outside-roots",
+			args{
+				"/other/a.jpg",
+				[]string{"/data"},
+			},
+			"",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := quarantinePath(filepath.FromSlash(tt.args.file), tt.args.roots, filepath.FromSlash("/quarantine"))
+			if got != filepath.FromSlash(tt.want) {
+				t.Errorf("quarantinePath() got = %v, want %v", got, tt.want)
+			}
+			if (err == nil) != tt.ok {
+				t.Errorf("quarantinePath() err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
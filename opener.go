@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// openWithSystem opens a file with the default application of the platform, without waiting for it to be closed
+func openWithSystem(path string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", "", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go cmd.Wait()
+
+	return nil
+}
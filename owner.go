@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// ownership is the user and group owning a file
+type ownership struct {
+	UID int
+	GID int
+}
+
+// String returns the ownership as uid:gid
+func (o ownership) String() string {
+	return fmt.Sprintf("%d:%d", o.UID, o.GID)
+}
+
+// userNames caches the names of the users looked up by userName
+var userNames = map[int]string{}
+
+// userName returns the name of a user, or its id if it can't be looked up
+func userName(uid int) string {
+	if name, ok := userNames[uid]; ok {
+		return name
+	}
+
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+
+	userNames[uid] = name
+
+	return name
+}
+
+// parseOwnership parses an owner given as user[:group], by name or id, the group defaults to the user's primary group
+func parseOwnership(spec string) (ownership, error) {
+	name, group := spec, ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, group = spec[:i], spec[i+1:]
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		if u, err = user.LookupId(name); err != nil {
+			return ownership{}, fmt.Errorf("unknown user: %s", name)
+		}
+	}
+
+	gid := u.Gid
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return ownership{}, fmt.Errorf("unknown group: %s", group)
+			}
+		}
+
+		gid = g.Gid
+	}
+
+	var o ownership
+	if o.UID, err = strconv.Atoi(u.Uid); err != nil {
+		return o, fmt.Errorf("unsupported user id: %s", u.Uid)
+	}
+
+	if o.GID, err = strconv.Atoi(gid); err != nil {
+		return o, fmt.Errorf("unsupported group id: %s", gid)
+	}
+
+	return o, nil
+}
+
+// splitByOwner splits duplicate groups so that only files owned by uid are left to act on
+// Groups which contain files of other users are also returned in full as cross-user groups, so they can be reported.
+// Files with an unknown owner are treated as if they belonged to uid.
+func splitByOwner(groups [][]string, uid int, owner func(string) (int, bool)) ([][]string, [][]string) {
+	var own, crossUser [][]string
+
+	for _, files := range groups {
+		var mine []string
+		for _, file := range files {
+			if o, ok := owner(file); ok && o != uid {
+				continue
+			}
+
+			mine = append(mine, file)
+		}
+
+		if len(mine) != len(files) {
+			crossUser = append(crossUser, files)
+		}
+
+		if len(mine) > 1 {
+			own = append(own, mine)
+		}
+	}
+
+	return own, crossUser
+}
+
+// mixedOwners returns true if the files of a group are owned by more than one user
+func mixedOwners(files []string) bool {
+	owners := map[int]bool{}
+	for _, file := range files {
+		if uid, ok := fileOwner(file); ok {
+			owners[uid] = true
+		}
+	}
+
+	return len(owners) > 1
+}
+
+// splitMixedOwners splits duplicate groups into the ones owned by a single user and the ones owned by multiple users
+func splitMixedOwners(groups [][]string) ([][]string, [][]string) {
+	var single, mixed [][]string
+	for _, files := range groups {
+		if mixedOwners(files) {
+			mixed = append(mixed, files)
+		} else {
+			single = append(single, files)
+		}
+	}
+
+	return single, mixed
+}
+
+// groupOwnerships returns the ownership of the files of each decision, as they are before being acted on
+func groupOwnerships(decisions []decision) map[string]ownership {
+	res := map[string]ownership{}
+	for _, d := range decisions {
+		for _, file := range append([]string{d.keep}, d.files...) {
+			if o, ok := fileOwnership(file); ok {
+				res[file] = o
+			}
+		}
+	}
+
+	return res
+}
+
+// chownKept transfers the files kept in groups which were owned by multiple users to a single owner
+// Hard links share the owner of the file kept, so the merged files end up owned by that owner as well.
+func chownKept(decisions []decision, owners map[string]ownership, to ownership, dryRun bool) {
+	for _, d := range decisions {
+		seen := map[int]bool{}
+		for _, file := range append([]string{d.keep}, d.files...) {
+			if o, ok := owners[file]; ok {
+				seen[o.UID] = true
+			}
+		}
+
+		if len(seen) < 2 || owners[d.keep] == to {
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("Owner of %s would be changed from %s to %s\n", d.keep, owners[d.keep], to)
+			continue
+		}
+
+		if err := os.Lchown(d.keep, to.UID, to.GID); err != nil {
+			fmt.Printf("can't change owner of file: %s, err %v\n", d.keep, err)
+			continue
+		}
+
+		fmt.Printf("Owner of %s changed from %s to %s\n", d.keep, owners[d.keep], to)
+	}
+}
+
+// reportCrossUser lists duplicate groups spanning multiple owners, the files of the other users in them were left alone
+func reportCrossUser(groups [][]string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Printf("The following duplicates are owned by multiple users, the files of the other users were left alone (use -as-admin to include them):\n\n")
+	listOwners(groups)
+}
+
+// reportMixedOwners lists duplicate groups spanning multiple owners, which were refused by -refuse-mixed-owners
+func reportMixedOwners(groups [][]string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Printf("The following duplicates are owned by multiple users and were not merged (-refuse-mixed-owners):\n\n")
+	listOwners(groups)
+}
+
+// listOwners lists the files of duplicate groups with the user id of their owners
+func listOwners(groups [][]string) {
+	for _, files := range groups {
+		for _, file := range files {
+			if uid, ok := fileOwner(file); ok {
+				fmt.Printf("[uid %d] %s\n", uid, file)
+			} else {
+				fmt.Printf("[uid ?] %s\n", file)
+			}
+		}
+
+		fmt.Println()
+	}
+}
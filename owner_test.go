@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_splitByOwner(t *testing.T) {
+	owners := map[string]int{
+		"mine-1":  1000,
+		"mine-2":  1000,
+		"mine-3":  1000,
+		"other-1": 1001,
+		"other-2": 1001,
+	}
+	owner := func(path string) (int, bool) {
+		uid, ok := owners[path]
+		return uid, ok
+	}
+
+	type args struct {
+		groups [][]string
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantOwn       [][]string
+		wantCrossUser [][]string
+	}{
+		{
+			"all-mine",
+			args{
+				[][]string{{"mine-1", "mine-2"}},
+			},
+			[][]string{{"mine-1", "mine-2"}},
+			nil,
+		},
+		{
+			"mixed-keeps-own-part",
+			args{
+				[][]string{{"mine-1", "other-1", "mine-2"}},
+			},
+			[][]string{{"mine-1", "mine-2"}},
+			[][]string{{"mine-1", "other-1", "mine-2"}},
+		},
+		{
+			"single-own-file-left",
+			args{
+				[][]string{{"mine-3", "other-1", "other-2"}},
+			},
+			nil,
+			[][]string{{"mine-3", "other-1", "other-2"}},
+		},
+		{
+			"unknown-owner-treated-as-mine",
+			args{
+				[][]string{{"mine-1", "unknown"}},
+			},
+			[][]string{{"mine-1", "unknown"}},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			own, crossUser := splitByOwner(tt.args.groups, 1000, owner)
+			if !reflect.DeepEqual(own, tt.wantOwn) {
+				t.Errorf("splitByOwner() own = %v, want %v", own, tt.wantOwn)
+			}
+			if !reflect.DeepEqual(crossUser, tt.wantCrossUser) {
+				t.Errorf("splitByOwner() crossUser = %v, want %v", crossUser, tt.wantCrossUser)
+			}
+		})
+	}
+}
+
+func Test_parseOwnership(t *testing.T) {
+	if !chownSupported {
+		t.Skip("ownership is not supported on this platform")
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+	want := ownership{uid, gid}
+
+	for _, spec := range []string{u.Username, u.Uid, u.Uid + ":" + u.Gid} {
+		if got, err := parseOwnership(spec); err != nil || got != want {
+			t.Errorf("parseOwnership(%q) = %v, %v, want %v", spec, got, err, want)
+		}
+	}
+
+	if _, err := parseOwnership("no-such-user-dblfinder"); err == nil {
+		t.Errorf("parseOwnership() expected an error for an unknown user")
+	}
+}
+
+func Test_chownKept(t *testing.T) {
+	if !chownSupported {
+		t.Skip("ownership is not supported on this platform")
+	}
+
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keep := filepath.Join(dir, "keep")
+	if err := ioutil.WriteFile(keep, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	current, ok := fileOwnership(keep)
+	if !ok {
+		t.Fatal("fileOwnership() failed")
+	}
+
+	// the kept file is pretended to belong to another user, so that it's changed to the current one
+	other := ownership{current.UID + 1, current.GID}
+	owners := map[string]ownership{keep: other, "gone": current}
+
+	chownKept([]decision{{keep, []string{"gone"}, manualReason}}, owners, current, false)
+
+	if got, _ := fileOwnership(keep); got != current {
+		t.Errorf("chownKept() left owner %v, want %v", got, current)
+	}
+}
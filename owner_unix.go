@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// chownSupported tells if the owner of files can be changed
+const chownSupported = true
+
+// fileOwner returns the user id of the owner of a file
+func fileOwner(path string) (int, bool) {
+	o, ok := fileOwnership(path)
+
+	return o.UID, ok
+}
+
+// fileOwnership returns the user and group owning a file
+func fileOwnership(path string) (ownership, bool) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return ownership{}, false
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ownership{}, false
+	}
+
+	return ownership{int(st.Uid), int(st.Gid)}, true
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package main
+
+// chownSupported tells if the owner of files can be changed
+const chownSupported = false
+
+// fileOwner is not supported on Windows, files are treated as if they belonged to the invoking user
+func fileOwner(path string) (int, bool) {
+	return 0, false
+}
+
+// fileOwnership is not supported on Windows
+func fileOwnership(path string) (ownership, bool) {
+	return ownership{}, false
+}
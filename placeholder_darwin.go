@@ -0,0 +1,23 @@
+//go:build darwin
+// +build darwin
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// sfDataless is the flag of dataless files, whose content is fetched by a file provider (iCloud, Dropbox, OneDrive,
+// Google Drive) when read
+const sfDataless = 0x40000000
+
+// placeholderAttr returns true if a file is the placeholder of a file kept online by a cloud sync client
+func placeholderAttr(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	return st.Flags&sfDataless != 0 || (st.Blocks == 0 && fi.Size() > inodeInlineSize)
+}
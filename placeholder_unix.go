@@ -0,0 +1,21 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// placeholderAttr returns true if a file looks like the placeholder of a file kept online by a cloud sync client
+// Sync clients on FUSE report the size of the online file without any blocks allocated for it. Files fitting into
+// inodeInlineSize may be stored inline in the inode by some file systems, they are not taken for placeholders.
+func placeholderAttr(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	return st.Blocks == 0 && fi.Size() > inodeInlineSize
+}
@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// attributes of files whose content is fetched when opened or read, as set by OneDrive Files On-Demand, Dropbox
+// online-only files, Google Drive streaming and other cloud files providers
+const (
+	fileAttributeOffline            = 0x1000
+	fileAttributeRecallOnOpen       = 0x40000
+	fileAttributeRecallOnDataAccess = 0x400000
+)
+
+// placeholderAttr returns true if a file is the placeholder of a file kept online by a cloud sync client
+func placeholderAttr(fi os.FileInfo) bool {
+	data, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+
+	return data.FileAttributes&(fileAttributeOffline|fileAttributeRecallOnOpen|fileAttributeRecallOnDataAccess) != 0
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runApply implements the apply command, which acts on the decisions of a plan: the audit log of a dry run
+// The decisions applied are appended to the plan, so that applying it again skips them. Plans where a file kept by one
+// decision is acted on by another are refused. The plan may be older than the files, so files which are no longer
+// identical to the file kept instead of them are left alone.
+func runApply(args []string) error {
+	var (
+		dryRun                 bool
+		target, marksFile      string
+		trashBackend, auditLog string
+	)
+
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "only report what would be done with the files of the plan")
+	fs.StringVar(&target, "target", "", "quarantine directory used by the move action")
+	fs.StringVar(&marksFile, "marks-file", defaultMarksFile(), "file storing the list of files marked for deletion by the mark action")
+	fs.StringVar(&trashBackend, "trash-backend", trashAuto, "how the trash action moves files to the trash ("+strings.Join(trashBackends, ", ")+")")
+	fs.StringVar(&auditLog, "audit-log", "", "append the decisions acted on to this file as JSON lines")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder apply [-dry-run] [-target <d>] [-audit-log <f>] <plan> [<root>...]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("a plan is expected, write one with -dry-run -audit-log <f>")
+	}
+
+	if !validTrashBackend(trashBackend) {
+		return unknownValue("trash backend", trashBackend, trashBackends)
+	}
+
+	plan := fs.Arg(0)
+
+	entries, err := loadAuditLog(plan)
+	if err != nil {
+		return err
+	}
+
+	actions, planned := planDecisions(entries)
+	if len(actions) == 0 {
+		fmt.Println("Nothing is left to apply in the plan")
+		return nil
+	}
+
+	var all []decision
+	for _, a := range actions {
+		all = append(all, planned[a]...)
+	}
+
+	if err := checkKeptFiles(all); err != nil {
+		return err
+	}
+
+	roots := fs.Args()[1:]
+	for _, a := range actions {
+		if a == moveAction && (target == "" || len(roots) == 0) {
+			return fmt.Errorf("the plan moves files, -target and the roots the files were found under are required")
+		}
+	}
+
+	for _, a := range actions {
+		var decisions []decision
+		for _, d := range planned[a] {
+			if files := verifyDeleteFiles(d.keep, d.files); len(files) > 0 {
+				decisions = append(decisions, decision{d.keep, files, d.reason})
+			}
+		}
+
+		act(decisions, options{
+			action:       a,
+			dryRun:       dryRun,
+			roots:        roots,
+			target:       target,
+			marksFile:    marksFile,
+			trashBackend: trashBackend,
+			auditLog:     auditLog,
+		})
+
+		if dryRun {
+			continue
+		}
+
+		if err := writeAuditLog(plan, decisions, nil, options{action: a}); err != nil {
+			return fmt.Errorf("failed recording the decisions applied in the plan: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// planDecisions returns the decisions of a plan which are still to be applied, by action, and the actions in the
+// order they first appear in the plan
+// Entries which were not recorded in a dry run were applied already, along with the files of earlier dry run entries
+// they act on the same way. Listing doesn't act on files.
+func planDecisions(entries []auditEntry) ([]action, map[action][]decision) {
+	var (
+		pending []auditEntry
+		applied = map[action]map[string]bool{}
+	)
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Action == listAction {
+			continue
+		}
+
+		if applied[e.Action] == nil {
+			applied[e.Action] = map[string]bool{}
+		}
+
+		if !e.DryRun {
+			for _, file := range e.Files {
+				applied[e.Action][file] = true
+			}
+			continue
+		}
+
+		var files []string
+		for _, file := range e.Files {
+			if !applied[e.Action][file] {
+				files = append(files, file)
+			}
+		}
+
+		if len(files) > 0 {
+			e.Files = files
+			pending = append(pending, e)
+		}
+	}
+
+	var actions []action
+	res := map[action][]decision{}
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		e := pending[i]
+		if _, ok := res[e.Action]; !ok {
+			actions = append(actions, e.Action)
+		}
+
+		res[e.Action] = append(res[e.Action], decision{e.Keep, e.Files, e.Reason})
+	}
+
+	return actions, res
+}
+
+// checkKeptFiles returns an error if a file kept by any of the decisions is acted on by another one, as acting on both
+// could leave no copy of the content
+func checkKeptFiles(decisions []decision) error {
+	actOn := map[string]bool{}
+	for _, d := range decisions {
+		for _, file := range d.files {
+			actOn[file] = true
+		}
+	}
+
+	for _, d := range decisions {
+		if actOn[d.keep] {
+			return fmt.Errorf("the file kept can't be acted on: %s", d.keep)
+		}
+	}
+
+	return nil
+}
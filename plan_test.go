@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_planDecisions(t *testing.T) {
+	entries := []auditEntry{
+		{DryRun: true},
+		{DryRun: false},
+		{DryRun: true},
+		{DryRun: true},
+	}
+	entries[0].Action, entries[0].Keep, entries[0].Files = trashAction, "a", []string{"b"}
+	entries[1].Action, entries[1].Keep, entries[1].Files = linkAction, "c", []string{"d"}
+	entries[2].Action, entries[2].Keep, entries[2].Files = linkAction, "e", []string{"f"}
+	entries[3].Action, entries[3].Keep, entries[3].Files = trashAction, "g", nil
+
+	actions, decisions := planDecisions(entries)
+
+	if len(actions) != 2 || actions[0] != trashAction || actions[1] != linkAction {
+		t.Errorf("planDecisions() actions = %v, want trash then hardlink", actions)
+	}
+
+	if len(decisions[trashAction]) != 1 || len(decisions[linkAction]) != 1 || decisions[linkAction][0].keep != "e" {
+		t.Errorf("planDecisions() = %+v, want the dry run decisions with files", decisions)
+	}
+}
+
+func Test_planDecisions_applied(t *testing.T) {
+	entries := []auditEntry{
+		{DryRun: true},
+		{DryRun: false},
+		{DryRun: false},
+	}
+	entries[0].Action, entries[0].Keep, entries[0].Files = trashAction, "a", []string{"b", "c"}
+	entries[1].Action, entries[1].Keep, entries[1].Files = linkAction, "a", []string{"c"}
+	entries[2].Action, entries[2].Keep, entries[2].Files = trashAction, "a", []string{"b"}
+
+	actions, decisions := planDecisions(entries)
+
+	if len(actions) != 1 || len(decisions[trashAction]) != 1 || len(decisions[trashAction][0].files) != 1 || decisions[trashAction][0].files[0] != "c" {
+		t.Errorf("planDecisions() = %v, %+v, want the files not trashed since the dry run", actions, decisions)
+	}
+}
+
+func Test_checkKeptFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		decisions []decision
+		wantErr   bool
+	}{
+		{"separate", []decision{{"a", []string{"b"}, manualReason}, {"c", []string{"d"}, manualReason}}, false},
+		{"same-keep", []decision{{"a", []string{"b"}, manualReason}, {"a", []string{"c"}, manualReason}}, false},
+		{"keep-each-other", []decision{{"a", []string{"b"}, manualReason}, {"b", []string{"a"}, manualReason}}, true},
+		{"keep-acted-on", []decision{{"a", []string{"a"}, manualReason}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkKeptFiles(tt.decisions); (err != nil) != tt.wantErr {
+				t.Errorf("checkKeptFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_runApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	for path, content := range map[string]string{a: "abcd", b: "abcd", c: "ABCD"} {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan := filepath.Join(dir, "plan.jsonl")
+	if err := writeAuditLog(plan, []decision{{a, []string{b, c}, manualReason}}, nil, options{action: linkAction, dryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runApply([]string{plan}); err != nil {
+		t.Fatal(err)
+	}
+
+	if linked, err := sameFile(a, b); err != nil || !linked {
+		t.Errorf("apply didn't link %s to %s: %v", b, a, err)
+	}
+
+	if linked, err := sameFile(a, c); err != nil || linked {
+		t.Errorf("apply linked %s, which is no longer a duplicate: %v", c, err)
+	}
+
+	entries, err := loadAuditLog(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, left := planDecisions(entries); len(left[linkAction]) != 1 || len(left[linkAction][0].files) != 1 || left[linkAction][0].files[0] != c {
+		t.Errorf("apply left %+v to apply again, want only the file which was left alone", left)
+	}
+
+	conflicting := filepath.Join(dir, "conflicting.jsonl")
+	if err := writeAuditLog(conflicting, []decision{{a, []string{c}, manualReason}, {c, []string{a}, manualReason}}, nil, options{action: deleteAction, dryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runApply([]string{conflicting}); err == nil {
+		t.Errorf("apply accepted a plan deleting the file kept by another decision")
+	}
+
+	if !exists(a) || !exists(c) {
+		t.Errorf("apply of a conflicting plan left a %v, c %v, want both kept", exists(a), exists(c))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// listFlag is a flag which can be repeated, collecting its values in order
+type listFlag []string
+
+// String returns the values of the flag separated by commas
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends a value to the flag
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+
+	return nil
+}
+
+// compilePreferred compiles prefer patterns, keeping their order
+func compilePreferred(patterns []string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, pattern := range patterns {
+		res = append(res, regexp.MustCompile(pattern))
+	}
+
+	return res
+}
+
+// preferredFiles returns the indexes of the files of a group matching the first prefer pattern which matches any
+// of them, later patterns are only used to break the tie if no earlier one matched. The number of the pattern which
+// matched is also returned, starting from 1, or 0 if none did.
+func preferredFiles(files []string, patterns []*regexp.Regexp) (map[int]bool, int) {
+	for i, re := range patterns {
+		res := map[int]bool{}
+		for key, file := range files {
+			if re.MatchString(file) {
+				res[key] = true
+			}
+		}
+
+		if len(res) > 0 {
+			return res, i + 1
+		}
+	}
+
+	return map[int]bool{}, 0
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_preferredFiles(t *testing.T) {
+	files := []string{"/copies/a.jpg", "/originals/a.jpg", "/copies/a.raw", "/originals/b.raw"}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     map[int]bool
+		wantRank int
+	}{
+		{
+			"none",
+			nil,
+			map[int]bool{},
+			0,
+		},
+		{
+			"first-pattern-wins",
+			[]string{"^/originals/", `\.raw$`},
+			map[int]bool{1: true, 3: true},
+			1,
+		},
+		{
+			"falls-back-to-next-pattern",
+			[]string{"^/archive/", `\.raw$`},
+			map[int]bool{2: true, 3: true},
+			2,
+		},
+		{
+			"nothing-matches",
+			[]string{"^/archive/"},
+			map[int]bool{},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rank := preferredFiles(files, compilePreferred(tt.patterns))
+			if !reflect.DeepEqual(got, tt.want) || rank != tt.wantRank {
+				t.Errorf("preferredFiles() = %v, %d, want %v, %d", got, rank, tt.want, tt.wantRank)
+			}
+		})
+	}
+}
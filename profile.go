@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+const (
+	defaultProfile = "default"
+	nasLiteProfile = "nas-lite"
+)
+
+// runtimeProfile tunes a run for the machine it runs on
+// Flags are defaults which are overridden by the command line and the environment, the rest tunes the runtime.
+type runtimeProfile struct {
+	flags      map[string]string
+	bufferSize int
+	gcPercent  int
+	gcInterval time.Duration
+}
+
+// profiles lists the runtime profiles available by -profile
+// nas-lite is meant for ARM NAS devices with little memory: few files are read at once with small buffers, and
+// memory is returned to the OS eagerly, at the cost of throughput. The file index is still held in memory, so memory
+// use grows with the number of files scanned.
+var profiles = map[string]runtimeProfile{
+	defaultProfile: {
+		bufferSize: 32 * 1024,
+	},
+	nasLiteProfile: {
+		flags: map[string]string{
+			"fs-limit":         "2",
+			"thumb-cache-size": "20",
+		},
+		bufferSize: 8 * 1024,
+		gcPercent:  50,
+		gcInterval: 30 * time.Second,
+	},
+}
+
+// readBufferSize is the size of the buffers files are read into when hashed, set by the runtime profile
+var readBufferSize = profiles[defaultProfile].bufferSize
+
+// profileNames returns the names of the runtime profiles
+func profileNames() []string {
+	var res []string
+	for name := range profiles {
+		res = append(res, name)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// applyProfile sets the flags of a runtime profile which are not given on the command line nor in the environment
+// It must be called after applyEnv, so that flags set from the environment are seen as given.
+func applyProfile(fs *flag.FlagSet, name string) error {
+	p, ok := profiles[name]
+	if !ok {
+		return unknownValue("profile", name, profileNames())
+	}
+
+	given := givenFlags(fs)
+
+	for flagName, value := range p.flags {
+		if given[flagName] {
+			continue
+		}
+
+		if err := fs.Set(flagName, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s in profile %s: %v", value, flagName, name, err)
+		}
+	}
+
+	return nil
+}
+
+// tuneRuntime applies the runtime settings of a profile, once the flags are parsed
+func tuneRuntime(name string) {
+	p := profiles[name]
+
+	readBufferSize = p.bufferSize
+
+	if p.gcPercent > 0 {
+		debug.SetGCPercent(p.gcPercent)
+	}
+
+	if p.gcInterval > 0 {
+		go func() {
+			defer recoverPanic()
+
+			for range time.Tick(p.gcInterval) {
+				debug.FreeOSMemory()
+			}
+		}()
+	}
+}
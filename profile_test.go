@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func Test_applyProfile(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		profile       string
+		wantFsLimit   int
+		wantThumbSize int
+		wantErr       string
+	}{
+		{"default", nil, defaultProfile, 10, 100, ""},
+		{"nas-lite", nil, nasLiteProfile, 2, 20, ""},
+		{"command-line-wins", []string{"-fs-limit", "4"}, nasLiteProfile, 4, 20, ""},
+		{"unknown", nil, "nas-lte", 10, 100, "did you mean nas-lite?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fsLimit, thumbSize int
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.IntVar(&fsLimit, "fs-limit", 10, "")
+			fs.IntVar(&thumbSize, "thumb-cache-size", 100, "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyProfile(fs, tt.profile)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("applyProfile() error = %v, want %q", err, tt.wantErr)
+			}
+
+			if fsLimit != tt.wantFsLimit || thumbSize != tt.wantThumbSize {
+				t.Errorf("applyProfile() fs-limit = %d, thumb-cache-size = %d, want %d, %d", fsLimit, thumbSize, tt.wantFsLimit, tt.wantThumbSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressWidth    = 30
+	progressInterval = 200 * time.Millisecond
+
+	// progress modes of -progress
+	progressAuto = "auto"
+	progressJSON = "json"
+	progressNone = "none"
+
+	// progressEventInterval is the time between JSON progress events
+	progressEventInterval = time.Second
+)
+
+// progressModes lists the progress modes available
+var progressModes = []string{progressAuto, progressJSON, progressNone}
+
+var (
+	// hashProgress shows how far hashing got, it's nil if no progress bar is shown
+	hashProgress *progressBar
+
+	// jsonProgress emits progress events, it's nil unless -progress json is given
+	jsonProgress *progressEmitter
+
+	// currentPath is the path of the file processed last, reported in progress events
+	currentPath atomic.Value
+)
+
+// setCurrentPath records the path of the file being processed
+func setCurrentPath(path string) {
+	if jsonProgress != nil {
+		currentPath.Store(path)
+	}
+}
+
+// progressBar redraws a line with the files processed out of a total, the bytes read, the throughput and the time
+// remaining, until it's finished
+type progressBar struct {
+	w     io.Writer
+	total int64
+	done  int64
+	bytes func() int64
+	start time.Time
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+// validProgressMode returns true if mode is one of progressModes
+func validProgressMode(mode string) bool {
+	for _, m := range progressModes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// showProgress returns true if a progress bar can be drawn on stdout
+// Progress is only drawn on terminals, as redrawn lines are noise in logs, and never in plain or quiet mode.
+func showProgress() bool {
+	return !plainMode && !quietMode && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// newProgressBar starts drawing a progress bar of total files to w, bytes returns the number of bytes read so far
+func newProgressBar(w io.Writer, total int64, bytes func() int64) *progressBar {
+	p := &progressBar{w: w, total: total, bytes: bytes, start: time.Now(), stop: make(chan struct{})}
+
+	p.wg.Add(1)
+	go func() {
+		defer recoverPanic()
+		defer p.wg.Done()
+
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				fmt.Fprintf(p.w, "\r%s", p.line(time.Now()))
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// advance counts files as processed, it's safe to call on a nil progress bar
+func (p *progressBar) advance(files int) {
+	if p == nil {
+		return
+	}
+
+	atomic.AddInt64(&p.done, int64(files))
+}
+
+// finish draws the final state of the progress bar and ends its line, it's safe to call on a nil progress bar and
+// more than once
+func (p *progressBar) finish() {
+	if p == nil {
+		return
+	}
+
+	p.once.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+
+		fmt.Fprintf(p.w, "\r%s\n", p.line(time.Now()))
+	})
+}
+
+// line renders the progress bar as of now
+func (p *progressBar) line(now time.Time) string {
+	done, bytes := atomic.LoadInt64(&p.done), p.bytes()
+	elapsed := now.Sub(p.start)
+
+	filled := progressWidth
+	if p.total > 0 && done < p.total {
+		filled = int(done * progressWidth / p.total)
+	}
+
+	line := fmt.Sprintf("[%s%s] %d/%d files, %.1f MB read", strings.Repeat("#", filled), strings.Repeat("-", progressWidth-filled), done, p.total, float64(bytes)/1e6)
+
+	if secs := elapsed.Seconds(); secs > 0 {
+		line += fmt.Sprintf(", %.1f MB/s", float64(bytes)/secs/1e6)
+	}
+
+	if done > 0 && done < p.total {
+		eta := time.Duration(float64(elapsed) * float64(p.total-done) / float64(done))
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+
+	// trailing spaces clear what's left of a longer line drawn before
+	return line + "   "
+}
+
+// progressEvent is a JSON progress event, files and bytes are counted within the phase
+// Files are counted once they are done with in phases with a known total, as they may be read more than once.
+type progressEvent struct {
+	Time        time.Time `json:"time"`
+	Phase       string    `json:"phase"`
+	FilesDone   int64     `json:"files_done"`
+	FilesTotal  int64     `json:"files_total,omitempty"`
+	BytesDone   int64     `json:"bytes_done"`
+	CurrentPath string    `json:"current_path,omitempty"`
+}
+
+// progressEmitter writes a progress event as a JSON line periodically, for wrappers drawing their own progress
+type progressEmitter struct {
+	enc   *json.Encoder
+	mu    sync.Mutex
+	phase string
+	total int64
+	done  int64
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newProgressEmitter starts writing progress events to w
+func newProgressEmitter(w io.Writer) *progressEmitter {
+	e := &progressEmitter{enc: json.NewEncoder(w), stop: make(chan struct{})}
+
+	e.wg.Add(1)
+	go func() {
+		defer recoverPanic()
+		defer e.wg.Done()
+
+		t := time.NewTicker(progressEventInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				e.emit()
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+
+	return e
+}
+
+// setPhase switches to a phase of the run, one of the stages timed by stats, total is the number of files to be
+// processed in it if known, it's safe to call on a nil emitter
+func (e *progressEmitter) setPhase(phase string, total int64) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	e.phase, e.total = phase, total
+	atomic.StoreInt64(&e.done, 0)
+	e.mu.Unlock()
+
+	e.emit()
+}
+
+// emit writes the current progress as an event
+func (e *progressEmitter) emit() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.phase == "" {
+		return
+	}
+
+	s := stats.stage(e.phase)
+	path, _ := currentPath.Load().(string)
+
+	done := atomic.LoadInt64(&s.files)
+	if e.total > 0 {
+		done = atomic.LoadInt64(&e.done)
+	}
+
+	e.enc.Encode(progressEvent{time.Now(), e.phase, done, e.total, atomic.LoadInt64(&s.bytes), path})
+}
+
+// advance counts files of the phase as done, it's safe to call on a nil emitter
+func (e *progressEmitter) advance(files int) {
+	if e == nil {
+		return
+	}
+
+	atomic.AddInt64(&e.done, int64(files))
+}
+
+// finish stops the events after writing a last one, it's safe to call on a nil emitter
+func (e *progressEmitter) finish() {
+	if e == nil {
+		return
+	}
+
+	close(e.stop)
+	e.wg.Wait()
+
+	e.emit()
+}
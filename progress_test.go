@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_progressBar_line(t *testing.T) {
+	start := time.Now()
+	p := &progressBar{total: 100, done: 25, bytes: func() int64 { return 50e6 }, start: start}
+
+	got := p.line(start.Add(10 * time.Second))
+
+	want := "[#######-----------------------] 25/100 files, 50.0 MB read, 5.0 MB/s, ETA 30s"
+	if strings.TrimRight(got, " ") != want {
+		t.Errorf("line() = %q, want %q", got, want)
+	}
+}
+
+func Test_progressBar_finish(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgressBar(&buf, 4, func() int64 { return 0 })
+
+	p.advance(4)
+	p.finish()
+	p.finish()
+
+	if got := buf.String(); !strings.HasSuffix(got, "\n") || !strings.Contains(got, "4/4 files") || strings.Count(got, "\n") != 1 {
+		t.Errorf("finish() wrote %q", got)
+	}
+
+	// a nil progress bar, used when no progress is shown, does nothing
+	var none *progressBar
+	none.advance(1)
+	none.finish()
+}
+
+func Test_progressEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	e := newProgressEmitter(&buf)
+
+	e.setPhase(sampleStage, 4)
+	e.advance(3)
+	e.finish()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	var last progressEvent
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatal(err)
+	}
+
+	if last.Phase != sampleStage || last.FilesDone != 3 || last.FilesTotal != 4 {
+		t.Errorf("progressEmitter wrote %+v, want 3 of 4 files sampled", last)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+var (
+	stdinOnce  sync.Once
+	stdinLines chan string
+	editor     *term.Terminal
+
+	// plainMode makes prompts screen reader friendly, see -plain
+	plainMode bool
+)
+
+// errAborted is returned by prompts when the user aborts the run, nothing is to be acted on then
+var errAborted = errors.New("aborted, nothing was changed")
+
+// readLine returns the next line read from standard in, or an empty string at the end of the input
+// If timeout is positive and no line arrives in time, false is returned. Lines are read in the background, so a line
+// typed after a timeout is the answer to the next prompt. Without a timeout, answers typed in a terminal can be
+// edited and earlier answers can be recalled with the arrow keys, unless in plain mode, as redrawing the line confuses
+// screen readers. errAborted is returned if the user aborts the run while editing.
+func readLine(timeout time.Duration) (string, bool, error) {
+	if timeout <= 0 && !plainMode && term.IsTerminal(int(os.Stdin.Fd())) {
+		line, err := editLine()
+		return line, true, err
+	}
+
+	stdinOnce.Do(func() {
+		stdinLines = make(chan string)
+
+		go func() {
+			defer recoverPanic()
+
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				stdinLines <- scanner.Text()
+			}
+
+			close(stdinLines)
+		}()
+	})
+
+	if timeout <= 0 {
+		return <-stdinLines, true, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case line := <-stdinLines:
+		return line, true, nil
+	case <-timer.C:
+		return "", false, nil
+	}
+}
+
+// editLine reads a line in a terminal with line editing and a history of the earlier answers
+// The terminal is only in raw mode while reading. As raw mode swallows the interrupt signal, Ctrl-C (and Ctrl-D)
+// return errAborted, so that the run ends without acting on any of the groups decided so far, like an interrupt would.
+func editLine() (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	if editor == nil {
+		editor = term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, "> ")
+	}
+
+	defer term.Restore(fd, state)
+
+	return readEdited(editor)
+}
+
+// readEdited reads a line edited in the terminal, the end of the input means the user aborted the run
+func readEdited(t *term.Terminal) (string, error) {
+	line, err := t.ReadLine()
+	if err == io.EOF {
+		return "", errAborted
+	}
+
+	return line, nil
+}
+
+// reportUndecided lists the groups skipped because their prompt timed out
+func reportUndecided(groups [][]string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Printf("%d group(s) left undecided:\n", len(groups))
+	for _, files := range groups {
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		fmt.Println()
+	}
+}
+
+// confirm asks a yes or no question, anything but a yes (or no answer within the timeout) counts as a no
+func confirm(question string, timeout time.Duration) (bool, error) {
+	fmt.Printf("%s (y/N)\n", question)
+
+	s, answered, err := readLine(timeout)
+	if err != nil || !answered {
+		return false, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes":
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/term"
+)
+
+func Test_readEdited(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{"answer", "1 3\r", "1 3", nil},
+		{"edited", "1 4\x7f3\r", "1 3", nil},
+		{"ctrl-c", "1\x03", "", errAborted},
+		{"ctrl-d", "\x04", "", errAborted},
+		{"end-of-input", "", "", errAborted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			editor := term.NewTerminal(struct {
+				io.Reader
+				io.Writer
+			}{strings.NewReader(tt.input), &out}, "> ")
+
+			got, err := readEdited(editor)
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("readEdited() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_confirm(t *testing.T) {
+	stdinLines = make(chan string, 10)
+	stdinOnce.Do(func() {})
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"y", true},
+		{" Yes ", true},
+		{"n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			stdinLines <- tt.line
+
+			if got, err := confirm("Apply?", 0); got != tt.want || err != nil {
+				t.Errorf("confirm() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// protectRegexpPrefix marks a protect pattern as a regexp instead of a glob
+const protectRegexpPrefix = "re:"
+
+// pathPattern matches paths either with a glob or with a regexp
+type pathPattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// parsePathPatterns parses patterns which are globs, or regexps if prefixed with "re:"
+func parsePathPatterns(patterns []string) ([]pathPattern, error) {
+	var res []pathPattern
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, protectRegexpPrefix) {
+			re, err := regexp.Compile(strings.TrimPrefix(pattern, protectRegexpPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern: %s, err %v", pattern, err)
+			}
+
+			res = append(res, pathPattern{re: re})
+			continue
+		}
+
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern: %s, err %v", pattern, err)
+		}
+
+		res = append(res, pathPattern{glob: pattern})
+	}
+
+	return res, nil
+}
+
+// match returns true if path matches the pattern
+func (p pathPattern) match(path string) bool {
+	if p.re != nil {
+		return p.re.MatchString(path)
+	}
+
+	return matchGlob(p.glob, path)
+}
+
+// matchGlob matches a path against a glob
+// Globs without a path separator are matched against the file name only, like in rsync.
+func matchGlob(glob, path string) bool {
+	name := path
+	if !strings.ContainsRune(filepath.ToSlash(glob), '/') {
+		name = filepath.Base(path)
+	}
+
+	ok, _ := filepath.Match(filepath.FromSlash(glob), name)
+
+	return ok
+}
+
+// matchAny returns true if path matches any of the patterns
+func matchAny(patterns []pathPattern, path string) bool {
+	for _, p := range patterns {
+		if p.match(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutProtected removes the protected files from the files chosen to act on
+func withoutProtected(files []string, protect []pathPattern) []string {
+	var res []string
+	for _, file := range files {
+		if matchAny(protect, file) {
+			fmt.Printf("Protected: %s\n", file)
+			continue
+		}
+
+		res = append(res, file)
+	}
+
+	return res
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_matchAny(t *testing.T) {
+	patterns, err := parsePathPatterns([]string{"*.raw", "/backup/*/keep", `re:work.*\.go$`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/photos/2020/a.raw", true},
+		{"/photos/2020/a.jpg", false},
+		{"/backup/x/keep", true},
+		{"/backup/x/y/keep", false},
+		{"/work/src/main.go", true},
+		{"/home/src/main.go", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := matchAny(patterns, filepath.FromSlash(tt.path)); got != tt.want {
+				t.Errorf("matchAny() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parsePathPatterns(t *testing.T) {
+	for _, pattern := range []string{"[", "re:("} {
+		if _, err := parsePathPatterns([]string{pattern}); err == nil {
+			t.Errorf("parsePathPatterns(%q) expected an error", pattern)
+		}
+	}
+}
+
+func Test_withoutProtected(t *testing.T) {
+	patterns, err := parsePathPatterns([]string{"*.raw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := withoutProtected([]string{"a.raw", "a.jpg", "b.jpg"}, patterns)
+	if want := []string{"a.jpg", "b.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("withoutProtected() = %v, want %v", got, want)
+	}
+}
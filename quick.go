@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// quickGroups groups same size files by their extension (or by their name if they have none) without reading them
+func quickGroups(sameSizeFiles map[int64][]string) [][]string {
+	var res [][]string
+
+	for _, size := range sortedSizes(sameSizeFiles) {
+		var (
+			keys   []string
+			byType = map[string][]string{}
+		)
+
+		for _, file := range sameSizeFiles[size] {
+			key := quickKey(file)
+			if _, ok := byType[key]; !ok {
+				keys = append(keys, key)
+			}
+
+			byType[key] = append(byType[key], file)
+		}
+
+		for _, key := range keys {
+			if len(byType[key]) > 1 {
+				res = append(res, byType[key])
+			}
+		}
+	}
+
+	return res
+}
+
+// quickKey returns the normalized lowercase extension of a file, or its lowercase name if it has no extension
+func quickKey(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+
+	if ext := filepath.Ext(name); ext != "" && ext != name {
+		return "." + normalizeExt(ext[1:])
+	}
+
+	return name
+}
+
+// listQuickGroups prints groups of files which might be duplicates based on their size and type
+func listQuickGroups(groups [][]string) {
+	fmt.Println()
+
+	for i, files := range groups {
+		fmt.Printf("The following files have the same size and type (%d / %d):\n", i, len(groups))
+
+		for key, file := range files {
+			fmt.Printf("[%d] %s\n", key+1, file)
+		}
+
+		fmt.Println()
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_quickGroups(t *testing.T) {
+	type args struct {
+		sameSizeFiles map[int64][]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want [][]string
+	}{
+		{
+			"by-extension",
+			args{
+				map[int64][]string{
+					10: {"a/x.jpg", "b/y.JPG", "c/z.png"},
+				},
+			},
+			[][]string{{"a/x.jpg", "b/y.JPG"}},
+		},
+		{
+			"equivalent-extensions",
+			args{
+				map[int64][]string{
+					10: {"a/x.jpeg", "b/y.JPG", "c/z.tif", "d/w.tiff"},
+				},
+			},
+			[][]string{{"a/x.jpeg", "b/y.JPG"}, {"c/z.tif", "d/w.tiff"}},
+		},
+		{
+			"by-name-without-extension",
+			args{
+				map[int64][]string{
+					10: {"a/Makefile", "b/Makefile", "c/README"},
+				},
+			},
+			[][]string{{"a/Makefile", "b/Makefile"}},
+		},
+		{
+			"dotfiles-by-name-largest-first",
+			args{
+				map[int64][]string{
+					10: {"a/.bashrc", "b/.bashrc"},
+					20: {"a/x.txt", "b/y.txt"},
+				},
+			},
+			[][]string{{"a/x.txt", "b/y.txt"}, {"a/.bashrc", "b/.bashrc"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quickGroups(tt.args.sameSizeFiles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("quickGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+)
+
+// quietMode is set by -quiet, progress messages are not printed then, only the results of a run
+var quietMode bool
+
+// progressf prints a progress message, unless in quiet mode
+func progressf(format string, a ...interface{}) {
+	if quietMode {
+		return
+	}
+
+	fmt.Printf(format, a...)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates newname as a copy-on-write clone of oldname using clonefile (APFS)
+func cloneFile(oldname, newname string) error {
+	if err := unix.Clonefile(oldname, newname, unix.CLONE_NOFOLLOW); err != nil {
+		return &os.LinkError{Op: "clone", Old: oldname, New: newname, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates newname as a copy-on-write clone of oldname using the FICLONE ioctl (Btrfs, XFS)
+func cloneFile(oldname, newname string) error {
+	src, err := os.Open(oldname)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(newname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	err = unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(newname)
+		return &os.LinkError{Op: "clone", Old: oldname, New: newname, Err: err}
+	}
+
+	return nil
+}
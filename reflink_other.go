@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile is only supported on Linux and macOS
+func cloneFile(oldname, newname string) error {
+	return &os.LinkError{Op: "clone", Old: oldname, New: newname, Err: errors.New("reflinks are not supported on this platform")}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/peteraba/dblfinder/model"
+)
+
+const (
+	textOutput     = "text"
+	jsonOutput     = "json"
+	ndjsonOutput   = "ndjson"
+	csvOutput      = "csv"
+	fdupesOutput   = "fdupes"
+	htmlOutput     = "html"
+	markdownOutput = "markdown"
+
+	// templateOutput is used by -format, it's not listed in outputFormats as it needs a template
+	templateOutput = "template"
+)
+
+// outputFormats lists the formats duplicates can be reported in
+var outputFormats = []string{textOutput, jsonOutput, ndjsonOutput, csvOutput, fdupesOutput, htmlOutput, markdownOutput}
+
+// validOutput returns true if format is one of outputFormats
+func validOutput(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// the structured report serializes the result model shared by every output format
+type (
+	reportFile    = model.FileRef
+	reportGroup   = model.Group
+	reportSummary = model.Summary
+	report        = model.Run
+)
+
+// newReport creates the report of the duplicate groups found and the decisions applied to them
+func newReport(groups []reportGroup, decisions []decision, opts options) report {
+	r := report{
+		SchemaVersion: model.SchemaVersion,
+		Version:       version,
+		Roots:         opts.roots,
+		HashAlgorithm: opts.hashName,
+		SampleSize:    opts.sampleSize,
+		Groups:        append([]reportGroup{}, groups...),
+		Summary:       summarize(groups, decisions, opts),
+	}
+
+	for _, d := range decisions {
+		r.Decisions = append(r.Decisions, d.export(opts.action))
+	}
+
+	return r
+}
+
+// newReportGroups collects the details of duplicate groups, hashes maps each path to the hash of its group
+// The details are collected before acting on any of the files, so that the report describes them as found.
+func newReportGroups(groups [][]string, hashes map[string]string) []reportGroup {
+	var res []reportGroup
+	for _, files := range groups {
+		if g, ok := newReportGroup(files, hashes[files[0]]); ok {
+			res = append(res, g)
+		}
+	}
+
+	return res
+}
+
+// newReportGroup collects the details of the files of a duplicate group with the given hash
+// Files which can't be accessed any more are left out, false is returned if less than two files remain.
+func newReportGroup(files []string, sum string) (reportGroup, bool) {
+	g := reportGroup{Hash: hex.EncodeToString([]byte(sum))}
+
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			slog.Warn("can't stat file", "path", file, "err", err)
+			continue
+		}
+
+		g.Size = fi.Size()
+		g.Files = append(g.Files, reportFile{Path: file, Size: fi.Size(), ModTime: fi.ModTime(), Hash: g.Hash})
+	}
+
+	if len(g.Files) < 2 {
+		return g, false
+	}
+
+	g.Reclaimable = g.Size * int64(len(g.Files)-1)
+
+	return g, true
+}
+
+// groupStreamer returns a callback which writes each duplicate group found as a JSON line right away
+// Groups are narrowed down by the same scope options as the ones reported at the end of the run.
+func groupStreamer(w io.Writer, opts options, expected expectedDuplicates) func([]string, string) {
+	enc := json.NewEncoder(w)
+
+	return func(files []string, sum string) {
+		groups := [][]string{files}
+
+		if opts.acrossRoots {
+			groups = acrossRootsOnly(groups, opts.roots)
+		}
+
+		if opts.expected != "" {
+			groups = withoutExpected(groups, expected, opts.roots)
+		}
+
+		if opts.sameDir {
+			groups = sameDirOnly(groups)
+		}
+
+		if opts.minWaste > 0 {
+			groups = minWasteOnly(groups, opts.minWaste)
+		}
+
+		for _, files := range groups {
+			g, ok := newReportGroup(files, sum)
+			if !ok {
+				continue
+			}
+
+			if err := enc.Encode(g); err != nil {
+				slog.Error("failed writing group", "err", err)
+			}
+		}
+	}
+}
+
+// writeJSONReport writes a report as an indented JSON document
+func writeJSONReport(w io.Writer, r report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
+
+// fileActions returns the action taken on each file of the duplicate groups
+// The action is keep or the action used for the files decided on, and empty in groups without a decision.
+func fileActions(groups []reportGroup, decisions []decision, a action) map[string]string {
+	decided := map[string]string{}
+	for _, d := range decisions {
+		decided[d.keep] = "keep"
+		for _, file := range d.files {
+			decided[file] = string(a)
+		}
+	}
+
+	res := map[string]string{}
+	for _, g := range groups {
+		hasDecision := false
+		for _, f := range g.Files {
+			if decided[f.Path] != "" {
+				hasDecision = true
+			}
+		}
+
+		for _, f := range g.Files {
+			switch {
+			case decided[f.Path] != "":
+				res[f.Path] = decided[f.Path]
+			case hasDecision:
+				res[f.Path] = "keep"
+			}
+		}
+	}
+
+	return res
+}
+
+// writeCSVReport writes a row for each file of the duplicate groups, with the action taken on it
+func writeCSVReport(w io.Writer, groups []reportGroup, decisions []decision, opts options) error {
+	actions := fileActions(groups, decisions, opts.action)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"group", "path", "size", "hash", "action"})
+
+	for i, g := range groups {
+		for _, f := range g.Files {
+			cw.Write([]string{strconv.Itoa(i + 1), f.Path, strconv.FormatInt(f.Size, 10), f.Hash, actions[f.Path]})
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// writeFdupesReport writes the paths of the files of each duplicate group on separate lines, and groups separated by
+// an empty line, the way fdupes lists them
+// With print0 paths are terminated by NUL bytes instead, so that any file name can be passed on safely. A non-empty
+// groupSep replaces the empty line (or empty record) written after each group.
+func writeFdupesReport(w io.Writer, groups []reportGroup, print0 bool, groupSep string) error {
+	end := "\n"
+	if print0 {
+		end = "\x00"
+	}
+
+	if groupSep == "" {
+		groupSep = end
+	}
+
+	for _, g := range groups {
+		for _, f := range g.Files {
+			if _, err := io.WriteString(w, f.Path+end); err != nil {
+				return err
+			}
+		}
+
+		if _, err := io.WriteString(w, groupSep); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatLine holds the fields of a file available to -format templates
+type formatLine struct {
+	Group   int
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Hash    string
+}
+
+// writeTemplateReport writes a line for each file of the duplicate groups, shaped by a template
+func writeTemplateReport(w io.Writer, groups []reportGroup, tmpl *template.Template) error {
+	bw := bufio.NewWriter(w)
+
+	for i, g := range groups {
+		for _, f := range g.Files {
+			if err := tmpl.Execute(bw, formatLine{i + 1, f.Path, f.Size, f.ModTime, f.Hash}); err != nil {
+				return err
+			}
+
+			bw.WriteString("\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// runReport implements the report command, which renders a JSON report of an earlier run in another format
+func runReport(args []string) error {
+	var output, out, thumbCacheDir string
+
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.StringVar(&output, "output", htmlOutput, "format to render the report in ("+strings.Join(reportFormats, ", ")+")")
+	fs.StringVar(&out, "out", "", "write the report to this file instead of stdout")
+	fs.StringVar(&thumbCacheDir, "thumb-cache", defaultThumbCache(), "directory caching the thumbnails of images and videos shown in HTML reports, empty disables thumbnails")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder report [-output <s>] [-out <f>] <report.json>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("a JSON report is expected, write one with -output json")
+	}
+
+	valid := false
+	for _, f := range reportFormats {
+		valid = valid || f == output
+	}
+	if !valid {
+		return unknownValue("output format", output, reportFormats)
+	}
+
+	r, err := loadReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	opts := options{action: listAction, roots: r.Roots, hashName: r.HashAlgorithm, sampleSize: r.SampleSize, thumbs: thumbCache{thumbCacheDir, 100 << 20}}
+
+	var decisions []decision
+	for _, d := range r.Decisions {
+		opts.action = d.Action
+		decisions = append(decisions, decision{d.Keep, d.Files, d.Reason})
+	}
+
+	w := os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	switch output {
+	case jsonOutput:
+		return writeJSONReport(w, r)
+	case csvOutput:
+		return writeCSVReport(w, r.Groups, decisions, opts)
+	case fdupesOutput:
+		return writeFdupesReport(w, r.Groups, false, "")
+	case markdownOutput:
+		return writeMarkdownReport(w, r.Groups, decisions, opts)
+	}
+
+	return writeHTMLReport(w, r.Groups, opts)
+}
+
+// reportFormats lists the formats the report command renders reports in
+var reportFormats = []string{jsonOutput, csvOutput, fdupesOutput, htmlOutput, markdownOutput}
+
+// loadReport reads a JSON report written by -output json
+func loadReport(file string) (report, error) {
+	var r report
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return r, err
+	}
+
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("invalid report %s: %v", file, err)
+	}
+
+	if r.SchemaVersion > model.SchemaVersion {
+		return r, fmt.Errorf("the report %s has schema version %d, this version of dblfinder reads up to %d", file, r.SchemaVersion, model.SchemaVersion)
+	}
+
+	return r, nil
+}
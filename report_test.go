@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func Test_newReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	for _, path := range []string{a, b, c} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashes := map[string]string{a: "\x01\x02", b: "\x01\x02", c: "\x01\x02"}
+	groups := newReportGroups([][]string{{a, b, c}, {filepath.Join(dir, "missing"), a}}, hashes)
+
+	// c is deleted after being reported, so it counts as reclaimed
+	if err := os.Remove(c); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{hashName: defaultHash, roots: []string{dir}, action: deleteAction}
+	r := newReport(groups, []decision{{a, []string{c}, preferReason(1)}}, opts)
+
+	if len(r.Groups) != 1 {
+		t.Fatalf("newReport() groups = %v, want 1 group", r.Groups)
+	}
+
+	g := r.Groups[0]
+	if g.Size != 4 || g.Hash != "0102" || g.Reclaimable != 8 || len(g.Files) != 3 || g.Files[1].Path != b {
+		t.Errorf("newReport() group = %+v", g)
+	}
+
+	want := reportSummary{
+		FilesScanned: r.Summary.FilesScanned,
+		BytesHashed:  r.Summary.BytesHashed,
+		Groups:       1,
+		Files:        3,
+		Duplicates:   2,
+		Reclaimable:  8,
+		Reclaimed:    4,
+		ByRoot:       map[string]wasteStats{dir: {Duplicates: 2, Reclaimable: 8, Reclaimed: 4}},
+		ByExtension:  map[string]wasteStats{"": {Duplicates: 2, Reclaimable: 8, Reclaimed: 4}},
+	}
+	if !reflect.DeepEqual(r.Summary, want) {
+		t.Errorf("newReport() summary = %+v, want %+v", r.Summary, want)
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSONReport(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Summary, want) || decoded.HashAlgorithm != defaultHash {
+		t.Errorf("writeJSONReport() round trip = %+v", decoded)
+	}
+}
+
+func Test_groupStreamer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"r1", "r2"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, b, c := filepath.Join(dir, "r1", "a"), filepath.Join(dir, "r1", "b"), filepath.Join(dir, "r2", "c")
+	for _, path := range []string{a, b, c} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	opts := options{roots: []string{filepath.Join(dir, "r1"), filepath.Join(dir, "r2")}, acrossRoots: true}
+	found := groupStreamer(&buf, opts, expectedDuplicates{})
+
+	found([]string{a, b}, "\x01")
+	found([]string{a, c}, "\x02")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("groupStreamer() wrote %q, want a single line", buf.String())
+	}
+
+	var g reportGroup
+	if err := json.Unmarshal([]byte(lines[0]), &g); err != nil {
+		t.Fatal(err)
+	}
+	if g.Hash != "02" || len(g.Files) != 2 || g.Files[1].Path != c {
+		t.Errorf("groupStreamer() wrote %+v", g)
+	}
+}
+
+func Test_writeCSVReport(t *testing.T) {
+	groups := []reportGroup{
+		{Size: 4, Hash: "01", Files: []reportFile{{Path: "a", Size: 4, Hash: "01"}, {Path: "b", Size: 4, Hash: "01"}, {Path: "c", Size: 4, Hash: "01"}}},
+		{Size: 2, Hash: "02", Files: []reportFile{{Path: "d", Size: 2, Hash: "02"}, {Path: "e", Size: 2, Hash: "02"}}},
+	}
+	decisions := []decision{{"b", []string{"a"}, manualReason}}
+
+	var buf bytes.Buffer
+	if err := writeCSVReport(&buf, groups, decisions, options{action: trashAction}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `group,path,size,hash,action
+1,a,4,01,trash
+1,b,4,01,keep
+1,c,4,01,keep
+2,d,2,02,
+2,e,2,02,
+`
+	if buf.String() != want {
+		t.Errorf("writeCSVReport() = %q, want %q", buf.String(), want)
+	}
+}
+
+func Test_writeFdupesReport(t *testing.T) {
+	groups := []reportGroup{
+		{Files: []reportFile{{Path: "a"}, {Path: "b"}}},
+		{Files: []reportFile{{Path: "c d"}, {Path: "e"}, {Path: "f"}}},
+	}
+
+	tests := []struct {
+		name     string
+		print0   bool
+		groupSep string
+		want     string
+	}{
+		{"fdupes", false, "", "a\nb\n\nc d\ne\nf\n\n"},
+		{"print0", true, "", "a\x00b\x00\x00c d\x00e\x00f\x00\x00"},
+		{"print0-group-separator", true, "--\n", "a\x00b\x00--\nc d\x00e\x00f\x00--\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeFdupesReport(&buf, groups, tt.print0, tt.groupSep); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("writeFdupesReport() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeTemplateReport(t *testing.T) {
+	groups := []reportGroup{
+		{Files: []reportFile{{Path: "a", Size: 4, Hash: "01"}, {Path: "b", Size: 4, Hash: "01"}}},
+		{Files: []reportFile{{Path: "c", Size: 2, Hash: "02"}, {Path: "d", Size: 2, Hash: "02"}}},
+	}
+
+	tmpl := template.Must(template.New("format").Parse("{{.Group}} {{.Path}} {{.Size}} {{.Hash}}"))
+
+	var buf bytes.Buffer
+	if err := writeTemplateReport(&buf, groups, tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "1 a 4 01\n1 b 4 01\n2 c 2 02\n2 d 2 02\n"; buf.String() != want {
+		t.Errorf("writeTemplateReport() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// rootIndex returns the index of the first root containing a file, or len(roots) if none does
+func rootIndex(file string, roots []string) (int, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, root := range roots {
+		if absRoot, err := filepath.Abs(root); err == nil && inRoot(abs, absRoot) {
+			return i, nil
+		}
+	}
+
+	return len(roots), nil
+}
+
+// acrossRootsOnly drops the groups whose files are all within a single root
+func acrossRootsOnly(groups [][]string, roots []string) [][]string {
+	var res [][]string
+	for _, files := range groups {
+		seen := map[int]bool{}
+		for _, file := range files {
+			if i, err := rootIndex(file, roots); err == nil {
+				seen[i] = true
+			}
+		}
+
+		if len(seen) > 1 {
+			res = append(res, files)
+		}
+	}
+
+	return res
+}
+
+// sameDirOnly splits groups by directory, keeping the files which have a duplicate in their own directory
+func sameDirOnly(groups [][]string) [][]string {
+	var res [][]string
+	for _, files := range groups {
+		for _, group := range shardFiles(files, filepath.Dir) {
+			if len(group) > 1 {
+				res = append(res, group)
+			}
+		}
+	}
+
+	return res
+}
+
+// minWasteOnly drops the groups whose extra copies take less than min bytes
+func minWasteOnly(groups [][]string, min int64) [][]string {
+	var res [][]string
+	for _, files := range groups {
+		fi, err := os.Stat(files[0])
+		if err != nil || fi.Size()*int64(len(files)-1) < min {
+			continue
+		}
+
+		res = append(res, files)
+	}
+
+	return res
+}
+
+// sortByWaste orders groups by the space their extra copies take, the most wasteful first
+// Groups are otherwise ordered by map iteration, this way interactive sessions start with the groups freeing the most.
+// Groups wasting the same space are ordered by their first file, so that runs over the same files are repeatable.
+func sortByWaste(groups [][]string) {
+	waste := make(map[string]int64, len(groups))
+	for _, files := range groups {
+		if fi, err := os.Stat(files[0]); err == nil {
+			waste[files[0]] = fi.Size() * int64(len(files)-1)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		wi, wj := waste[groups[i][0]], waste[groups[j][0]]
+		if wi != wj {
+			return wi > wj
+		}
+
+		return groups[i][0] < groups[j][0]
+	})
+}
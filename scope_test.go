@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_acrossRootsOnly(t *testing.T) {
+	p := filepath.FromSlash
+	roots := []string{p("/old"), p("/new"), p("/new/nested")}
+
+	groups := [][]string{
+		{p("/old/a"), p("/old/b")},
+		{p("/old/c"), p("/new/c")},
+		{p("/new/d"), p("/new/nested/d")},
+		{p("/other/e"), p("/old/e")},
+	}
+
+	want := [][]string{
+		{p("/old/c"), p("/new/c")},
+		{p("/other/e"), p("/old/e")},
+	}
+
+	if got := acrossRootsOnly(groups, roots); !reflect.DeepEqual(got, want) {
+		t.Errorf("acrossRootsOnly() = %v, want %v", got, want)
+	}
+}
+
+func Test_sameDirOnly(t *testing.T) {
+	p := filepath.FromSlash
+	groups := [][]string{
+		{p("/a/x.jpg"), p("/b/x.jpg"), p("/a/x (1).jpg"), p("/b/x (1).jpg"), p("/c/x.jpg")},
+		{p("/a/y"), p("/b/y")},
+	}
+
+	want := [][]string{
+		{p("/a/x.jpg"), p("/a/x (1).jpg")},
+		{p("/b/x.jpg"), p("/b/x (1).jpg")},
+	}
+
+	if got := sameDirOnly(groups); !reflect.DeepEqual(got, want) {
+		t.Errorf("sameDirOnly() = %v, want %v", got, want)
+	}
+}
+
+func Test_sortByWaste(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sizes := map[string]int{"small": 10, "large": 100, "tie": 20, "twin": 20}
+	for name, size := range sizes {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := func(name string) string { return filepath.Join(dir, name) }
+
+	// small wastes 30 bytes with four copies, large 100 with two, tie and twin 20 each
+	groups := [][]string{
+		{p("twin"), p("twin")},
+		{p("small"), p("small"), p("small"), p("small")},
+		{p("tie"), p("tie")},
+		{p("large"), p("large")},
+	}
+
+	sortByWaste(groups)
+
+	var got []string
+	for _, files := range groups {
+		got = append(got, filepath.Base(files[0]))
+	}
+
+	if want := []string{"large", "small", "tie", "twin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sortByWaste() = %v, want %v", got, want)
+	}
+}
+
+func Test_minWasteOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	small, large := filepath.Join(dir, "small"), filepath.Join(dir, "large")
+	if err := ioutil.WriteFile(small, make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(large, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	groups := [][]string{
+		{small, small},
+		{small, small, small, small, small, small},
+		{large, large},
+		{filepath.Join(dir, "missing"), large},
+	}
+
+	want := [][]string{
+		{small, small, small, small, small, small},
+		{large, large},
+	}
+
+	if got := minWasteOnly(groups, 50); !reflect.DeepEqual(got, want) {
+		t.Errorf("minWasteOnly() = %v, want %v", got, want)
+	}
+}
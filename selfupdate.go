@@ -0,0 +1,258 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const releasesURL = "https://api.github.com/repos/peteraba/dblfinder/releases/latest"
+
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// selfUpdate replaces the running binary with the latest release published on GitHub
+func selfUpdate() error {
+	var rel release
+
+	data, err := download(releasesURL)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return fmt.Errorf("can't parse release information: %v", err)
+	}
+
+	latest := strings.TrimPrefix(rel.TagName, "v")
+	if !newerVersion(latest, version) {
+		fmt.Printf("dblfinder %s is up to date\n", version)
+		return nil
+	}
+
+	archiveName := releaseArchiveName(latest, runtime.GOOS, runtime.GOARCH)
+
+	archiveURL, checksumsURL := "", ""
+	for _, asset := range rel.Assets {
+		switch asset.Name {
+		case archiveName:
+			archiveURL = asset.URL
+		case "checksums.txt":
+			checksumsURL = asset.URL
+		}
+	}
+
+	if archiveURL == "" {
+		return fmt.Errorf("release %s has no archive for %s/%s", latest, runtime.GOOS, runtime.GOARCH)
+	}
+
+	if checksumsURL == "" {
+		return fmt.Errorf("release %s has no checksums, refusing to update", latest)
+	}
+
+	fmt.Printf("Downloading dblfinder %s...\n", latest)
+
+	checksums, err := download(checksumsURL)
+	if err != nil {
+		return err
+	}
+
+	archive, err := download(archiveURL)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyChecksum(archive, archiveName, checksums); err != nil {
+		return err
+	}
+
+	bin, err := extractBinary(archive, archiveName)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceExecutable(bin); err != nil {
+		return err
+	}
+
+	fmt.Printf("dblfinder updated from %s to %s\n", version, latest)
+
+	return nil
+}
+
+// download fetches the content of a URL
+func download(url string) ([]byte, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status downloading %s: %s", url, res.Status)
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
+
+// releaseArchiveName returns the name of the archive goreleaser publishes for a platform
+func releaseArchiveName(v, goos, goarch string) string {
+	replacements := map[string]string{
+		"darwin":  "Darwin",
+		"linux":   "Linux",
+		"windows": "Windows",
+		"386":     "i386",
+		"amd64":   "x86_64",
+	}
+
+	if r, ok := replacements[goos]; ok {
+		goos = r
+	}
+
+	if r, ok := replacements[goarch]; ok {
+		goarch = r
+	}
+
+	return fmt.Sprintf("dblfinder_%s_%s_%s.tar.gz", v, goos, goarch)
+}
+
+// newerVersion returns true if version a is newer than version b (both in major.minor.patch format)
+func newerVersion(a, b string) bool {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+
+		if na != nb {
+			return na > nb
+		}
+	}
+
+	return false
+}
+
+// verifyChecksum checks the sha256 sum of an archive against a goreleaser checksums file
+func verifyChecksum(archive []byte, archiveName string, checksums []byte) error {
+	sum := sha256.Sum256(archive)
+
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[1] != archiveName {
+			continue
+		}
+
+		if fields[0] != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("checksum mismatch for %s", archiveName)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("no checksum found for %s", archiveName)
+}
+
+// extractBinary returns the content of the dblfinder binary stored in a release archive
+func extractBinary(archive []byte, archiveName string) ([]byte, error) {
+	binName := "dblfinder"
+	if runtime.GOOS == "windows" {
+		binName += ".exe"
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if filepath.Base(hdr.Name) == binName && hdr.Typeflag == tar.TypeReg {
+			return ioutil.ReadAll(tr)
+		}
+	}
+
+	return nil, fmt.Errorf("binary not found in %s", archiveName)
+}
+
+// replaceExecutable atomically swaps the running binary with a new one
+func replaceExecutable(bin []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(exe), ".dblfinder-update-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	// a running executable can't be overwritten on Windows, but it can be renamed
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), exe)
+}
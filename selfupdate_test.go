@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_newerVersion(t *testing.T) {
+	type args struct {
+		a string
+		b string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			"same",
+			args{
+				"0.5.2",
+				"0.5.2",
+			},
+			false,
+		},
+		{
+			"newer-patch",
+			args{
+				"0.5.10",
+				"0.5.2",
+			},
+			true,
+		},
+		{
+			"older-minor",
+			args{
+				"0.4.9",
+				"0.5.2",
+			},
+			false,
+		},
+		{
+			"newer-major",
+			args{
+				"1.0",
+				"0.5.2",
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newerVersion(tt.args.a, tt.args.b); got != tt.want {
+				t.Errorf("newerVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_releaseArchiveName(t *testing.T) {
+	type args struct {
+		goos   string
+		goarch string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"linux-amd64",
+			args{
+				"linux",
+				"amd64",
+			},
+			"dblfinder_1.0.0_Linux_x86_64.tar.gz",
+		},
+		{
+			"darwin-arm64",
+			args{
+				"darwin",
+				"arm64",
+			},
+			"dblfinder_1.0.0_Darwin_arm64.tar.gz",
+		},
+		{
+			"windows-386",
+			args{
+				"windows",
+				"386",
+			},
+			"dblfinder_1.0.0_Windows_i386.tar.gz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releaseArchiveName("1.0.0", tt.args.goos, tt.args.goarch); got != tt.want {
+				t.Errorf("releaseArchiveName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultListenAddr is the address the web dashboard listens on, only reachable from the machine itself by default
+const defaultListenAddr = "127.0.0.1:8080"
+
+// dashboard serves the duplicate groups found by a scan as a web page, where they can be selected and acted on
+// Groups are updated as they are acted on, the files acted on are no longer listed. The generation counts the updates,
+// so that selections made on a page showing older groups are refused.
+type dashboard struct {
+	mu         sync.Mutex
+	groups     []reportGroup
+	thumbs     []template.URL
+	opts       options
+	message    string
+	generation int
+	public     bool
+}
+
+// runServe implements the serve command, which scans the roots and serves a web dashboard of the duplicates found
+func runServe(args []string) error {
+	var (
+		addr, actionName, target string
+		hashName, auditLog       string
+		thumbCacheDir            string
+		dryRun, gitignore        bool
+		exclude, excludeFrom     listFlag
+		protect                  listFlag
+		settle                   time.Duration
+	)
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&addr, "listen", defaultListenAddr, "address to serve the dashboard on, use :8080 to make it reachable from other machines")
+	fs.StringVar(&actionName, "action", string(trashAction), "action applied to the files selected ("+strings.Join(actionNames(), ", ")+")")
+	fs.StringVar(&target, "target", "", "quarantine directory used by the move action")
+	fs.BoolVar(&dryRun, "dry-run", false, "only report what would be done with the files selected")
+	fs.StringVar(&auditLog, "audit-log", "", "append the decisions acted on to this file as JSON lines")
+	fs.BoolVar(&gitignore, "respect-gitignore", false, "skip files ignored by .gitignore files and .git directories")
+	fs.Var(&exclude, "exclude", "glob (or regexp prefixed with re:) of files and directories to ignore, can be repeated")
+	fs.Var(&excludeFrom, "exclude-from", "file of exclude patterns, one per line, can be repeated")
+	fs.StringVar(&hashName, "hash", defaultHash, "hash algorithm used to compare files")
+	fs.Var(&protect, "protect", "glob (or regexp prefixed with re:) of files which are never acted on, even if selected, can be repeated")
+	fs.DurationVar(&settle, "settle", 0, "never act on files modified within this duration (eg. 10m), even if selected")
+	fs.StringVar(&thumbCacheDir, "thumb-cache", defaultThumbCache(), "directory caching the thumbnails of images and videos, empty disables thumbnails")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder serve [-listen <addr>] [-action <s>] [-exclude <s>]... <root>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	a, err := parseAction(actionName)
+	if err != nil {
+		return err
+	}
+
+	newHash, ok := hashers[hashName]
+	if !ok {
+		return unknownValue("hash algorithm", hashName, hasherNames())
+	}
+
+	for _, file := range excludeFrom {
+		patterns, err := readPatternFile(file)
+		if err != nil {
+			return fmt.Errorf("can't read exclude file: %v", err)
+		}
+
+		exclude = append(exclude, patterns...)
+	}
+
+	filter, err := newWalkFilter("", "", "", nil, exclude)
+	if err != nil {
+		return err
+	}
+	filter.gitignore = gitignore
+
+	protectPatterns, err := parsePathPatterns(protect)
+	if err != nil {
+		return err
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	opts := options{
+		action:       a,
+		roots:        roots,
+		target:       target,
+		dryRun:       dryRun,
+		auditLog:     auditLog,
+		hashName:     hashName,
+		marksFile:    defaultMarksFile(),
+		trashBackend: trashAuto,
+		thumbs:       thumbCache{thumbCacheDir, 100 << 20},
+		protect:      protectPatterns,
+		settle:       settle,
+	}
+
+	switch {
+	case a == listAction:
+		return fmt.Errorf("-action %s doesn't act on the files selected", listAction)
+	case a == moveAction && target == "":
+		return fmt.Errorf("-action %s requires a -target directory", moveAction)
+	case a == dedupeAction && !dedupeSupported:
+		return fmt.Errorf("-action %s is only supported on Linux", dedupeAction)
+	}
+
+	groups, err := findDuplicateGroups(roots, filter, newHash)
+	if err != nil {
+		return err
+	}
+
+	d := newDashboard(ownedGroups(groups), opts)
+	if host, _, err := net.SplitHostPort(addr); err != nil || !loopbackHost(host) {
+		d.public = true
+	}
+
+	fmt.Printf("Serving %d duplicate group(s) on http://%s/\n", len(d.groups), addr)
+
+	return http.ListenAndServe(addr, d.handler())
+}
+
+// ownedGroups returns the groups narrowed down to the files owned by the invoking user, as other users' files are
+// never acted on by the dashboard
+func ownedGroups(groups []reportGroup) []reportGroup {
+	var res []reportGroup
+	for _, g := range groups {
+		var files []reportFile
+		for _, f := range g.Files {
+			if uid, ok := fileOwner(f.Path); ok && uid != os.Getuid() {
+				continue
+			}
+
+			files = append(files, f)
+		}
+
+		if len(files) > 1 {
+			g.Files = files
+			g.Reclaimable = g.Size * int64(len(files)-1)
+			res = append(res, g)
+		}
+	}
+
+	return res
+}
+
+// newDashboard creates a dashboard of the groups, with their thumbnails made upfront
+func newDashboard(groups []reportGroup, opts options) *dashboard {
+	return &dashboard{groups: groups, thumbs: groupThumbnails(groups, opts.thumbs), opts: opts}
+}
+
+// handler returns the routes of the dashboard
+func (d *dashboard) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.index)
+	mux.HandleFunc("/preview", d.preview)
+	mux.HandleFunc("/apply", d.apply)
+	mux.HandleFunc("/metrics", metricsHandler)
+
+	return d.checkHost(mux)
+}
+
+// checkHost refuses requests addressed to other hosts than the machine itself, or one of its addresses when the
+// dashboard is reachable from other machines, so that other sites can't reach it by rebinding their names
+func (d *dashboard) checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+
+		if !loopbackHost(host) && (!d.public || net.ParseIP(host) == nil) {
+			http.Error(w, "unknown host refused", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loopbackHost returns true if a host name or address only refers to the machine itself
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// index renders the groups with a checkbox per file
+func (d *dashboard) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var reclaimable int64
+	for _, g := range d.groups {
+		reclaimable += g.Reclaimable
+	}
+
+	data := struct {
+		Groups      []reportGroup
+		Thumbs      []template.URL
+		Reclaimable int64
+		Action      string
+		DryRun      bool
+		Message     string
+		Generation  int
+	}{d.groups, d.thumbs, reclaimable, describeAction(d.opts), d.opts.dryRun, d.message, d.generation}
+
+	d.message = ""
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// groupOf returns the index of the group of each file listed, so that only files of the groups found can be requested
+func (d *dashboard) groupOf() map[string]int {
+	res := map[string]int{}
+	for i, g := range d.groups {
+		for _, f := range g.Files {
+			res[f.Path] = i
+		}
+	}
+
+	return res
+}
+
+// preview serves the content of a file of a group, for the browser to show it
+func (d *dashboard) preview(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+
+	d.mu.Lock()
+	_, ok := d.groupOf()[path]
+	d.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, path)
+}
+
+// apply acts on the files selected, given by their paths, at least one file of each group is kept
+// Protected files, files not settled yet and ones which changed since the scan are left alone.
+func (d *dashboard) apply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// forms posted by other sites could act on files otherwise, browsers send the origin of forms posted
+	if r.Header.Get("Origin") != "http://"+r.Host {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if r.PostForm.Get("generation") != strconv.Itoa(d.generation) {
+		http.Error(w, "the groups changed since the page was loaded, reload it", http.StatusConflict)
+		return
+	}
+
+	groupOf := d.groupOf()
+
+	selected := map[int]map[string]bool{}
+	for _, path := range r.PostForm["delete"] {
+		i, ok := groupOf[path]
+		if !ok {
+			continue
+		}
+
+		if selected[i] == nil {
+			selected[i] = map[string]bool{}
+		}
+		selected[i][path] = true
+	}
+
+	decided, skipped := d.decide(selected)
+
+	// the scan may be older than the files, those no longer identical to the file kept are left alone
+	var (
+		decisions []decision
+		left      int
+	)
+	for _, dec := range decided {
+		files := withoutProtected(dec.files, d.opts.protect)
+		if d.opts.settle > 0 {
+			files = settledFiles(files, d.opts.settle, time.Now())
+		}
+
+		files = verifyDeleteFiles(dec.keep, files)
+		left += len(dec.files) - len(files)
+		if len(files) > 0 {
+			decisions = append(decisions, decision{dec.keep, files, dec.reason})
+		}
+	}
+
+	act(decisions, d.opts)
+	d.remove(decisions)
+
+	var files int
+	for _, dec := range decisions {
+		files += len(dec.files)
+	}
+
+	d.message = fmt.Sprintf("%d file(s) of %d group(s) %s.", files, len(decisions), describeAction(d.opts))
+	if d.opts.dryRun {
+		d.message = fmt.Sprintf("%d file(s) of %d group(s) would have been %s (dry run).", files, len(decisions), describeAction(d.opts))
+	}
+	if skipped > 0 {
+		d.message += fmt.Sprintf(" %d group(s) skipped as all of their files were selected.", skipped)
+	}
+	if left > 0 {
+		d.message += fmt.Sprintf(" %d file(s) left alone as they are protected, not settled or changed since the scan.", left)
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// decide turns the files selected per group into decisions, groups with all of their files selected are skipped
+func (d *dashboard) decide(selected map[int]map[string]bool) ([]decision, int) {
+	var (
+		decisions []decision
+		skipped   int
+	)
+
+	for i, g := range d.groups {
+		if len(selected[i]) == 0 {
+			continue
+		}
+
+		if len(selected[i]) == len(g.Files) {
+			skipped++
+			continue
+		}
+
+		var files []string
+		for _, f := range g.Files {
+			files = append(files, f.Path)
+		}
+
+		var deleteFiles []string
+		for _, file := range files {
+			if selected[i][file] {
+				deleteFiles = append(deleteFiles, file)
+			}
+		}
+
+		decisions = append(decisions, decision{keptFile(files, deleteFiles), deleteFiles, manualReason})
+	}
+
+	return decisions, skipped
+}
+
+// remove drops the files acted on from the groups, and the groups left with a single file, unless in dry run mode
+func (d *dashboard) remove(decisions []decision) {
+	if d.opts.dryRun {
+		return
+	}
+
+	acted := map[string]bool{}
+	for _, dec := range decisions {
+		for _, file := range dec.files {
+			acted[file] = true
+		}
+	}
+
+	var (
+		groups []reportGroup
+		thumbs []template.URL
+	)
+
+	for i, g := range d.groups {
+		var files []reportFile
+		for _, f := range g.Files {
+			if !acted[f.Path] {
+				files = append(files, f)
+			}
+		}
+
+		if len(files) < 2 {
+			continue
+		}
+
+		g.Files = files
+		g.Reclaimable = g.Size * int64(len(files)-1)
+		groups = append(groups, g)
+
+		if i < len(d.thumbs) {
+			thumbs = append(thumbs, d.thumbs[i])
+		}
+	}
+
+	d.groups, d.thumbs = groups, thumbs
+	d.generation++
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"inc":  func(i int) int { return i + 1 },
+	"size": humanSize,
+	"thumb": func(thumbs []template.URL, i int) template.URL {
+		if i < len(thumbs) {
+			return thumbs[i]
+		}
+
+		return ""
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Dblfinder</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.group { border: 1px solid #ccc; padding: 0.5em 1em; margin-bottom: 1em; }
+.group h3 { margin: 0.3em 0; }
+.message { background: #efe; border: 1px solid #9c9; padding: 0.5em 1em; }
+img.thumb { float: right; }
+label { display: block; padding: 0.1em 0; }
+.bar { position: sticky; top: 0; background: #fff; padding: 0.5em 0; border-bottom: 1px solid #ccc; margin-bottom: 1em; }
+</style>
+</head>
+<body>
+<h1>Duplicates</h1>
+{{with .Message}}<p class="message">{{.}}</p>{{end}}
+<p>{{len .Groups}} group(s), {{size .Reclaimable}} reclaimable. Selected files will be {{.Action}}{{if .DryRun}} (dry run){{end}}, at least one file of each group is kept.</p>
+<form method="post" action="/apply">
+<input type="hidden" name="generation" value="{{.Generation}}">
+<div class="bar">
+<button type="button" id="select-copies">Select all but the first file of each group</button>
+<button type="button" id="select-none">Select none</button>
+<button type="submit">Apply</button>
+</div>
+{{range $i, $g := .Groups}}<div class="group">
+{{with thumb $.Thumbs $i}}<img class="thumb" src="{{.}}" alt="">{{end}}
+<h3>Group {{inc $i}}: {{len $g.Files}} files of {{size $g.Size}}, {{size $g.Reclaimable}} reclaimable</h3>
+{{range $j, $f := $g.Files}}<label><input type="checkbox" name="delete" value="{{$f.Path}}" data-index="{{$j}}"> {{$f.Path}} <small>{{$f.ModTime.Format "2006-01-02 15:04"}}</small> <a href="/preview?path={{$f.Path}}" target="_blank">preview</a></label>
+{{end}}</div>
+{{end}}</form>
+<script>
+function select(copies) {
+  document.querySelectorAll("input[name=delete]").forEach(function (box) {
+    box.checked = copies && box.dataset.index !== "0";
+  });
+}
+document.getElementById("select-copies").addEventListener("click", function () { select(true); });
+document.getElementById("select-none").addEventListener("click", function () { select(false); });
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_dashboard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c, p := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c"), filepath.Join(dir, "p")
+	e, f := filepath.Join(dir, "e"), filepath.Join(dir, "f")
+	for _, path := range []string{a, b, c, p, e, f} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups := newReportGroups([][]string{{a, b, c, p}, {e, f}}, map[string]string{a: "\x01", e: "\x02"})
+	d := newDashboard(groups, options{action: linkAction, roots: []string{dir}, protect: []pathPattern{{glob: "p"}}})
+	srv := httptest.NewServer(d.handler())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(page), "Group 1: 4 files of 4 B") || !strings.Contains(string(page), `value="`+c+`"`) || !strings.Contains(string(page), `name="generation" value="0"`) {
+		t.Errorf("index = %s", page)
+	}
+
+	res, err = http.Get(srv.URL + "/preview?path=" + url.QueryEscape(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(content) != "abcd" {
+		t.Errorf("preview = %q, want the content of the file", content)
+	}
+
+	if res, err = http.Get(srv.URL + "/preview?path=" + url.QueryEscape(filepath.Join(dir, "x"))); err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("preview of an unknown file = %v, %v, want 404", res.StatusCode, err)
+	}
+
+	post := func(host, origin string, form url.Values) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/apply", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if host != "" {
+			req.Host = host
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res
+	}
+
+	origin := srv.URL
+	selection := url.Values{"generation": {"0"}, "delete": {b, p, filepath.Join(dir, "x"), f}}
+
+	refused := []struct {
+		name, host, origin string
+		form               url.Values
+		want               int
+	}{
+		{"cross-origin", "", "http://evil.example", selection, http.StatusForbidden},
+		{"no-origin", "", "", selection, http.StatusForbidden},
+		{"rebound-host", "evil.example", "http://evil.example", selection, http.StatusForbidden},
+		{"stale-page", "", origin, url.Values{"generation": {"1"}, "delete": {b}}, http.StatusConflict},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := post(tt.host, tt.origin, tt.form); res.StatusCode != tt.want {
+				t.Errorf("apply = %d, want %d", res.StatusCode, tt.want)
+			}
+		})
+	}
+
+	if err := ioutil.WriteFile(f, []byte("efgh"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res = post("", origin, selection)
+	page, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(page), "1 file(s) of 1 group(s) replaced by hard links. 2 file(s) left alone as they are protected, not settled or changed since the scan.") || strings.Contains(string(page), `value="`+b+`"`) {
+		t.Errorf("apply = %s", page)
+	}
+
+	if linked, err := sameFile(a, b); err != nil || !linked {
+		t.Errorf("apply didn't link %s to %s: %v", b, a, err)
+	}
+
+	for _, file := range []string{p, f} {
+		if linked, err := sameFile(a, file); err != nil || linked {
+			t.Errorf("apply linked %s, which is protected or changed since the scan: %v", file, err)
+		}
+	}
+
+	if res := post("", origin, selection); res.StatusCode != http.StatusConflict {
+		t.Errorf("apply of the page before the groups changed = %d, want 409", res.StatusCode)
+	}
+}
+
+func Test_loopbackHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"localhost", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"192.168.1.2", false},
+		{"", false},
+		{"evil.example", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := loopbackHost(tt.host); got != tt.want {
+				t.Errorf("loopbackHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
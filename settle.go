@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// settledFiles returns the files which were not modified within the settle window
+// Recently modified files may still be written by another process, so they are only reported.
+func settledFiles(files []string, settle time.Duration, now time.Time) []string {
+	var res []string
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			fmt.Printf("Skipping: %s, err %v\n", file, err)
+			continue
+		}
+
+		if age := now.Sub(fi.ModTime()); age < settle {
+			fmt.Printf("Not settled: %s (modified %s ago)\n", file, age.Round(time.Second))
+			continue
+		}
+
+		res = append(res, file)
+	}
+
+	return res
+}
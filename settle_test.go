@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_settledFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder-settle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	old, fresh := filepath.Join(dir, "old"), filepath.Join(dir, "fresh")
+	for path, mtime := range map[string]time.Time{old: now.Add(-time.Hour), fresh: now.Add(-time.Minute)} {
+		if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := []string{old, fresh, filepath.Join(dir, "missing")}
+
+	tests := []struct {
+		name   string
+		settle time.Duration
+		want   []string
+	}{
+		{"short-window", time.Second, []string{old, fresh}},
+		{"long-window", 10 * time.Minute, []string{old}},
+		{"longer-than-all", 2 * time.Hour, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := settledFiles(files, tt.settle, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("settledFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
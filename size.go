@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the unit suffixes accepted by parseSize to their multipliers, units are powers of 1024
+var sizeUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	{"t", 1 << 40},
+	{"g", 1 << 30},
+	{"m", 1 << 20},
+	{"k", 1 << 10},
+	{"", 1},
+}
+
+// parseSize parses a size in bytes, which may be given with a unit (eg. 50M, 1.5GB, 10MiB or 4096)
+func parseSize(s string) (int64, error) {
+	num := strings.ToLower(strings.TrimSpace(s))
+	num = strings.TrimSuffix(strings.TrimSuffix(num, "ib"), "b")
+
+	for _, u := range sizeUnits {
+		if u.suffix != "" && !strings.HasSuffix(num, u.suffix) {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), 64)
+		if err != nil || f < 0 {
+			return 0, fmt.Errorf("invalid size: %s", s)
+		}
+
+		return int64(f * float64(u.mult)), nil
+	}
+
+	return 0, fmt.Errorf("invalid size: %s", s)
+}
+
+// exactSizes is set by -bytes, sizes are then listed in bytes instead of human readable units
+var exactSizes bool
+
+// formatSize formats a size in bytes with the largest binary unit it reaches, eg. 1.4 GiB or 230 MiB
+// Sizes of less than 100 units are shown with a decimal, larger ones are rounded to whole units.
+func formatSize(n int64) string {
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+
+	if n < 1024 && n > -1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	v, unit := float64(n)/1024, units[0]
+	for _, u := range units[1:] {
+		if v < 1024 && v > -1024 {
+			break
+		}
+
+		v, unit = v/1024, u
+	}
+
+	if v >= 100 || v <= -100 {
+		return fmt.Sprintf("%.0f %s", v, unit)
+	}
+
+	return fmt.Sprintf("%.1f %s", v, unit)
+}
+
+// humanSize formats a size for listings, in bytes with -bytes, with formatSize otherwise
+func humanSize(n int64) string {
+	if exactSizes {
+		return fmt.Sprintf("%d bytes", n)
+	}
+
+	return formatSize(n)
+}
+
+// sizeFlag is a flag holding a size in bytes, given with an optional unit as accepted by parseSize
+type sizeFlag int64
+
+// String returns the size in bytes
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+// Set parses a size
+func (s *sizeFlag) Set(value string) error {
+	n, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	*s = sizeFlag(n)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_parseSize(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{"4096", 4096, false},
+		{"12B", 12, false},
+		{"50M", 50 << 20, false},
+		{"50mb", 50 << 20, false},
+		{"10MiB", 10 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{"2 K", 2048, false},
+		{"1T", 1 << 40, false},
+		{"", 0, true},
+		{"M", 0, true},
+		{"-5M", 0, true},
+		{"5X", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseSize(tt.s)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("parseSize() = %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_formatSize(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+		want string
+	}{
+		{"bytes", 512, "512 B"},
+		{"kib", 1536, "1.5 KiB"},
+		{"mib-rounded", 230 << 20, "230 MiB"},
+		{"gib", 1503238553, "1.4 GiB"},
+		{"tib", 3 << 40, "3.0 TiB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatSize(tt.size); got != tt.want {
+				t.Errorf("formatSize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
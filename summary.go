@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/peteraba/dblfinder/model"
+)
+
+// wasteStats is the space taken by duplicates within a part of the scanned files, like a root or an extension
+type wasteStats = model.WasteStats
+
+// summarize sums up the files scanned and hashed, the duplicates found and the space freed by the decisions applied
+// Just like for reclaimable bytes, the first file of each group is considered the original, the rest as duplicates.
+func summarize(groups []reportGroup, decisions []decision, opts options) reportSummary {
+	s := reportSummary{
+		FilesScanned: stats.stage(walkStage).files,
+		BytesHashed:  stats.stage(sampleStage).bytes,
+		ByRoot:       map[string]wasteStats{},
+		ByExtension:  map[string]wasteStats{},
+	}
+
+	reclaimed := map[string]bool{}
+	for _, d := range decisions {
+		for _, file := range reclaimedFiles(d, opts) {
+			reclaimed[file] = true
+		}
+	}
+
+	for _, g := range groups {
+		s.Groups++
+		s.Files += len(g.Files)
+		s.Duplicates += len(g.Files) - 1
+		s.Reclaimable += g.Reclaimable
+
+		for i, f := range g.Files {
+			root := summaryRoot(f.Path, opts.roots)
+			ext := extension(f.Path)
+
+			byRoot, byExt := s.ByRoot[root], s.ByExtension[ext]
+
+			if i > 0 {
+				byRoot.Duplicates++
+				byRoot.Reclaimable += f.Size
+				byExt.Duplicates++
+				byExt.Reclaimable += f.Size
+			}
+
+			if reclaimed[f.Path] {
+				s.Reclaimed += f.Size
+				byRoot.Reclaimed += f.Size
+				byExt.Reclaimed += f.Size
+			}
+
+			s.ByRoot[root], s.ByExtension[ext] = byRoot, byExt
+		}
+	}
+
+	return s
+}
+
+// summaryRoot returns the root a file was found under
+func summaryRoot(file string, roots []string) string {
+	i, err := rootIndex(file, roots)
+	if err != nil || i >= len(roots) {
+		return ""
+	}
+
+	return roots[i]
+}
+
+// reclaimedFiles returns the files of a decision whose space was actually freed
+// Nothing is freed in dry runs and by marking. Files deleted, trashed or moved are freed if they are gone, hard links
+// if they now share the kept file's data. Clones can't be told apart from copies, they are trusted to be freed.
+func reclaimedFiles(d decision, opts options) []string {
+	if opts.dryRun || opts.action == markAction || opts.action == listAction {
+		return nil
+	}
+
+	var res []string
+	for _, file := range d.files {
+		switch opts.action {
+		case linkAction:
+			kept, err := os.Stat(d.keep)
+			if err != nil {
+				continue
+			}
+
+			if fi, err := os.Stat(file); err == nil && os.SameFile(kept, fi) {
+				res = append(res, file)
+			}
+		case reflinkAction, dedupeAction:
+			res = append(res, file)
+		default:
+			if _, err := os.Lstat(file); os.IsNotExist(err) {
+				res = append(res, file)
+			}
+		}
+	}
+
+	return res
+}
+
+// printSummary prints the totals of a run, broken down by root and by extension
+func printSummary(s reportSummary) {
+	fmt.Println("Summary:")
+	fmt.Printf("  %d file(s) scanned, %s hashed\n", s.FilesScanned, humanSize(s.BytesHashed))
+	fmt.Printf("  %d duplicate group(s), %d duplicate file(s)\n", s.Groups, s.Duplicates)
+	fmt.Printf("  %s reclaimable, %s reclaimed\n", humanSize(s.Reclaimable), humanSize(s.Reclaimed))
+
+	if s.Groups == 0 {
+		return
+	}
+
+	printWaste("root", s.ByRoot)
+	printWaste("extension", s.ByExtension)
+}
+
+// printWaste prints the space taken by duplicates per root or per extension, the most wasteful first
+func printWaste(kind string, waste map[string]wasteStats) {
+	var keys []string
+	for key := range waste {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if waste[keys[i]].Reclaimable != waste[keys[j]].Reclaimable {
+			return waste[keys[i]].Reclaimable > waste[keys[j]].Reclaimable
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	fmt.Printf("  per %s:\n", kind)
+	for _, key := range keys {
+		name := key
+		if name == "" {
+			name = "(none)"
+		}
+
+		w := waste[key]
+		fmt.Printf("    %s: %d duplicate(s), %s reclaimable, %s reclaimed\n", name, w.Duplicates, humanSize(w.Reclaimable), humanSize(w.Reclaimed))
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_reclaimedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keep, kept, gone := filepath.Join(dir, "keep"), filepath.Join(dir, "kept"), filepath.Join(dir, "gone")
+	for _, path := range []string{keep, kept} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := decision{keep, []string{kept, gone}, manualReason}
+
+	tests := []struct {
+		name string
+		opts options
+		want []string
+	}{
+		{"deleted", options{action: trashAction}, []string{gone}},
+		{"dry-run", options{action: trashAction, dryRun: true}, nil},
+		{"marked", options{action: markAction}, nil},
+		{"not-linked", options{action: linkAction}, nil},
+		{"cloned", options{action: reflinkAction}, []string{kept, gone}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reclaimedFiles(d, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reclaimedFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
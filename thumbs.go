@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	// decoders of the image formats thumbnails are made of
+	_ "image/gif"
+	_ "image/png"
+)
+
+// thumbSize is the maximum width and height of thumbnails in pixels
+const thumbSize = 160
+
+// errNoThumbnail is returned for files which no thumbnail can be made of
+var errNoThumbnail = errors.New("no thumbnail available")
+
+// thumbCache stores thumbnails of images and videos in a directory, keyed by the hash and size of their content
+// Duplicates share their content, so a single thumbnail serves a whole group, and regenerating reports of the same
+// files only reads the cache. Once the thumbnails take more than limit bytes, the least recently used ones are removed.
+type thumbCache struct {
+	dir   string
+	limit int64
+}
+
+// defaultThumbCache returns the location of the thumbnail cache in the user's cache directory
+func defaultThumbCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "dblfinder", "thumbs")
+}
+
+// enabled returns true if thumbnails are to be made and cached
+func (c thumbCache) enabled() bool {
+	return c.dir != ""
+}
+
+// thumbnail returns the path of the cached thumbnail of a file with the given content key, making it if needed
+// Images are scaled down in process, videos by ffmpeg if it's available. errNoThumbnail is returned for other files.
+func (c thumbCache) thumbnail(file, key string) (string, error) {
+	path := filepath.Join(c.dir, key+".jpg")
+
+	if _, err := os.Stat(path); err == nil {
+		// used thumbnails are touched, so that they are evicted last
+		now := time.Now()
+		os.Chtimes(path, now, now)
+
+		return path, nil
+	}
+
+	mime, err := sniffMime(file)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", err
+	}
+
+	tmp := path + ".tmp"
+
+	switch {
+	case mime == "image/jpeg" || mime == "image/png" || mime == "image/gif":
+		err = writeImageThumbnail(file, tmp)
+	case strings.HasPrefix(mime, "video/"):
+		err = writeVideoThumbnail(file, tmp)
+	default:
+		return "", errNoThumbnail
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// writeImageThumbnail scales an image down to fit thumbSize and writes it as a JPEG file
+func writeImageThumbnail(file, thumb string) error {
+	f, err := fds.openFile(sniffStage, file)
+	if err != nil {
+		return err
+	}
+	defer fds.closeFile(sniffStage, f)
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(thumb)
+	if err != nil {
+		return err
+	}
+
+	if err := jpeg.Encode(out, scaleDown(img, thumbSize), &jpeg.Options{Quality: 80}); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// scaleDown returns an image fitting into a max × max square, keeping its aspect ratio
+// Nearest neighbour sampling is good enough to recognize pictures at thumbnail size.
+func scaleDown(img image.Image, max int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	if w <= max && h <= max {
+		res := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(res, res.Bounds(), img, b.Min, draw.Src)
+
+		return res
+	}
+
+	tw, th := max, h*max/w
+	if h > w {
+		tw, th = w*max/h, max
+	}
+
+	if tw < 1 {
+		tw = 1
+	}
+
+	if th < 1 {
+		th = 1
+	}
+
+	res := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			res.Set(x, y, img.At(b.Min.X+x*w/tw, b.Min.Y+y*h/th))
+		}
+	}
+
+	return res
+}
+
+// writeVideoThumbnail writes a representative frame of a video as a JPEG file, using ffmpeg
+func writeVideoThumbnail(file, thumb string) error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return errNoThumbnail
+	}
+
+	scale := fmt.Sprintf("thumbnail,scale=%d:%d:force_original_aspect_ratio=decrease", thumbSize, thumbSize)
+
+	out, err := exec.Command("ffmpeg", "-v", "error", "-y", "-i", file, "-vf", scale, "-frames:v", "1", "-f", "image2", thumb).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// prune removes the least recently used thumbnails until the cache fits into its limit
+func (c thumbCache) prune() error {
+	infos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	var total int64
+	for _, fi := range infos {
+		total += fi.Size()
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+
+	for _, fi := range infos {
+		if total <= c.limit {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(c.dir, fi.Name())); err != nil {
+			return err
+		}
+
+		total -= fi.Size()
+	}
+
+	return nil
+}
+
+// thumbKey returns the cache key of the content of a duplicate group
+// Hashes may be calculated over samples only, the size tells apart files sharing their samples.
+func thumbKey(g reportGroup) string {
+	return fmt.Sprintf("%s-%d", g.Hash, g.Size)
+}
+
+// groupThumbnails returns the thumbnails of the duplicate groups as data URLs, in the order of the groups
+// The URL is empty for groups of files no thumbnail can be made of.
+func groupThumbnails(groups []reportGroup, c thumbCache) []template.URL {
+	if !c.enabled() {
+		return nil
+	}
+
+	res := make([]template.URL, len(groups))
+	for i, g := range groups {
+		path, err := c.thumbnail(g.Files[0].Path, thumbKey(g))
+		if err != nil {
+			if err != errNoThumbnail {
+				slog.Warn("can't make thumbnail", "path", g.Files[0].Path, "err", err)
+			}
+
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			slog.Warn("can't read thumbnail", "path", path, "err", err)
+			continue
+		}
+
+		// thumbnails are made by dblfinder itself, so they are safe to embed
+		res[i] = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data))
+	}
+
+	if err := c.prune(); err != nil {
+		slog.Warn("can't prune thumbnail cache", "err", err)
+	}
+
+	return res
+}
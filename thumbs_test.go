@@ -0,0 +1,85 @@
+package main
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_scaleDown(t *testing.T) {
+	tests := []struct {
+		name  string
+		w, h  int
+		wantW int
+		wantH int
+	}{
+		{"small", 40, 30, 40, 30},
+		{"landscape", 320, 160, 160, 80},
+		{"portrait", 100, 400, 40, 160},
+		{"thin", 1000, 2, 160, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := scaleDown(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), thumbSize).Bounds()
+			if b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Errorf("scaleDown() = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func Test_thumbCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img, text := filepath.Join(dir, "a.png"), filepath.Join(dir, "a.txt")
+
+	f, err := os.Create(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if err := ioutil.WriteFile(text, []byte("plain text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := thumbCache{filepath.Join(dir, "thumbs"), 1 << 20}
+	groups := []reportGroup{
+		{Size: 1, Hash: "01", Files: []reportFile{{Path: img}, {Path: img}}},
+		{Size: 2, Hash: "02", Files: []reportFile{{Path: text}, {Path: text}}},
+	}
+
+	thumbs := groupThumbnails(groups, c)
+	if len(thumbs) != 2 || thumbs[0] == "" || thumbs[1] != "" {
+		t.Fatalf("groupThumbnails() = %v, want a thumbnail of the first group only", thumbs)
+	}
+
+	cached := filepath.Join(c.dir, thumbKey(groups[0])+".jpg")
+	if _, err := os.Stat(cached); err != nil {
+		t.Fatalf("thumbnail not cached: %v", err)
+	}
+
+	// the cache is used even once the original is gone
+	os.Remove(img)
+	if path, err := c.thumbnail(img, thumbKey(groups[0])); err != nil || path != cached {
+		t.Errorf("thumbnail() = %v, %v, want %v", path, err, cached)
+	}
+
+	c.limit = 0
+	if err := c.prune(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cached); !os.IsNotExist(err) {
+		t.Errorf("prune() kept %s in an empty cache", cached)
+	}
+}
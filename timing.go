@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	walkStage   = "walk"
+	sampleStage = "sampling"
+	verifyStage = "verification"
+	actionStage = "actions"
+)
+
+// stageOrder is the order stages are reported in
+var stageOrder = []string{walkStage, sampleStage, verifyStage, actionStage}
+
+// stageStats holds the wall time spent in a stage, and the files and bytes processed in it
+// Counters are updated atomically, as files are processed by several goroutines in parallel.
+type stageStats struct {
+	elapsed int64
+	files   int64
+	bytes   int64
+}
+
+// runStats collects the stage statistics of a run
+type runStats struct {
+	mu     sync.Mutex
+	stages map[string]*stageStats
+}
+
+// stats holds the statistics of the current run
+var stats = newRunStats()
+
+func newRunStats() *runStats {
+	return &runStats{stages: map[string]*stageStats{}}
+}
+
+// stage returns the statistics of a stage, creating them on first use
+func (r *runStats) stage(name string) *stageStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.stages[name]
+	if !ok {
+		s = &stageStats{}
+		r.stages[name] = s
+	}
+
+	return s
+}
+
+// track starts timing a stage and returns a function stopping it, time spent in several calls adds up
+func (r *runStats) track(name string) func() {
+	s, start := r.stage(name), time.Now()
+
+	return func() {
+		atomic.AddInt64(&s.elapsed, int64(time.Since(start)))
+	}
+}
+
+// add counts files and bytes processed in a stage
+func (r *runStats) add(name string, files, bytes int64) {
+	s := r.stage(name)
+
+	atomic.AddInt64(&s.files, files)
+	atomic.AddInt64(&s.bytes, bytes)
+}
+
+// report prints the wall time and throughput of each stage which was run
+func (r *runStats) report() {
+	var lines []string
+	for _, name := range stageOrder {
+		r.mu.Lock()
+		s, ok := r.stages[name]
+		r.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		elapsed := time.Duration(atomic.LoadInt64(&s.elapsed))
+		files, bytes := atomic.LoadInt64(&s.files), atomic.LoadInt64(&s.bytes)
+
+		line := fmt.Sprintf("  %-13s %10s  %d file(s)", name, elapsed.Round(time.Millisecond), files)
+		if bytes > 0 {
+			line += fmt.Sprintf(", %d bytes", bytes)
+		}
+
+		if secs := elapsed.Seconds(); secs > 0 {
+			line += fmt.Sprintf(" (%.0f files/s", float64(files)/secs)
+			if bytes > 0 {
+				line += fmt.Sprintf(", %.1f MB/s", float64(bytes)/secs/1e6)
+			}
+			line += ")"
+		}
+
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	lines = append(lines, fmt.Sprintf("  memory        %d MB obtained from the OS", m.Sys>>20))
+
+	fmt.Println("Timing:")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func Test_runStats(t *testing.T) {
+	r := newRunStats()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			stop := r.track(sampleStage)
+			r.add(sampleStage, 1, 100)
+			stop()
+		}()
+	}
+	wg.Wait()
+
+	s := r.stage(sampleStage)
+	if s.files != 50 || s.bytes != 5000 {
+		t.Errorf("runStats counted %d files and %d bytes, want 50 and 5000", s.files, s.bytes)
+	}
+
+	if _, ok := r.stages[walkStage]; ok {
+		t.Errorf("runStats created a stage which was never used")
+	}
+}
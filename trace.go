@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+)
+
+// tracer records each file visited, each decision to skip one and each hash calculated, it's nil unless -trace-file
+// is given
+// The trace is written independently of the log level and -verbose, to find out why a file wasn't reported.
+var tracer *slog.Logger
+
+// setupTrace starts writing the trace into file, replacing the trace of an earlier run
+// The returned function flushes and closes the trace file.
+func setupTrace(file string) (func() error, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	tracer = slog.New(slog.NewTextHandler(w, nil))
+
+	return func() error {
+		if err := w.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+
+		return f.Close()
+	}, nil
+}
+
+// trace records an event of the scan, it does nothing unless a trace file is written
+func trace(msg string, args ...interface{}) {
+	if tracer == nil {
+		return
+	}
+
+	tracer.Info(msg, args...)
+}
+
+// traceSkip records that a file or directory was left out of the scan and why
+func traceSkip(path, reason string, args ...interface{}) {
+	trace("skipped", append([]interface{}{"path", path, "reason", reason}, args...)...)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_setupTrace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a", ".hidden", "empty"} {
+		content := []byte("abcd")
+		if name == "empty" {
+			content = nil
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(root, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	traceFile := filepath.Join(dir, "scan.log")
+	closeTrace, err := setupTrace(traceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { tracer = nil }()
+
+	if _, err := getAllFileSizes([]string{root}, walkFilter{skipHidden: true, skipEmpty: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := closeTrace(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(traceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"msg=visited path=" + filepath.Join(root, "a") + " size=4",
+		"msg=skipped path=" + filepath.Join(root, ".hidden") + " reason=hidden",
+		"msg=skipped path=" + filepath.Join(root, "empty") + " reason=empty",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("setupTrace() wrote %q, want a line with %q", data, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	trashAuto   = "auto"
+	trashNative = "native"
+	trashGio    = "gio"
+)
+
+// trashBackends lists the ways files can be moved to the trash
+var trashBackends = []string{trashAuto, trashNative, trashGio}
+
+// trashCommand is a desktop utility moving the files given as its last arguments to the trash
+type trashCommand struct {
+	name string
+	args []string
+}
+
+// trashCommands lists the utilities the auto backend delegates to when present, in order of preference
+// Desktop utilities handle details like per-volume trash directories and restore metadata the way the desktop expects.
+var trashCommands = []trashCommand{
+	{"gio", []string{"trash"}},
+	{"trash-put", nil},
+}
+
+// validTrashBackend returns true if backend is one of trashBackends
+func validTrashBackend(backend string) bool {
+	for _, b := range trashBackends {
+		if b == backend {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trashMover returns the function moving a file to the trash with a backend, and the name of what it uses
+// The native backend is implemented for each platform, gio delegates to the gio utility of GLib, and auto uses the
+// first utility of trashCommands available, falling back to the native implementation. On Windows, the native
+// implementation uses the API of the Recycle Bin itself, so auto never delegates.
+func trashMover(backend string) (func(path string) error, string, error) {
+	switch backend {
+	case trashNative:
+		return moveToTrash, trashNative, nil
+	case trashGio:
+		if _, err := exec.LookPath(trashCommands[0].name); err != nil {
+			return nil, "", fmt.Errorf("trash backend %s is not available: %v", trashGio, err)
+		}
+
+		return trashCommands[0].run, trashCommands[0].name, nil
+	}
+
+	if runtime.GOOS != "windows" {
+		for _, c := range trashCommands {
+			if _, err := exec.LookPath(c.name); err == nil {
+				return c.run, c.name, nil
+			}
+		}
+	}
+
+	return moveToTrash, trashNative, nil
+}
+
+// run moves a file to the trash with the utility
+func (c trashCommand) run(path string) error {
+	out, err := exec.Command(c.name, append(c.args, "--", path)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed for %s: %v %s", c.name, path, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// trashFiles moves files to the trash using a trash backend, unless dryRun is set
+func trashFiles(files []string, backend string, dryRun bool) {
+	trash, name, err := trashMover(backend)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	if name != trashNative {
+		progressf("Using %s to move files to the trash\n", name)
+	}
+
+	for _, file := range files {
+		if dryRun {
+			fmt.Printf("%s %s (skipped)\n", colorize(deleteColor, "Trashing:"), file)
+			continue
+		}
+
+		fmt.Printf("%s %s\n", colorize(deleteColor, "Trashing:"), file)
+
+		if err := trash(file); err != nil {
+			fmt.Printf("%s\n", colorize(errorColor, err.Error()))
+		} else {
+			fmt.Println("done.")
+		}
+	}
+}
+
+// uniqueTrashName returns a name for a file in a trash directory which is not taken yet
+// Names are made unique by appending a counter before the extension, e.g. "photo 2.jpg".
+func uniqueTrashName(dir, name string, taken func(path string) bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for i := 2; taken(filepath.Join(dir, candidate)); i++ {
+		candidate = base + " " + strconv.Itoa(i) + ext
+	}
+
+	return candidate
+}
+
+// mountPoint returns the top directory of the file system a path is on
+func mountPoint(path string) (string, error) {
+	dev, err := deviceID(path)
+	if err != nil {
+		return "", err
+	}
+
+	dir := path
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+
+		parentDev, err := deviceID(parent)
+		if err != nil {
+			return "", err
+		}
+
+		if parentDev != dev {
+			return dir, nil
+		}
+
+		dir = parent
+	}
+}
+
+// sameDevice returns true if path is on the same device as other, or the closest existing directory above it
+func sameDevice(path, other string) (bool, error) {
+	for !exists(other) && filepath.Dir(other) != other {
+		other = filepath.Dir(other)
+	}
+
+	a, err := deviceID(path)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := deviceID(other)
+	if err != nil {
+		return false, err
+	}
+
+	return a == b, nil
+}
+
+// exists returns true if something exists at path
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+
+	return err == nil
+}
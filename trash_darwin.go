@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// moveToTrash moves a file into the trash of the user
+// Files on other volumes than the home directory, like removable drives, are moved to the .Trashes directory of that
+// volume, so that they stay recoverable from the drive itself.
+func moveToTrash(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	trash := filepath.Join(home, ".Trash")
+
+	same, err := sameDevice(abs, home)
+	if err != nil {
+		return err
+	}
+
+	if !same {
+		volume, err := mountPoint(abs)
+		if err != nil {
+			return err
+		}
+
+		trash = filepath.Join(volume, ".Trashes", strconv.Itoa(os.Getuid()))
+		if err := os.MkdirAll(trash, 0700); err != nil {
+			return err
+		}
+	}
+
+	name := uniqueTrashName(trash, filepath.Base(abs), exists)
+
+	return os.Rename(abs, filepath.Join(trash, name))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_uniqueTrashName(t *testing.T) {
+	type args struct {
+		name  string
+		taken []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"free",
+			args{
+				"photo.jpg",
+				nil,
+			},
+			"photo.jpg",
+		},
+		{
+			"taken-twice",
+			args{
+				"photo.jpg",
+				[]string{"trash/photo.jpg", "trash/photo 2.jpg"},
+			},
+			"photo 3.jpg",
+		},
+		{
+			"no-extension",
+			args{
+				"Makefile",
+				[]string{"trash/Makefile"},
+			},
+			"Makefile 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := map[string]bool{}
+			for _, path := range tt.args.taken {
+				taken[path] = true
+			}
+
+			got := uniqueTrashName("trash", tt.args.name, func(path string) bool { return taken[path] })
+			if got != tt.want {
+				t.Errorf("uniqueTrashName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_trashCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on the true and false utilities")
+	}
+
+	if err := (trashCommand{"true", nil}).run("file"); err != nil {
+		t.Errorf("run() = %v, want no error", err)
+	}
+
+	if err := (trashCommand{"false", []string{"trash"}}).run("file"); err == nil {
+		t.Errorf("run() succeeded for a failing utility")
+	}
+}
+
+func Test_trashMover(t *testing.T) {
+	if _, name, err := trashMover(trashNative); err != nil || name != trashNative {
+		t.Errorf("trashMover(%s) = %v, %v", trashNative, name, err)
+	}
+
+	if _, name, err := trashMover(trashAuto); err != nil || name == "" {
+		t.Errorf("trashMover(%s) = %v, %v", trashAuto, name, err)
+	}
+}
+
+func Test_mountPoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "a")
+	if err := ioutil.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	top, err := mountPoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rel, err := filepath.Rel(top, file); err != nil || strings.HasPrefix(rel, "..") {
+		t.Errorf("mountPoint() = %v, want a directory above %v", top, file)
+	}
+
+	same, err := sameDevice(file, filepath.Join(dir, "missing", "trash"))
+	if err != nil || !same {
+		t.Errorf("sameDevice() = %v, %v, want true", same, err)
+	}
+}
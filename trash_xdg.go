@@ -0,0 +1,120 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// moveToTrash moves a file into the trash following the FreeDesktop.org trash specification
+// Files on other file systems than the home trash, like removable drives, are moved to the trash of that file system,
+// so that they stay recoverable from the drive itself.
+func moveToTrash(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	trash, topDir, err := trashFor(abs)
+	if err != nil {
+		return err
+	}
+
+	// files in the trash of a file system are recorded relative to its top directory
+	infoPath := abs
+	if topDir != "" {
+		if infoPath, err = filepath.Rel(topDir, abs); err != nil {
+			return err
+		}
+	}
+
+	filesDir, infoDir := filepath.Join(trash, "files"), filepath.Join(trash, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	// the info file is created exclusively first, which reserves the name in the trash
+	var (
+		name string
+		info *os.File
+	)
+
+	for info == nil {
+		name = uniqueTrashName(filesDir, filepath.Base(abs), func(p string) bool {
+			return exists(p) || exists(filepath.Join(infoDir, filepath.Base(p)+".trashinfo"))
+		})
+
+		info, err = os.OpenFile(filepath.Join(infoDir, name+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(info, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", (&url.URL{Path: filepath.ToSlash(infoPath)}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	if cerr := info.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(abs, filepath.Join(filesDir, name))
+	}
+
+	if err != nil {
+		os.Remove(info.Name())
+		return err
+	}
+
+	return nil
+}
+
+// trashFor returns the trash directory to move a file to, and the top directory of its file system if it's not the
+// home trash
+// On other file systems the shared $topdir/.Trash/$uid is used if $topdir/.Trash is a sticky directory (and not a
+// symbolic link), otherwise $topdir/.Trash-$uid.
+func trashFor(abs string) (string, string, error) {
+	home, err := homeTrash()
+	if err != nil {
+		return "", "", err
+	}
+
+	same, err := sameDevice(abs, home)
+	if err != nil || same {
+		return home, "", err
+	}
+
+	topDir, err := mountPoint(abs)
+	if err != nil {
+		return "", "", err
+	}
+
+	uid := strconv.Itoa(os.Getuid())
+
+	shared := filepath.Join(topDir, ".Trash")
+	if fi, err := os.Lstat(shared); err == nil && fi.IsDir() && fi.Mode()&os.ModeSticky != 0 {
+		return filepath.Join(shared, uid), topDir, nil
+	}
+
+	return filepath.Join(topDir, ".Trash-"+uid), topDir, nil
+}
+
+// homeTrash returns the trash directory of the user
+func homeTrash() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "Trash"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
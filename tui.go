@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences used to draw the review screen
+const (
+	tuiAltScreen  = "\x1b[?1049h"
+	tuiMainScreen = "\x1b[?1049l"
+	tuiHideCursor = "\x1b[?25l"
+	tuiShowCursor = "\x1b[?25h"
+	tuiClear      = "\x1b[H\x1b[2J"
+)
+
+// tuiHelp lists the keys of the review screen
+const tuiHelp = "↑↓ file  ←→ group  d delete  k keep  s keep only this  a keep all  u undo  enter apply  q quit"
+
+// tuiResult tells what to do after a key was handled
+type tuiResult int
+
+const (
+	tuiContinue tuiResult = iota
+	tuiApply
+	tuiQuit
+)
+
+// tuiGroup is a duplicate group under review, with the files marked for deletion
+type tuiGroup struct {
+	files     []string
+	size      int64
+	protected map[string]bool
+	marked    map[string]bool
+}
+
+// tuiChange is a change of the marks of a group, undone by restoring the marks it replaced
+type tuiChange struct {
+	group  int
+	marked map[string]bool
+}
+
+// tuiState is the state of the review screen, changed by the keys pressed
+type tuiState struct {
+	groups  []tuiGroup
+	group   int
+	file    int
+	undo    []tuiChange
+	confirm bool
+	status  string
+}
+
+// newTUIState starts reviewing groups with none of their files marked for deletion
+func newTUIState(groups [][]string, protect []pathPattern) *tuiState {
+	s := &tuiState{}
+
+	for _, files := range groups {
+		g := tuiGroup{files: files, protected: map[string]bool{}, marked: map[string]bool{}}
+
+		if fi, err := os.Stat(files[0]); err == nil {
+			g.size = fi.Size()
+		}
+
+		for _, file := range files {
+			if matchAny(protect, file) {
+				g.protected[file] = true
+			}
+		}
+
+		s.groups = append(s.groups, g)
+	}
+
+	return s
+}
+
+// update handles a key pressed
+func (s *tuiState) update(key string) tuiResult {
+	s.status = ""
+
+	if s.confirm {
+		switch key {
+		case "y", "enter":
+			return tuiApply
+		case "n", "esc", "q":
+			s.confirm = false
+		}
+
+		return tuiContinue
+	}
+
+	g := &s.groups[s.group]
+	file := g.files[s.file]
+
+	switch key {
+	case "up":
+		if s.file > 0 {
+			s.file--
+		}
+	case "down":
+		if s.file < len(g.files)-1 {
+			s.file++
+		}
+	case "left", "p":
+		if s.group > 0 {
+			s.group, s.file = s.group-1, 0
+		}
+	case "right", "n":
+		if s.group < len(s.groups)-1 {
+			s.group, s.file = s.group+1, 0
+		}
+	case "d":
+		switch {
+		case g.protected[file]:
+			s.status = "protected files are never deleted"
+		case len(g.marked) == len(g.files)-1 && !g.marked[file]:
+			s.status = "at least one file of a group is kept"
+		default:
+			s.change(func(marked map[string]bool) { marked[file] = true })
+		}
+	case "k":
+		s.change(func(marked map[string]bool) { delete(marked, file) })
+	case "s":
+		s.change(func(marked map[string]bool) {
+			for _, f := range g.files {
+				if f != file && !g.protected[f] {
+					marked[f] = true
+				}
+			}
+			delete(marked, file)
+		})
+	case "a":
+		s.change(func(marked map[string]bool) {
+			for f := range marked {
+				delete(marked, f)
+			}
+		})
+	case "u":
+		if len(s.undo) == 0 {
+			s.status = "nothing to undo"
+			break
+		}
+
+		c := s.undo[len(s.undo)-1]
+		s.undo = s.undo[:len(s.undo)-1]
+		s.groups[c.group].marked = c.marked
+		s.group, s.file = c.group, 0
+	case "enter":
+		s.confirm = true
+	case "q", "ctrl-c":
+		return tuiQuit
+	}
+
+	return tuiContinue
+}
+
+// change changes the marks of the current group, recording the marks replaced so that the change can be undone
+func (s *tuiState) change(f func(map[string]bool)) {
+	g := &s.groups[s.group]
+
+	before := map[string]bool{}
+	for file := range g.marked {
+		before[file] = true
+	}
+
+	f(g.marked)
+
+	s.undo = append(s.undo, tuiChange{s.group, before})
+}
+
+// decisions returns the groups with files marked for deletion as decisions, in the order of the groups
+func (s *tuiState) decisions() []decision {
+	var res []decision
+
+	for _, g := range s.groups {
+		var deleteFiles []string
+		for _, file := range g.files {
+			if g.marked[file] {
+				deleteFiles = append(deleteFiles, file)
+			}
+		}
+
+		if len(deleteFiles) == 0 {
+			continue
+		}
+
+		res = append(res, decision{keptFile(g.files, deleteFiles), deleteFiles, manualReason})
+	}
+
+	return res
+}
+
+// render draws the review screen, the list of groups on the left and the files of the current group on the right,
+// or the apply screen once the review is done
+func (s *tuiState) render(width, height int, opts options) []string {
+	if s.confirm {
+		return s.renderApply(height, opts)
+	}
+
+	body := height - 3
+	if body < 1 {
+		body = 1
+	}
+
+	leftWidth := width / 3
+	rightWidth := width - leftWidth - 3
+
+	var left []string
+	from, to := window(len(s.groups), s.group, body)
+	for i := from; i < to; i++ {
+		g := s.groups[i]
+
+		cursor := " "
+		if i == s.group {
+			cursor = ">"
+		}
+
+		line := fmt.Sprintf("%s %d. %d × %s", cursor, i+1, len(g.files), humanSize(g.size))
+		if len(g.marked) > 0 {
+			line += fmt.Sprintf(", %d to delete", len(g.marked))
+		}
+
+		left = append(left, line)
+	}
+
+	g := s.groups[s.group]
+
+	var right []string
+	from, to = window(len(g.files), s.file, body-2)
+	for i := from; i < to; i++ {
+		file := g.files[i]
+
+		cursor := " "
+		if i == s.file {
+			cursor = ">"
+		}
+
+		mark := "[ ]"
+		switch {
+		case g.protected[file]:
+			mark = "[P]"
+		case g.marked[file]:
+			mark = colorize(deleteColor, "[D]")
+		}
+
+		right = append(right, fmt.Sprintf("%s %s %s", cursor, mark, file))
+	}
+	right = append(right, "", strings.TrimSpace(fileDetails(g.files[s.file])))
+
+	lines := []string{colorize(headerColor, fmt.Sprintf("Group %d of %d, %d identical files of %s", s.group+1, len(s.groups), len(g.files), humanSize(g.size)))}
+	for i := 0; i < body; i++ {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+
+		lines = append(lines, fit(l, leftWidth)+" │ "+fit(r, rightWidth))
+	}
+
+	status := tuiHelp
+	if s.status != "" {
+		status = s.status
+	}
+
+	return append(lines, "", fit(status, width))
+}
+
+// renderApply draws the apply screen, listing what's about to happen before confirming it
+func (s *tuiState) renderApply(height int, opts options) []string {
+	decisions := s.decisions()
+
+	var files int
+	for _, d := range decisions {
+		files += len(d.files)
+	}
+
+	lines := []string{colorize(headerColor, fmt.Sprintf("%d file(s) of %d group(s) will be %s.", files, len(decisions), describeAction(opts))), ""}
+
+	for i, d := range decisions {
+		if len(lines) >= height-2 {
+			lines = append(lines, fmt.Sprintf("... and %d more group(s)", len(decisions)-i))
+			break
+		}
+
+		lines = append(lines, fmt.Sprintf("keep %s, %d file(s) to delete", d.keep, len(d.files)))
+	}
+
+	return append(lines, "", "y apply  n back to the review")
+}
+
+// window returns the range of n lines to show in height lines, so that the cursor is visible
+func window(n, cursor, height int) (int, int) {
+	if height < 1 {
+		height = 1
+	}
+
+	if n <= height {
+		return 0, n
+	}
+
+	from := cursor - height/2
+	if from < 0 {
+		from = 0
+	}
+	if from+height > n {
+		from = n - height
+	}
+
+	return from, from + height
+}
+
+// fit pads or truncates s to width characters, escape sequences of colors don't count
+func fit(s string, width int) string {
+	visible := utf8.RuneCountInString(stripColors(s))
+
+	if visible <= width {
+		return s + strings.Repeat(" ", width-visible)
+	}
+
+	runes := []rune(stripColors(s))
+	if width < 1 {
+		return ""
+	}
+
+	return string(runes[:width-1]) + "…"
+}
+
+// stripColors removes the color escape sequences of colorize from s
+func stripColors(s string) string {
+	for _, c := range []string{colorReset, colorBold, colorRed, colorGreen, colorYellow, colorCyan} {
+		s = strings.Replace(s, c, "", -1)
+	}
+
+	return s
+}
+
+// parseKey names the key whose input was read, or returns the character typed
+func parseKey(b []byte) string {
+	switch string(b) {
+	case "\x1b[A", "\x1bOA":
+		return "up"
+	case "\x1b[B", "\x1bOB":
+		return "down"
+	case "\x1b[C", "\x1bOC":
+		return "right"
+	case "\x1b[D", "\x1bOD":
+		return "left"
+	case "\r", "\n":
+		return "enter"
+	case "\x1b":
+		return "esc"
+	case "\x03", "\x04":
+		return "ctrl-c"
+	}
+
+	return string(b)
+}
+
+// reviewTUI lets the groups be reviewed on a full screen terminal UI, and returns the decisions to apply
+// Nothing is decided unless the review ends on the apply screen, quitting leaves all the files alone.
+func reviewTUI(groups [][]string, opts options) ([]decision, error) {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return nil, fmt.Errorf("-tui requires a terminal")
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Print(tuiAltScreen + tuiHideCursor)
+	defer func() {
+		fmt.Print(tuiShowCursor + tuiMainScreen)
+		term.Restore(in, state)
+	}()
+
+	s := newTUIState(groups, opts.protect)
+	buf := make([]byte, 16)
+
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+
+		fmt.Print(tuiClear + strings.Join(s.render(width, height, opts), "\r\n"))
+
+		n, err := os.Stdin.Read(buf)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch s.update(parseKey(buf[:n])) {
+		case tuiApply:
+			return s.decisions(), nil
+		case tuiQuit:
+			return nil, nil
+		}
+	}
+}
+
+// reviewGroups reviews the groups in the TUI, and prepares the decisions made the same way as execute does
+func reviewGroups(groups [][]string, opts options) []decision {
+	reviewed, err := reviewTUI(groups, opts)
+	if err != nil {
+		fmt.Printf("review failed: %v\n", err)
+		setExitCode(exitError)
+		return nil
+	}
+
+	if len(reviewed) == 0 {
+		progressf("Nothing was marked for deletion.\n")
+		return nil
+	}
+
+	var decisions []decision
+	for _, d := range reviewed {
+		deleteFiles := d.files
+
+		if opts.settle > 0 {
+			deleteFiles = settledFiles(deleteFiles, opts.settle, time.Now())
+		}
+
+		if opts.verify {
+			deleteFiles = verifyDeleteFiles(d.keep, deleteFiles)
+		}
+
+		if len(deleteFiles) > 0 {
+			decisions = append(decisions, decision{d.keep, deleteFiles, d.reason})
+		}
+	}
+
+	return act(decisions, opts)
+}
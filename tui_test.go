@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_tuiState_update(t *testing.T) {
+	groups := [][]string{{"a1", "a2", "a3"}, {"b1", "b2"}}
+
+	tests := []struct {
+		name   string
+		keys   []string
+		want   []decision
+		result tuiResult
+	}{
+		{"nothing-marked", []string{"enter", "y"}, nil, tuiApply},
+		{"delete-second", []string{"down", "d", "enter", "y"}, []decision{{"a1", []string{"a2"}, manualReason}}, tuiApply},
+		{"keep-only", []string{"right", "down", "s", "enter", "enter"}, []decision{{"b2", []string{"b1"}, manualReason}}, tuiApply},
+		{"last-file-kept", []string{"right", "d", "down", "d", "enter", "y"}, []decision{{"b2", []string{"b1"}, manualReason}}, tuiApply},
+		{"undo", []string{"d", "down", "d", "u", "enter", "y"}, []decision{{"a2", []string{"a1"}, manualReason}}, tuiApply},
+		{"keep-again", []string{"d", "k", "enter", "y"}, nil, tuiApply},
+		{"back-from-apply", []string{"enter", "n", "d", "enter", "y"}, []decision{{"a2", []string{"a1"}, manualReason}}, tuiApply},
+		{"quit", []string{"d", "q"}, []decision{{"a2", []string{"a1"}, manualReason}}, tuiQuit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTUIState(groups, nil)
+
+			result := tuiContinue
+			for _, key := range tt.keys {
+				if result = s.update(key); result != tuiContinue {
+					break
+				}
+			}
+
+			if got := s.decisions(); result != tt.result || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("update() = %v, decisions %v, want %v, %v", result, got, tt.result, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tuiState_protected(t *testing.T) {
+	protect, err := parsePathPatterns([]string{"a1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTUIState([][]string{{"a1", "a2"}}, protect)
+	s.update("d")
+
+	if len(s.decisions()) != 0 || s.status == "" {
+		t.Errorf("update() marked a protected file, status %q", s.status)
+	}
+}
+
+func Test_tuiState_render(t *testing.T) {
+	s := newTUIState([][]string{{"a1", "a2"}, {"b1", "b2"}}, nil)
+	s.update("down")
+	s.update("d")
+
+	lines := s.render(80, 10, options{action: trashAction})
+	if len(lines) != 10 {
+		t.Fatalf("render() = %d lines, want 10", len(lines))
+	}
+
+	screen := strings.Join(lines, "\n")
+	for _, want := range []string{"Group 1 of 2", "> 1. 2 × 0 B, 1 to delete", "> [D] a2", "  [ ] a1"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("render() = %s\nwant it to contain %q", screen, want)
+		}
+	}
+
+	s.update("enter")
+	if screen := strings.Join(s.render(80, 10, options{action: trashAction}), "\n"); !strings.Contains(screen, "1 file(s) of 1 group(s) will be moved to the trash.") {
+		t.Errorf("render() apply screen = %s", screen)
+	}
+}
+
+func Test_parseKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"\x1b[A", "up"},
+		{"\x1bOB", "down"},
+		{"\r", "enter"},
+		{"\x03", "ctrl-c"},
+		{"d", "d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := parseKey([]byte(tt.in)); got != tt.want {
+				t.Errorf("parseKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
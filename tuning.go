@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	// minTuningChecks is the number of checked groups needed before a sample size is considered safe
+	minTuningChecks = 20
+	tuningGrowth    = 4
+)
+
+// sampleFeedback collects how groups of files found identical by sampling fared when their full content was hashed
+var sampleFeedback = &feedback{}
+
+type feedback struct {
+	mu      sync.Mutex
+	results []sampleResult
+}
+
+type sampleResult struct {
+	sampleSize    int
+	files         []string
+	falsePositive bool
+}
+
+// record stores the outcome of checking a group found by sampling against the full content of its files
+func (f *feedback) record(sampleSize int, files []string, falsePositive bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.results = append(f.results, sampleResult{sampleSize, files, falsePositive})
+}
+
+// recordSampleFeedback compares groups found by samples of sampleSize with the groups left after hashing the full content
+// A sampled group counts as a false positive if its files did not all end up in the same group.
+func recordSampleFeedback(sampleSize int, sampled, full [][]string) {
+	groupLen := map[string]int{}
+	for _, files := range full {
+		for _, file := range files {
+			groupLen[file] = len(files)
+		}
+	}
+
+	for _, files := range sampled {
+		sampleFeedback.record(sampleSize, files, groupLen[files[0]] != len(files))
+	}
+}
+
+// sampleCounts is the number of sampled groups checked and found to be false positives for a sample size
+type sampleCounts struct {
+	Checked        int `json:"checked"`
+	FalsePositives int `json:"false_positives"`
+}
+
+// tuningState is persisted between runs and holds sample counts per root and per sample size in bytes
+type tuningState struct {
+	Roots map[string]map[int]*sampleCounts `json:"roots"`
+}
+
+// defaultTuningFile returns the location of the sample tuning state in the user's cache directory
+func defaultTuningFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "dblfinder", "tuning.json")
+}
+
+// updateTuning adds the feedback collected in this run to the persisted tuning state and prints recommendations
+// Dry runs leave the state alone, and recommendations go to stderr, unless in quiet mode or reporting in another format
+// than text, so that they never mix with the results.
+func updateTuning(tuningFile string, roots []string, opts options) {
+	if tuningFile == "" {
+		return
+	}
+
+	state, err := loadTuning(tuningFile)
+	if err != nil {
+		slog.Warn("failed loading sample tuning state", "err", err)
+		return
+	}
+
+	absRoots := make([]string, len(roots))
+	for i, root := range roots {
+		absRoots[i], _ = filepath.Abs(root)
+	}
+
+	sampleFeedback.mu.Lock()
+	results := sampleFeedback.results
+	sampleFeedback.mu.Unlock()
+
+	for _, res := range results {
+		for _, root := range rootsOf(res.files, absRoots) {
+			if state.Roots[root] == nil {
+				state.Roots[root] = map[int]*sampleCounts{}
+			}
+
+			counts := state.Roots[root][res.sampleSize]
+			if counts == nil {
+				counts = &sampleCounts{}
+				state.Roots[root][res.sampleSize] = counts
+			}
+
+			counts.Checked++
+			if res.falsePositive {
+				counts.FalsePositives++
+			}
+		}
+	}
+
+	if len(results) > 0 && !opts.dryRun {
+		if err := saveTuning(tuningFile, state); err != nil {
+			slog.Warn("failed saving sample tuning state", "err", err)
+		}
+	}
+
+	if opts.quiet || opts.output != textOutput || opts.print0 {
+		return
+	}
+
+	for _, root := range absRoots {
+		if msg := tuningRecommendation(state.Roots[root], opts.sampleSize); msg != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", root, msg)
+		}
+	}
+}
+
+// rootsOf returns the roots containing any of the files given
+func rootsOf(files []string, absRoots []string) []string {
+	var res []string
+
+	for _, root := range absRoots {
+		for _, file := range files {
+			abs, err := filepath.Abs(file)
+			if err == nil && inRoot(abs, root) {
+				res = append(res, root)
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// suggestSampleSize returns the smallest sample size in bytes expected to be free of false positives for a root
+// If no sample size has been checked enough yet, 0 is returned.
+func suggestSampleSize(counts map[int]*sampleCounts) int {
+	var sizes []int
+	for size := range counts {
+		sizes = append(sizes, size)
+	}
+
+	sort.Ints(sizes)
+
+	worst := 0
+	for _, size := range sizes {
+		if counts[size].FalsePositives > 0 {
+			worst = size
+		}
+	}
+
+	for _, size := range sizes {
+		if size > worst && counts[size].FalsePositives == 0 && counts[size].Checked >= minTuningChecks {
+			return size
+		}
+	}
+
+	if worst > 0 {
+		return worst * tuningGrowth
+	}
+
+	return 0
+}
+
+// tuningRecommendation returns a recommendation for the sample size of a root, or an empty string if the sample size
+// in use is fine or there is not enough data yet
+func tuningRecommendation(counts map[int]*sampleCounts, sampleSize int) string {
+	suggested := suggestSampleSize(counts)
+	if suggested == 0 || sampleSize == 0 || suggested <= sampleSize {
+		return ""
+	}
+
+	c := counts[sampleSize]
+	if c != nil && c.FalsePositives == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("earlier full hash runs found false positives with samples of this size, consider -sample-size %d or -sample-strategy head,middle,tail", (suggested+KB-1)/KB)
+}
+
+// loadTuning reads the tuning state, a missing file is treated as an empty state
+func loadTuning(tuningFile string) (*tuningState, error) {
+	state := &tuningState{Roots: map[string]map[int]*sampleCounts{}}
+
+	data, err := ioutil.ReadFile(tuningFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("can't parse %s: %v", tuningFile, err)
+	}
+
+	if state.Roots == nil {
+		state.Roots = map[string]map[int]*sampleCounts{}
+	}
+
+	return state, nil
+}
+
+// saveTuning writes the tuning state via a temp file, so that it's never left half written
+func saveTuning(tuningFile string, state *tuningState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(tuningFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".tuning-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), tuningFile)
+}
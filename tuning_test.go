@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_suggestSampleSize(t *testing.T) {
+	type args struct {
+		counts map[int]*sampleCounts
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			"no-data",
+			args{
+				nil,
+			},
+			0,
+		},
+		{
+			"not-enough-checks",
+			args{
+				map[int]*sampleCounts{
+					4096: {Checked: 5},
+				},
+			},
+			0,
+		},
+		{
+			"clean",
+			args{
+				map[int]*sampleCounts{
+					4096: {Checked: minTuningChecks},
+				},
+			},
+			4096,
+		},
+		{
+			"false-positives-grow-sample",
+			args{
+				map[int]*sampleCounts{
+					4096: {Checked: 50, FalsePositives: 3},
+				},
+			},
+			4096 * tuningGrowth,
+		},
+		{
+			"false-positives-with-checked-larger-size",
+			args{
+				map[int]*sampleCounts{
+					4096:    {Checked: 50, FalsePositives: 3},
+					1048576: {Checked: 47},
+				},
+			},
+			1048576,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggestSampleSize(tt.args.counts); got != tt.want {
+				t.Errorf("suggestSampleSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_recordSampleFeedback(t *testing.T) {
+	sampleFeedback = &feedback{}
+
+	sampled := [][]string{{"a", "b"}, {"c", "d", "e"}, {"f", "g"}}
+	full := [][]string{{"a", "b"}, {"c", "d"}}
+
+	recordSampleFeedback(4096, sampled, full)
+
+	want := []bool{false, true, true}
+	if len(sampleFeedback.results) != len(want) {
+		t.Fatalf("recordSampleFeedback() recorded %d results, want %d", len(sampleFeedback.results), len(want))
+	}
+
+	for i, res := range sampleFeedback.results {
+		if res.falsePositive != want[i] {
+			t.Errorf("recordSampleFeedback() %v falsePositive = %v, want %v", res.files, res.falsePositive, want[i])
+		}
+	}
+}
+
+func Test_updateTuning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(f *feedback) { sampleFeedback = f }(sampleFeedback)
+	sampleFeedback = &feedback{}
+	sampleFeedback.record(4096, []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, true)
+
+	tuningFile := filepath.Join(dir, "tuning.json")
+
+	updateTuning(tuningFile, []string{dir}, options{output: textOutput, dryRun: true})
+	if exists(tuningFile) {
+		t.Errorf("updateTuning() saved the state of a dry run")
+	}
+
+	updateTuning(tuningFile, []string{dir}, options{output: textOutput})
+	state, err := loadTuning(tuningFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := state.Roots[dir][4096]; c == nil || c.Checked != 1 || c.FalsePositives != 1 {
+		t.Errorf("updateTuning() saved %+v, want the false positive of the run", state.Roots[dir])
+	}
+}
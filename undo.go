@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runUndo implements the undo command, which reverts the decisions recorded in an audit log, the latest first
+// - hardlink gives the files their own copy of the content again
+// - mark removes the files from the marks file
+// - move moves the files back from the target directory
+// Deleted files are gone, trashed ones are to be restored from the trash, and reflinks and deduplicated files already
+// have contents of their own, so these are only reported.
+// The kept files get back the owner recorded before acting, which -chown-to may have changed.
+func runUndo(args []string) error {
+	var (
+		dryRun            bool
+		target, marksFile string
+	)
+
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "only report what would be undone")
+	fs.StringVar(&target, "target", "", "quarantine directory the move action moved files to")
+	fs.StringVar(&marksFile, "marks-file", defaultMarksFile(), "file storing the list of files marked for deletion by the mark action")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dblfinder undo [-dry-run] [-target <d>] [-marks-file <f>] <audit-log> [<root>...]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("an audit log is expected")
+	}
+
+	entries, err := loadAuditLog(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	roots := fs.Args()[1:]
+
+	var unmark []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.DryRun {
+			continue
+		}
+
+		restoreOwner(e, dryRun)
+
+		switch e.Action {
+		case linkAction:
+			for _, file := range e.Files {
+				unlinkFile(e.Keep, file, dryRun)
+			}
+		case markAction:
+			unmark = append(unmark, e.Files...)
+		case moveAction:
+			if target == "" || len(roots) == 0 {
+				return fmt.Errorf("the audit log records moved files, -target and the roots the files were found under are required")
+			}
+
+			for _, file := range e.Files {
+				moveBack(file, roots, target, dryRun)
+			}
+		case reflinkAction, dedupeAction:
+			fmt.Printf("Nothing to undo for %s, the files have their own content: %v\n", e.Action, e.Files)
+		case trashAction:
+			fmt.Printf("Can't undo %s, restore the files from the trash: %v\n", e.Action, e.Files)
+		default:
+			fmt.Printf("Can't undo %s, the files are gone: %v\n", e.Action, e.Files)
+		}
+	}
+
+	if len(unmark) == 0 {
+		return nil
+	}
+
+	return unmarkFiles(marksFile, unmark, dryRun)
+}
+
+// unlinkFile replaces a hard link of the kept file by a copy of its content, unless dryRun is set
+func unlinkFile(keep, file string, dryRun bool) {
+	if linked, err := sameFile(keep, file); err != nil || !linked {
+		fmt.Printf("Unlinking: %s (skipped, not a hard link of %s)\n", file, keep)
+		return
+	}
+
+	if dryRun {
+		fmt.Printf("Unlinking: %s (skipped)\n", file)
+		return
+	}
+
+	fmt.Printf("Unlinking: %s\n", file)
+
+	tmp := file + ".dblfinder-undo"
+	if err := copyFile(file, tmp); err != nil {
+		os.Remove(tmp)
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	fmt.Println("done.")
+}
+
+// sameFile returns true if both paths are links of the same file
+func sameFile(a, b string) (bool, error) {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+
+	return os.SameFile(fa, fb), nil
+}
+
+// restoreOwner changes the owner of the kept file of an audit log entry back to the one recorded before acting, unless
+// dryRun is set
+func restoreOwner(e auditEntry, dryRun bool) {
+	recorded, ok := e.Owners[e.Keep]
+	if !ok || !chownSupported {
+		return
+	}
+
+	var was ownership
+	if _, err := fmt.Sscanf(recorded, "%d:%d", &was.UID, &was.GID); err != nil {
+		fmt.Printf("invalid owner recorded for %s: %s\n", e.Keep, recorded)
+		return
+	}
+
+	current, ok := fileOwnership(e.Keep)
+	if !ok || current == was {
+		return
+	}
+
+	if dryRun {
+		fmt.Printf("Owner of %s would be changed back from %s to %s\n", e.Keep, current, was)
+		return
+	}
+
+	if err := os.Lchown(e.Keep, was.UID, was.GID); err != nil {
+		fmt.Printf("can't change owner of file: %s, err %v\n", e.Keep, err)
+		return
+	}
+
+	fmt.Printf("Owner of %s changed back from %s to %s\n", e.Keep, current, was)
+}
+
+// moveBack moves a file from the target directory back to where it was found, unless dryRun is set
+func moveBack(file string, roots []string, target string, dryRun bool) {
+	src, err := quarantinePath(file, roots, target)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	switch {
+	case !exists(src):
+		fmt.Printf("Moving back: %s (skipped, %s is gone)\n", file, src)
+		return
+	case exists(file):
+		fmt.Printf("Moving back: %s (skipped, it already exists)\n", file)
+		return
+	case dryRun:
+		fmt.Printf("Moving back: %s => %s (skipped)\n", src, file)
+		return
+	}
+
+	fmt.Printf("Moving back: %s => %s\n", src, file)
+
+	if err := moveFile(src, file); err != nil {
+		fmt.Printf("%v\n", err)
+	} else {
+		fmt.Println("done.")
+	}
+}
+
+// unmarkFiles removes the files from the marks file, unless dryRun is set
+func unmarkFiles(marksFile string, files []string, dryRun bool) error {
+	marks, err := loadMarks(marksFile)
+	if err != nil {
+		return err
+	}
+
+	unmark := map[string]bool{}
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			unmark[abs] = true
+		}
+	}
+
+	var remaining []mark
+	for _, m := range marks {
+		if !unmark[m.Path] {
+			remaining = append(remaining, m)
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("Unmarking: %s (skipped)\n", m.Path)
+		} else {
+			fmt.Printf("Unmarking: %s\n", m.Path)
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	return saveMarks(marksFile, remaining)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_runUndo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	for _, path := range []string{a, c} {
+		if err := ioutil.WriteFile(path, []byte("abcd"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	marksFile := filepath.Join(dir, "marked.json")
+	if err := saveMarks(marksFile, newMarks(a, []string{c}, manualReason)); err != nil {
+		t.Fatal(err)
+	}
+
+	auditLog := filepath.Join(dir, "audit.jsonl")
+	if err := writeAuditLog(auditLog, []decision{{a, []string{b}, manualReason}}, nil, options{action: linkAction}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAuditLog(auditLog, []decision{{a, []string{c}, manualReason}}, nil, options{action: markAction}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runUndo([]string{"-marks-file", marksFile, auditLog}); err != nil {
+		t.Fatal(err)
+	}
+
+	if linked, err := sameFile(a, b); err != nil || linked {
+		t.Errorf("undo didn't unlink %s from %s: %v", b, a, err)
+	}
+
+	if content, err := ioutil.ReadFile(b); err != nil || string(content) != "abcd" {
+		t.Errorf("undo changed the content of %s to %q: %v", b, content, err)
+	}
+
+	if marks, err := loadMarks(marksFile); err != nil || len(marks) != 0 {
+		t.Errorf("undo left marks %+v: %v", marks, err)
+	}
+}
+
+func Test_restoreOwner(t *testing.T) {
+	if !chownSupported {
+		t.Skip("ownership is not supported on this platform")
+	}
+
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keep := filepath.Join(dir, "keep")
+	if err := ioutil.WriteFile(keep, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	was, ok := fileOwnership(keep)
+	if !ok {
+		t.Fatal("fileOwnership() failed")
+	}
+
+	// -chown-to gave the kept file to another user, which needs privileges
+	other := ownership{was.UID + 1, was.GID}
+	if err := os.Lchown(keep, other.UID, other.GID); err != nil {
+		t.Skipf("can't change the owner of files: %v", err)
+	}
+
+	auditLog := filepath.Join(dir, "audit.jsonl")
+	if err := writeAuditLog(auditLog, []decision{{keep, []string{"gone"}, manualReason}}, map[string]ownership{keep: was}, options{action: deleteAction}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runUndo([]string{auditLog}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := fileOwnership(keep); got != was {
+		t.Errorf("undo left owner %v, want %v", got, was)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// actions lists the available actions
+var actions = []action{listAction, keepAction, deleteAction, markAction, linkAction, reflinkAction, dedupeAction, trashAction, moveAction}
+
+// actionNames returns the names of the available actions
+func actionNames() []string {
+	var res []string
+	for _, a := range actions {
+		res = append(res, string(a))
+	}
+
+	return res
+}
+
+// parseAction returns the action with the given name
+func parseAction(name string) (action, error) {
+	for _, a := range actions {
+		if string(a) == name {
+			return a, nil
+		}
+	}
+
+	return "", unknownValue("action", name, actionNames())
+}
+
+// unknownValue returns an error for a value which is not one of the available ones, suggesting the closest one
+func unknownValue(kind, value string, available []string) error {
+	if s := suggest(value, available); s != "" {
+		return fmt.Errorf("unknown %s: %s, did you mean %s? available: %s", kind, value, s, strings.Join(available, ", "))
+	}
+
+	return fmt.Errorf("unknown %s: %s, available: %s", kind, value, strings.Join(available, ", "))
+}
+
+// suggest returns the available value closest to a mistyped one, or an empty string if none is close enough
+func suggest(value string, available []string) string {
+	var (
+		best     string
+		bestDist = len(value)/2 + 1
+	)
+
+	for _, a := range available {
+		if d := editDistance(strings.ToLower(value), a); d < bestDist {
+			best, bestDist = a, d
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance of two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+// min3 returns the smallest of three integers
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+
+	if c < a {
+		a = c
+	}
+
+	return a
+}
+
+// validateOptions checks the combination of options up front, so that runs don't fail or surprise halfway through
+func validateOptions(opts options) error {
+	destructive := opts.action != listAction
+
+	switch {
+	case opts.fsLimit < 1:
+		return fmt.Errorf("-fs-limit must be at least 1")
+	case opts.sampleSize < 0:
+		return fmt.Errorf("-sample-size can't be negative, use -full-hash to hash whole files")
+	case opts.filter.maxDepth < 0:
+		return fmt.Errorf("-max-depth can't be negative, use 0 for no limit")
+	case opts.bucketMax < 0:
+		return fmt.Errorf("-bucket-limit can't be negative, use 0 to disable the check")
+	case opts.maxDuration < 0:
+		return fmt.Errorf("-max-duration can't be negative, use 0 for no limit")
+	case opts.top < 0:
+		return fmt.Errorf("-top can't be negative, use 0 to handle all groups")
+	case opts.top > 0 && opts.output == ndjsonOutput:
+		return fmt.Errorf("-output %s streams groups as they are found, before the largest ones are known, it can't be used with -top", ndjsonOutput)
+	case opts.thumbs.limit < 0:
+		return fmt.Errorf("-thumb-cache-size can't be negative")
+	case opts.quiet && opts.verbose:
+		return fmt.Errorf("-quiet and -verbose can't be used together")
+	case opts.quiet && destructive && opts.keep == "" && opts.action != deleteAction && !opts.skipManual:
+		return fmt.Errorf("-quiet doesn't list groups to answer the keep prompt for, add -keep to decide automatically")
+	case opts.tui && (!destructive || opts.action == deleteAction):
+		return fmt.Errorf("-tui reviews the files to act on, it requires an -action other than %s and %s", listAction, deleteAction)
+	case opts.tui && (opts.plain || opts.quiet || opts.keep != "" || opts.skipManual || opts.learn):
+		return fmt.Errorf("-tui can't be used with -plain, -quiet, -keep, -skip-manual or -learn")
+	case opts.listen != "" && len(opts.roots) > 0:
+		return fmt.Errorf("-listen scans the roots given in the requests it receives, it takes no roots")
+	case opts.listen != "" && (opts.tui || opts.keep != "" || opts.learn):
+		return fmt.Errorf("-listen acts on the decisions it receives, it can't be used with -tui, -keep or -learn")
+	case opts.acrossRoots && opts.sameDir:
+		return fmt.Errorf("-across-roots-only and -same-dir-only can't be used together")
+	case opts.quick && destructive:
+		return fmt.Errorf("files are not compared in -quick mode, so -action %s can't be used with it", opts.action)
+	case opts.match == matchNameSize && destructive:
+		return fmt.Errorf("-match %s only lists matches, -action %s can't be used with it, verify them with verify-matches first", matchNameSize, opts.action)
+	case opts.matchesOut != "" && opts.match != matchNameSize:
+		return fmt.Errorf("-matches-file requires -match %s", matchNameSize)
+	case opts.export != "" && (opts.quick || opts.match == matchNameSize):
+		return fmt.Errorf("-export requires files to be hashed, it can't be used with -quick or -match %s", matchNameSize)
+	case opts.failOnDups && (opts.quick || opts.match == matchNameSize):
+		return fmt.Errorf("-fail-on-duplicates requires files to be hashed, it can't be used with -quick or -match %s", matchNameSize)
+	case opts.output != textOutput && (opts.quick || opts.match == matchNameSize):
+		return fmt.Errorf("-output %s requires files to be hashed, it can't be used with -quick or -match %s", opts.output, matchNameSize)
+	case opts.groupSep != "" && opts.output != fdupesOutput:
+		return fmt.Errorf("-group-separator is only used by -output %s and -print0", fdupesOutput)
+	case opts.reportFile != "" && opts.output == textOutput:
+		return fmt.Errorf("-report-file requires an -output format other than %s", textOutput)
+	case opts.output != textOutput && destructive && opts.reportFile == "":
+		return fmt.Errorf("-output %s only reports duplicates, it can't be used with -action %s unless written to a -report-file", opts.output, opts.action)
+	case opts.skipManual && len(opts.prefer) == 0:
+		return fmt.Errorf("-skip-manual has no effect without -prefer, add a -prefer pattern or use -keep to decide automatically")
+	case opts.learn && (!destructive || opts.action == deleteAction || opts.keep != "" || opts.skipManual):
+		return fmt.Errorf("-learn only applies to groups decided by answering the keep prompt")
+	case opts.auditLog != "" && !destructive:
+		return fmt.Errorf("-audit-log has no effect with -action %s, as nothing is acted on", listAction)
+	case opts.requireBackup && opts.manifest == "":
+		return fmt.Errorf("-require-backup needs a -manifest to look up backed up content in")
+	case opts.requireBackup && !destructive:
+		return fmt.Errorf("-require-backup has no effect with -action %s", listAction)
+	case opts.chownTo != nil && opts.refuseMixed:
+		return fmt.Errorf("-chown-to and -refuse-mixed-owners can't be used together")
+	case (opts.chownTo != nil || opts.refuseMixed) && (!opts.asAdmin || !destructive):
+		return fmt.Errorf("-chown-to and -refuse-mixed-owners only apply to groups owned by multiple users, which are only acted on with -as-admin and an -action other than %s", listAction)
+	case opts.chownTo != nil && !chownSupported:
+		return fmt.Errorf("-chown-to is not supported on this platform")
+	case opts.keep != "" && !destructive:
+		return fmt.Errorf("-keep has no effect with -action %s, add eg. -action %s", listAction, deleteAction)
+	case opts.action == deleteAction && len(opts.prefer) == 0 && opts.keep == "":
+		return fmt.Errorf("-action %s requires -prefer or -keep to pick the files to keep", deleteAction)
+	case opts.action == moveAction && opts.target == "":
+		return fmt.Errorf("-action %s requires a -target directory", moveAction)
+	case opts.trashBackend != trashAuto && opts.action != trashAction:
+		return fmt.Errorf("-trash-backend is only used by -action %s", trashAction)
+	case opts.action != moveAction && opts.target != "":
+		return fmt.Errorf("-target is only used by -action %s", moveAction)
+	case opts.storage != nil && checkCapabilities(opts.storage, opts.action) != nil:
+		return checkCapabilities(opts.storage, opts.action)
+	case opts.action == dedupeAction && !dedupeSupported:
+		return fmt.Errorf("-action %s is only supported on Linux", dedupeAction)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    action
+		wantErr string
+	}{
+		{"trash", trashAction, ""},
+		{"delet", "", "did you mean delete?"},
+		{"hardlnk", "", "did you mean hardlink?"},
+		{"frobnicate", "", "unknown action: frobnicate, available:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAction(tt.name)
+			if got != tt.want {
+				t.Errorf("parseAction() = %v, want %v", got, tt.want)
+			}
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("parseAction() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateOptions(t *testing.T) {
+	valid := options{action: listAction, fsLimit: 10, match: matchContent, output: textOutput, trashBackend: trashAuto}
+
+	tests := []struct {
+		name    string
+		modify  func(o *options)
+		wantErr string
+	}{
+		{"defaults", func(o *options) {}, ""},
+		{"fs-limit", func(o *options) { o.fsLimit = 0 }, "-fs-limit"},
+		{"negative-sample-size", func(o *options) { o.sampleSize = -1 }, "-sample-size"},
+		{"top-ndjson", func(o *options) { o.top, o.output = 10, ndjsonOutput }, "-top"},
+		{"quiet-verbose", func(o *options) { o.quiet, o.verbose = true, true }, "-quiet"},
+		{"quiet-prompt", func(o *options) { o.quiet, o.action = true, trashAction }, "-quiet"},
+		{"tui-list", func(o *options) { o.tui = true }, "-tui"},
+		{"tui-keep", func(o *options) { o.tui, o.action, o.keep = true, trashAction, keepNewest }, "-tui"},
+		{"listen-roots", func(o *options) { o.listen, o.roots = ":8080", []string{"."} }, "-listen"},
+		{"listen-tui", func(o *options) { o.listen, o.tui, o.action = ":8080", true, trashAction }, "-listen"},
+		{"negative-max-duration", func(o *options) { o.maxDuration = -time.Second }, "-max-duration"},
+		{"skip-manual-without-prefer", func(o *options) { o.action, o.skipManual = keepAction, true }, "-skip-manual"},
+		{"skip-manual-with-prefer", func(o *options) { o.action, o.skipManual, o.prefer = keepAction, true, []string{"x"} }, ""},
+		{"keep-with-list", func(o *options) { o.keep = keepOldest }, "-keep has no effect"},
+		{"delete-without-survivor", func(o *options) { o.action = deleteAction }, "requires -prefer or -keep"},
+		{"delete-with-keep", func(o *options) { o.action, o.keep = deleteAction, keepOldest }, ""},
+		{"move-without-target", func(o *options) { o.action = moveAction }, "-target"},
+		{"target-without-move", func(o *options) { o.target = "/q" }, "only used by -action move"},
+		{"quick-with-action", func(o *options) { o.quick, o.action, o.keep = true, deleteAction, keepOldest }, "-quick"},
+		{"matches-file-without-name-size", func(o *options) { o.matchesOut = "m.json" }, "-matches-file"},
+		{"json-with-action", func(o *options) { o.output, o.action, o.keep = jsonOutput, deleteAction, keepOldest }, "only reports duplicates"},
+		{"csv-to-file-with-action", func(o *options) { o.output, o.reportFile, o.action = csvOutput, "r.csv", trashAction }, ""},
+		{"report-file-with-text", func(o *options) { o.reportFile = "r.csv" }, "-report-file"},
+		{"trash-backend-without-trash", func(o *options) { o.trashBackend = trashNative }, "-trash-backend"},
+		{"require-backup-without-manifest", func(o *options) { o.requireBackup, o.action = true, trashAction }, "-manifest"},
+		{"require-backup", func(o *options) { o.requireBackup, o.manifest, o.action = true, "m.jsonl", trashAction }, ""},
+		{"json-with-quick", func(o *options) { o.output, o.quick = jsonOutput, true }, "requires files to be hashed"},
+		{"learn-with-list", func(o *options) { o.learn = true }, "-learn"},
+		{"learn-with-prompt", func(o *options) { o.learn, o.action = true, trashAction }, ""},
+		{"across-roots-and-same-dir", func(o *options) { o.acrossRoots, o.sameDir = true, true }, "can't be used together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.modify(&opts)
+
+			err := validateOptions(opts)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateOptions() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
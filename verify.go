@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const verifyChunkSize = 64 * 1024
+
+// verifyDeleteFiles compares each file marked for deletion byte by byte with a file that is kept and returns only the
+// ones that are proven to be identical
+func verifyDeleteFiles(keep string, deleteFiles []string) []string {
+	defer stats.track(verifyStage)()
+
+	var res []string
+
+	for _, file := range deleteFiles {
+		stats.add(verifyStage, 1, 0)
+
+		same, err := sameContent(keep, file)
+		if err != nil {
+			fmt.Printf("Verification failed, keeping: %s, err %v\n", file, err)
+			continue
+		}
+
+		if !same {
+			fmt.Printf("Content differs from %s, keeping: %s\n", keep, file)
+			continue
+		}
+
+		res = append(res, file)
+	}
+
+	return res
+}
+
+// sameContent compares two files chunk by chunk, using constant memory
+func sameContent(a, b string) (bool, error) {
+	fa, err := fds.openFile(verifyStage, a)
+	if err != nil {
+		return false, err
+	}
+	defer fds.closeFile(verifyStage, fa)
+
+	fb, err := fds.openFile(verifyStage, b)
+	if err != nil {
+		return false, err
+	}
+	defer fds.closeFile(verifyStage, fb)
+
+	bufA := make([]byte, verifyChunkSize)
+	bufB := make([]byte, verifyChunkSize)
+
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		stats.add(verifyStage, 0, int64(na+nb))
+
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+
+		if errA != nil && !endA {
+			return false, errA
+		}
+
+		if errB != nil && !endB {
+			return false, errB
+		}
+
+		if endA || endB {
+			return endA && endB, nil
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_sameContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	big := strings.Repeat("x", verifyChunkSize+10)
+	files := map[string]string{
+		"a":     big + "a",
+		"a-dup": big + "a",
+		"b":     big + "b",
+		"short": big,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type args struct {
+		a string
+		b string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			"identical",
+			args{
+				"a",
+				"a-dup",
+			},
+			true,
+		},
+		{
+			"differs-after-first-chunk",
+			args{
+				"a",
+				"b",
+			},
+			false,
+		},
+		{
+			"prefix-only",
+			args{
+				"a",
+				"short",
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sameContent(filepath.Join(dir, tt.args.a), filepath.Join(dir, tt.args.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("sameContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
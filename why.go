@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/peteraba/dblfinder/model"
+)
+
+// manualReason is the reason of decisions made by answering the keep prompt
+const manualReason = "manual"
+
+// preferReason returns the reason of a decision made by the nth prefer pattern, counted from 1
+func preferReason(n int) string {
+	return fmt.Sprintf("prefer:%d", n)
+}
+
+// keepReason returns the reason of a decision made by a keep policy, preferred files narrowing the candidates first
+func keepReason(policy string, preferRank int) string {
+	if preferRank > 0 {
+		return preferReason(preferRank) + ",keep:" + policy
+	}
+
+	return "keep:" + policy
+}
+
+// learnedReason returns the reason of a decision made by learned directory preferences
+func learnedReason(prefs []dirPreference) string {
+	var res []string
+	for _, p := range prefs {
+		res = append(res, "learned:"+p.keep+">"+p.over)
+	}
+
+	return strings.Join(res, ",")
+}
+
+// auditEntry is a decision acted on, as recorded in the audit log
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	model.Decision
+	DryRun bool              `json:"dry_run"`
+	Owners map[string]string `json:"owners,omitempty"`
+}
+
+// writeAuditLog appends the decisions of a run to the audit log as JSON lines
+// The ownership of the files before acting on them is recorded as uid:gid, so that it can be restored.
+func writeAuditLog(auditLog string, decisions []decision, owners map[string]ownership, opts options) error {
+	if len(decisions) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+
+	now := time.Now()
+	for _, d := range decisions {
+		entry := auditEntry{now, d.export(opts.action), opts.dryRun, map[string]string{}}
+		for _, file := range append([]string{d.keep}, d.files...) {
+			if o, ok := owners[file]; ok {
+				entry.Owners[file] = o.String()
+			}
+		}
+
+		if err := enc.Encode(entry); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// loadAuditLog reads the decisions recorded in an audit log, in the order they were acted on
+func loadAuditLog(auditLog string) ([]auditEntry, error) {
+	f, err := os.Open(auditLog)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var res []auditEntry
+
+	dec := json.NewDecoder(f)
+	for {
+		var entry auditEntry
+
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit log %s: %v", auditLog, err)
+		}
+
+		res = append(res, entry)
+	}
+}
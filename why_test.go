@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_reasons(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"prefer", preferReason(2), "prefer:2"},
+		{"keep", keepReason(keepNewest, 0), "keep:newest"},
+		{"prefer-then-keep", keepReason(keepOldest, 1), "prefer:1,keep:oldest"},
+		{"learned", learnedReason([]dirPreference{{"/a", "/b"}, {"/a", "/c"}}), "learned:/a>/b,learned:/a>/c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("reason = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dblfinder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	auditLog := filepath.Join(dir, "audit.jsonl")
+	opts := options{action: trashAction}
+
+	for i := 0; i < 2; i++ {
+		if err := writeAuditLog(auditLog, []decision{{"a", []string{"b"}, keepReason(keepNewest, 0)}}, map[string]ownership{"b": {1000, 100}}, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(auditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) != 2 || entries[1].Reason != "keep:newest" || entries[1].Action != trashAction || entries[1].Files[0] != "b" || entries[1].Owners["b"] != "1000:100" {
+		t.Errorf("writeAuditLog() wrote %+v", entries)
+	}
+}